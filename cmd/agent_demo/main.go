@@ -65,7 +65,7 @@ func main() {
 	sessionCtx := scaffold.CreateSessionContext(fmt.Sprintf("session_%d", time.Now().Unix()))
 
 	fmt.Printf("\n✅ Logged in as: %s (%s)\n", userID, userRole)
-	fmt.Printf("🔐 Permissions: %s\n", strings.Join(userCtx.Permissions, ", "))
+	fmt.Printf("🔐 Permissions: %s\n", strings.Join(agent.EffectivePermissions(userCtx), ", "))
 	fmt.Printf("📊 Session ID: %s\n", sessionCtx.ID)
 
 	// Show available tools
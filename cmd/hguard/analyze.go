@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SafellmHub/hguard-go/pkg/hallucinationguard"
+)
+
+const analyzeUsage = "Usage: hguard analyze [-schemas file] [-env name] [-combining algorithm] [-json] <policies.yaml>"
+
+// runAnalyze statically analyzes a policy file and reports the decisions
+// each tool can reach, conflicting and dead policies, and tools left to the
+// implicit default:allow. It exits 0 when there are no conflicts or dead
+// policies, 1 when there are and 2 on usage or load errors.
+func runAnalyze(args []string) int {
+	fs := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	schemas := fs.String("schemas", "", "schema file whose tools to analyze")
+	env := fs.String("env", "", "policy overlay to apply")
+	combining := fs.String("combining", "", "combining algorithm for tools the file does not configure")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) != 1 {
+		fmt.Fprintln(os.Stderr, analyzeUsage)
+		return 2
+	}
+
+	analysis, err := hallucinationguard.AnalyzePolicies(files[0], hallucinationguard.AnalyzeOptions{
+		Schemas:   *schemas,
+		Env:       *env,
+		Combining: *combining,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(analysis); err != nil {
+			fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+			return 2
+		}
+	} else {
+		printAnalysis(analysis)
+	}
+	if len(analysis.Conflicts) > 0 || len(analysis.DeadRules) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printAnalysis writes one section per tool with its reachable decisions,
+// followed by the conflicts, dead policies and default:allow tools.
+func printAnalysis(a *hallucinationguard.PolicyAnalysis) {
+	for i, t := range a.Tools {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf("%s (%s, policies: %d, combinations: %d)\n", t.Tool, t.Algorithm, t.Policies, t.Scenarios)
+		if t.Truncated {
+			fmt.Println("  too many combinations to explore")
+		}
+		for _, o := range t.Outcomes {
+			fmt.Printf("  %s by %s\n", o.Action, o.PolicyID)
+			for _, when := range o.When {
+				fmt.Printf("    when %s\n", when)
+			}
+		}
+	}
+
+	fmt.Printf("\nconflicts: %d\n", len(a.Conflicts))
+	for _, c := range a.Conflicts {
+		fmt.Printf("  %s (%s) and %s (%s) at priority %d on %s\n",
+			c.First, c.FirstAction, c.Second, c.SecondAction, c.Priority, strings.Join(c.Tools, ", "))
+		fmt.Printf("    when %s\n", c.When)
+	}
+	fmt.Printf("\ndead policies: %d\n", len(a.DeadRules))
+	for _, d := range a.DeadRules {
+		fmt.Printf("  %s: %s\n", d.PolicyID, d.Reason)
+	}
+	if len(a.DefaultAllow) > 0 {
+		fmt.Printf("\nimplicit default:allow: %s\n", strings.Join(a.DefaultAllow, ", "))
+	}
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/SafellmHub/hguard-go/pkg/hallucinationguard"
+)
+
+func runAudit(args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: hguard audit <verify|keygen> [arguments]")
+		return 2
+	}
+	switch args[0] {
+	case "verify":
+		return runAuditVerify(args[1:])
+	case "keygen":
+		return runAuditKeygen()
+	default:
+		fmt.Fprintf(os.Stderr, "hguard audit: unknown subcommand %q\n", args[0])
+		return 2
+	}
+}
+
+// runAuditVerify checks the hash chain and checkpoint signatures of a log.
+// It exits 0 when the log is intact, 1 when tampering is detected and 2 on
+// usage or I/O errors.
+func runAuditVerify(args []string) int {
+	fs := flag.NewFlagSet("audit verify", flag.ContinueOnError)
+	pubHex := fs.String("pubkey", "", "hex-encoded ed25519 public key used to verify checkpoints")
+	pubFile := fs.String("pubkey-file", "", "file containing the hex-encoded ed25519 public key")
+	head := fs.String("head", "", "head printed by an earlier verification; fails if the log no longer contains it")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: hguard audit verify [-pubkey hex | -pubkey-file path] [-head digest] <file>")
+		return 2
+	}
+
+	if *pubFile != "" {
+		b, err := os.ReadFile(*pubFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+			return 2
+		}
+		*pubHex = string(b)
+	}
+	var pub ed25519.PublicKey
+	if *pubHex != "" {
+		b, err := hex.DecodeString(strings.TrimSpace(*pubHex))
+		if err != nil || len(b) != ed25519.PublicKeySize {
+			fmt.Fprintln(os.Stderr, "hguard: public key must be a hex-encoded ed25519 key")
+			return 2
+		}
+		pub = ed25519.PublicKey(b)
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+		return 2
+	}
+	defer f.Close()
+
+	report, err := hallucinationguard.VerifyAuditLogHead(f, pub, *head)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+		return 2
+	}
+
+	fmt.Printf("records: %d, checkpoints: %d, last checkpoint: %d\n", report.Records, report.Checkpoints, report.LastCheckpoint)
+	if report.Head != "" {
+		fmt.Printf("head: %s\n", report.Head)
+	}
+	if pub == nil {
+		fmt.Println("warning: no public key given, checkpoint signatures were not verified")
+	}
+	if report.UncheckpointedN > 0 {
+		fmt.Printf("warning: %d trailing record(s) are not covered by a checkpoint; truncation of this tail cannot be detected\n", report.UncheckpointedN)
+	}
+	if report.OK() {
+		fmt.Println("OK: audit log is intact")
+		return 0
+	}
+	for _, p := range report.Problems {
+		fmt.Printf("line %d (seq %d): %s\n", p.Line, p.Seq, p.Message)
+	}
+	fmt.Printf("FAIL: %d problem(s) found\n", len(report.Problems))
+	return 1
+}
+
+// runAuditKeygen prints a fresh ed25519 key pair for signing checkpoints.
+func runAuditKeygen() int {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+		return 2
+	}
+	fmt.Printf("public:  %s\n", hex.EncodeToString(pub))
+	fmt.Printf("private: %s\n", hex.EncodeToString(priv))
+	return 0
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/SafellmHub/hguard-go/pkg/hallucinationguard"
+)
+
+const diffUsage = "Usage: hguard diff [-schemas file] [-env name] [-combining algorithm] [-json] -calls corpus.jsonl <old.yaml> <new.yaml>"
+
+// runDiff replays a corpus of tool calls through two policy files and
+// reports the decisions that changed. It exits 0 when no decision changed,
+// 1 when some did and 2 on usage or load errors.
+func runDiff(args []string) int {
+	fs := flag.NewFlagSet("diff", flag.ContinueOnError)
+	calls := fs.String("calls", "", "JSON Lines file of tool calls to replay")
+	schemas := fs.String("schemas", "", "schema file to validate calls against before the policies")
+	env := fs.String("env", "", "policy overlay to apply to both files")
+	combining := fs.String("combining", "", "combining algorithm for tools the files do not configure")
+	asJSON := fs.Bool("json", false, "print the diff as JSON")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) != 2 || *calls == "" {
+		fmt.Fprintln(os.Stderr, diffUsage)
+		return 2
+	}
+
+	f, err := os.Open(*calls)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+		return 2
+	}
+	defer f.Close()
+
+	diff, err := hallucinationguard.DiffPolicies(context.Background(), files[0], files[1], f, hallucinationguard.DiffOptions{
+		Schemas:   *schemas,
+		Env:       *env,
+		Combining: *combining,
+	})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(diff); err != nil {
+			fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+			return 2
+		}
+	} else {
+		printDiff(diff)
+	}
+	if diff.Changed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// printDiff writes one section per tool and policy change, listing each
+// changed call with its old and new decision.
+func printDiff(diff *hallucinationguard.PolicyDiff) {
+	fmt.Printf("calls: %d, changed: %d\n", diff.Calls, diff.Changed)
+	if diff.Skipped > 0 {
+		fmt.Printf("skipped: %d call(s) without a reproducible recorded decision\n", diff.Skipped)
+	}
+	for _, g := range diff.Groups {
+		fmt.Printf("\n%s: %s -> %s (%d call(s))\n", g.Tool, g.OldPolicy, g.NewPolicy, len(g.Changes))
+		for _, c := range g.Changes {
+			fmt.Printf("  line %d: %s\n", c.Line, formatDecision(c.Old))
+			fmt.Printf("      now: %s\n", formatDecision(c.New))
+		}
+	}
+}
+
+func formatDecision(d hallucinationguard.Decision) string {
+	return fmt.Sprintf("%s %s %q", d.Status, d.Action, d.Reason)
+}
+
+// parseInterspersed parses flags that may follow positional arguments, as in
+// "hguard diff old.yaml new.yaml --calls corpus.jsonl", and returns the
+// positional arguments.
+func parseInterspersed(fs *flag.FlagSet, args []string) ([]string, error) {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			return positional, nil
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+}
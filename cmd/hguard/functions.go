@@ -0,0 +1,44 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/SafellmHub/hguard-go/pkg/hallucinationguard"
+)
+
+// runFunctions lists the helper functions available to policy conditions.
+func runFunctions(args []string) int {
+	fs := flag.NewFlagSet("functions", flag.ContinueOnError)
+	asJSON := fs.Bool("json", false, "print the functions as JSON")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 0 {
+		fmt.Fprintln(os.Stderr, "Usage: hguard functions [-json]")
+		return 2
+	}
+
+	functions := hallucinationguard.ConditionFunctions()
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(functions); err != nil {
+			fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+			return 2
+		}
+		return 0
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, f := range functions {
+		fmt.Fprintf(w, "%s\t%s\n", f.Signature, f.Description)
+	}
+	if err := w.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+		return 2
+	}
+	return 0
+}
@@ -0,0 +1,59 @@
+// Command hguard is the HallucinationGuard command line tool.
+//
+// Usage:
+//
+//	hguard audit verify [-pubkey hex | -pubkey-file path] [-head digest] <file>
+//	hguard audit keygen
+//	hguard functions [-json]
+//	hguard diff [-schemas file] [-env name] [-json] -calls corpus.jsonl <old.yaml> <new.yaml>
+//	hguard replay [-schemas file] [-policies file] [-tenants dir] [-env name] [-json] <recording.jsonl>
+//	hguard analyze [-schemas file] [-env name] [-combining algorithm] [-json] <policies.yaml>
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+// command is a top-level hguard subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) int
+}
+
+var commands = []command{
+	{name: "audit", summary: "Work with tamper-evident decision logs", run: runAudit},
+	{name: "functions", summary: "List helper functions available to policy conditions", run: runFunctions},
+	{name: "diff", summary: "Show the decisions a policy change alters on recorded calls", run: runDiff},
+	{name: "replay", summary: "Check recorded decisions against current schemas and policies", run: runReplay},
+	{name: "analyze", summary: "Report reachable decisions, conflicts and dead policies of a policy file", run: runAnalyze},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:]))
+}
+
+func run(args []string) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		usage()
+		return 2
+	}
+	for _, c := range commands {
+		if c.name == args[0] {
+			return c.run(args[1:])
+		}
+	}
+	fmt.Fprintf(os.Stderr, "hguard: unknown command %q\n\n", args[0])
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: hguard <command> [arguments]")
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", c.name, c.summary)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/SafellmHub/hguard-go/pkg/hallucinationguard"
+)
+
+const replayUsage = "Usage: hguard replay [-schemas file] [-policies file] [-tenants dir] [-env name] [-json] <recording.jsonl>"
+
+// runReplay re-validates a recording against schemas and policies and
+// reports the calls whose decision no longer matches the recorded one. It
+// exits 0 when every decision is reproduced, 1 on regressions and 2 on usage
+// or load errors.
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	schemas := fs.String("schemas", "", "schema file to validate against")
+	policies := fs.String("policies", "", "policy file to validate against")
+	tenants := fs.String("tenants", "", "directory of tenant bundles")
+	env := fs.String("env", "", "policy overlay to apply")
+	asJSON := fs.Bool("json", false, "print the report as JSON")
+	files, err := parseInterspersed(fs, args)
+	if err != nil {
+		return 2
+	}
+	if len(files) != 1 {
+		fmt.Fprintln(os.Stderr, replayUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	var opts []hallucinationguard.GuardOption
+	if *env != "" {
+		opts = append(opts, hallucinationguard.WithPolicyEnvironment(*env))
+	}
+	guard := hallucinationguard.New(opts...)
+	if *schemas != "" {
+		if err := guard.LoadSchemasFromFile(ctx, *schemas); err != nil {
+			fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+			return 2
+		}
+	}
+	if *policies != "" {
+		if err := guard.LoadPoliciesFromFile(ctx, *policies); err != nil {
+			fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+			return 2
+		}
+	}
+	if *tenants != "" {
+		if err := guard.LoadTenants(ctx, *tenants); err != nil {
+			fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+			return 2
+		}
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+		return 2
+	}
+	defer f.Close()
+
+	report, err := guard.ReplayRecording(ctx, f)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+		return 2
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(report); err != nil {
+			fmt.Fprintf(os.Stderr, "hguard: %v\n", err)
+			return 2
+		}
+	} else {
+		printDiff(report)
+	}
+	if report.Changed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,73 @@
+package hallucinationguard
+
+import (
+	"fmt"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+	"github.com/SafellmHub/hguard-go/pkg/internal/schema"
+)
+
+// PolicyAnalysis is the outcome of AnalyzePolicies.
+type PolicyAnalysis = policy.Analysis
+
+// ToolAnalysis lists the decisions one tool's calls can reach.
+type ToolAnalysis = policy.ToolAnalysis
+
+// PolicyOutcome is a decision and the predicates leading to it.
+type PolicyOutcome = policy.Outcome
+
+// PolicyConflict is a pair of policies with the same priority and different
+// actions that can match the same call.
+type PolicyConflict = policy.Conflict
+
+// DeadPolicy is a policy that decides no call, with the reason why.
+type DeadPolicy = policy.DeadRule
+
+// AnalyzeOptions configures AnalyzePolicies.
+type AnalyzeOptions struct {
+	// Schemas is a schema file whose tools, and their tags, are analyzed
+	// along with every tool a policy names. Empty analyzes the named tools
+	// only.
+	Schemas string
+	// Env selects the policies.<env>.yaml overlay.
+	Env string
+	// Combining is the combining algorithm for tools the file does not
+	// configure.
+	Combining string
+}
+
+// AnalyzePolicies statically analyzes a policy file without evaluating any
+// call. For each tool it lists which combinations of roles, permissions and
+// parameter values lead to which decision, and it reports policies with the
+// same priority that can match together with different actions, policies
+// that can never decide a call, and tools some calls reach with no matching
+// policy (an implicit default:allow). Predicates other than comparisons with
+// string literals, roles and permissions, such as params.amount > 100, are
+// treated as independent, so conflicts may name combinations no call
+// produces. BUDGET policies and sequence rules are not analyzed.
+//
+// Example:
+//
+//	analysis, err := hallucinationguard.AnalyzePolicies("policies.yaml", AnalyzeOptions{Schemas: "schemas.yaml"})
+//	for _, dead := range analysis.DeadRules { /* dead.PolicyID, dead.Reason */ }
+func AnalyzePolicies(path string, opts AnalyzeOptions) (*PolicyAnalysis, error) {
+	algorithm := policy.CombiningAlgorithm(opts.Combining)
+	if algorithm != "" && !algorithm.Valid() {
+		return nil, fmt.Errorf("unknown combining algorithm %q", opts.Combining)
+	}
+	tools := map[string][]string{}
+	if opts.Schemas != "" {
+		schemas := schema.NewRegistry(false)
+		if err := schemas.Load(opts.Schemas); err != nil {
+			return nil, fmt.Errorf("failed to load schemas from %s: %w", opts.Schemas, err)
+		}
+		for name, s := range schemas.All() {
+			tools[name] = s.Tags
+		}
+	}
+	set, err := policy.LoadSet(path, opts.Env, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policies from %s: %w", path, err)
+	}
+	return set.Analyze(tools, algorithm), nil
+}
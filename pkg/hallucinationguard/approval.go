@@ -0,0 +1,222 @@
+package hallucinationguard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/approval"
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+)
+
+// ApprovalRequest is a tool call parked by a REQUIRE_APPROVAL policy.
+type ApprovalRequest = approval.Request
+
+// ApprovalStatus is the lifecycle state of an ApprovalRequest.
+type ApprovalStatus = approval.Status
+
+// ApprovalStore holds pending approval requests. Implement it to share
+// approvals across processes; the default is an in-memory store.
+type ApprovalStore = approval.Store
+
+// Approval request states.
+const (
+	ApprovalPending  = approval.StatusPending
+	ApprovalApproved = approval.StatusApproved
+	ApprovalDenied   = approval.StatusDenied
+	ApprovalExpired  = approval.StatusExpired
+	ApprovalConsumed = approval.StatusConsumed
+)
+
+// Errors returned by approval operations.
+var (
+	ErrApprovalNotFound     = approval.ErrNotFound
+	ErrApprovalExpired      = approval.ErrExpired
+	ErrApprovalNotPending   = approval.ErrNotPending
+	ErrApprovalNotApproved  = approval.ErrNotApproved
+	ErrApprovalSelfApproval = approval.ErrSelfApproval
+	ErrApprovalNoApprover   = approval.ErrNoApprover
+	// ErrApprovalBudgetExceeded is returned by ResumeApproved when releasing
+	// the call would exceed a BUDGET policy. The request stays approved.
+	ErrApprovalBudgetExceeded = errors.New("approved call exceeds budget")
+)
+
+// DefaultApprovalTTL is how long a parked call waits for a decision and, once
+// approved, for ResumeApproved.
+const DefaultApprovalTTL = 24 * time.Hour
+
+// NewMemoryApprovalStore creates an in-memory ApprovalStore.
+func NewMemoryApprovalStore() ApprovalStore {
+	return approval.NewMemoryStore()
+}
+
+// WithApprovalStore sets the store for calls parked by REQUIRE_APPROVAL
+// policies and how long they stay valid. A non-positive ttl uses
+// DefaultApprovalTTL.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithApprovalStore(myStore, time.Hour))
+func WithApprovalStore(store ApprovalStore, ttl time.Duration) GuardOption {
+	return func(g *Guard) {
+		if ttl <= 0 {
+			ttl = DefaultApprovalTTL
+		}
+		g.approvals = store
+		g.approvalTTL = ttl
+	}
+}
+
+// PendingApprovals lists calls awaiting a decision, oldest first.
+//
+// Example:
+//
+//	pending, err := guard.PendingApprovals(ctx)
+func (g *Guard) PendingApprovals(ctx context.Context) ([]ApprovalRequest, error) {
+	return g.approvals.List(ctx, ApprovalPending)
+}
+
+// Approve records approver's approval of a pending call. The call can then be
+// released once with ResumeApproved. The approval is written to the audit
+// log. An empty approver fails with ErrApprovalNoApprover, and the user who
+// made the call cannot approve it: that fails with ErrApprovalSelfApproval.
+//
+// Example:
+//
+//	err := guard.Approve(ctx, result.ApprovalToken, "alice@example.com")
+func (g *Guard) Approve(ctx context.Context, token, approver string) error {
+	// Checked here as well as in the store, for stores that do not
+	if approver == "" {
+		return ErrApprovalNoApprover
+	}
+	req, err := g.approvals.Get(ctx, token)
+	if err != nil {
+		return err
+	}
+	if req.SelfApproval(approver) {
+		return ErrApprovalSelfApproval
+	}
+	req, err = g.approvals.Approve(ctx, token, approver)
+	if err != nil {
+		return err
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	g.audit(stamped(req.ToolCall), model.ValidationResult{
+		ToolCallID:   req.ToolCall.ID,
+		Status:       "approved",
+		PolicyAction: string(policy.PolicyRequireApproval),
+		PolicyID:     req.PolicyID,
+		Reason:       fmt.Sprintf("Approved by %s", approver),
+	})
+	return nil
+}
+
+// Deny records approver's denial of a pending call and writes it to the audit
+// log. An empty approver fails with ErrApprovalNoApprover.
+//
+// Example:
+//
+//	err := guard.Deny(ctx, result.ApprovalToken, "alice@example.com", "amount too large")
+func (g *Guard) Deny(ctx context.Context, token, approver, note string) error {
+	if approver == "" {
+		return ErrApprovalNoApprover
+	}
+	req, err := g.approvals.Deny(ctx, token, approver, note)
+	if err != nil {
+		return err
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	g.audit(stamped(req.ToolCall), model.ValidationResult{
+		ToolCallID:   req.ToolCall.ID,
+		Status:       "rejected",
+		PolicyAction: string(policy.PolicyRequireApproval),
+		Reason:       fmt.Sprintf("Denied by %s: %s", approver, note),
+	})
+	return nil
+}
+
+// ResumeApproved releases an approved call for execution. It succeeds at most
+// once per token and fails if the call is still pending, was denied, or has
+// expired. BUDGET policies are checked again when the call is released, and a
+// call that would exceed one fails with ErrApprovalBudgetExceeded without
+// being consumed. The call is recorded in its session history and audit log.
+//
+// Example:
+//
+//	tc, err := guard.ResumeApproved(ctx, token)
+//	if err == nil { /* execute tc */ }
+func (g *Guard) ResumeApproved(ctx context.Context, token string) (ToolCall, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	req, err := g.approvals.Get(ctx, token)
+	if err != nil {
+		return ToolCall{}, err
+	}
+	if req.Status == ApprovalApproved {
+		if budget, exceeded := g.checkBudgets(ctx, stamped(req.ToolCall)); exceeded {
+			g.audit(stamped(req.ToolCall), model.ValidationResult{
+				ToolCallID:   req.ToolCall.ID,
+				Status:       "rejected",
+				PolicyAction: string(budget.Action),
+				PolicyID:     budget.PolicyID,
+				Reason:       budget.Reason,
+			})
+			return ToolCall{}, fmt.Errorf("%w: %s", ErrApprovalBudgetExceeded, budget.Reason)
+		}
+	}
+	req, err = g.approvals.Consume(ctx, token)
+	if err != nil {
+		return ToolCall{}, err
+	}
+	result := model.ValidationResult{
+		ToolCallID:       req.ToolCall.ID,
+		Status:           "approved",
+		ExecutionAllowed: true,
+		PolicyAction:     string(policy.PolicyRequireApproval),
+		PolicyID:         req.PolicyID,
+		Reason:           fmt.Sprintf("Approved by %s", req.Approver),
+	}
+	tc := stamped(req.ToolCall)
+	g.recordSessionCall(ctx, tc, result)
+	g.trackCall(tc, result)
+	g.recordUsage(ctx, tc, result)
+	g.audit(tc, result)
+	return ToolCall{
+		Name:       req.ToolCall.Name,
+		Parameters: req.ToolCall.Parameters,
+		Context:    toPublicContext(req.ToolCall.Context),
+	}, nil
+}
+
+// parkForApproval stores a pending call and returns its token. If the store
+// fails, the call is rejected instead.
+func (g *Guard) parkForApproval(ctx context.Context, tc model.ToolCall, result model.ValidationResult) (string, model.ValidationResult) {
+	token := approval.NewToken()
+	err := g.approvals.Add(ctx, ApprovalRequest{
+		Token:     token,
+		ToolCall:  tc,
+		Reason:    result.Reason,
+		PolicyID:  result.PolicyID,
+		Status:    ApprovalPending,
+		CreatedAt: tc.Timestamp,
+		ExpiresAt: tc.Timestamp.Add(g.approvalTTL),
+	})
+	if err != nil {
+		result.Status = "rejected"
+		result.ExecutionAllowed = false
+		result.Reason = fmt.Sprintf("Approval required but approval store unavailable: %v", err)
+		return "", result
+	}
+	return token, result
+}
+
+// stamped returns tc with the current time, for audit records written after
+// the original validation.
+func stamped(tc model.ToolCall) model.ToolCall {
+	tc.Timestamp = time.Now()
+	return tc
+}
@@ -0,0 +1,124 @@
+package hallucinationguard
+
+import (
+	"context"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/attribute"
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+)
+
+// AttributeProvider supplies data the caller did not send, so conditions can
+// reference attributes such as user.department or account.balance. The
+// provider is consulted lazily, only when a condition evaluates one of its
+// paths, and each path is resolved at most once per ValidateToolCall.
+type AttributeProvider interface {
+	// Attributes lists the dotted paths the provider serves, e.g.
+	// "user.department" or "account.balance". Paths under user.role,
+	// user.permissions and metadata back up values the caller left empty.
+	Attributes() []string
+	// Resolve returns the value of path for the call. A nil value means the
+	// attribute is unknown; an error fails the condition, and the policy is
+	// skipped.
+	Resolve(ctx context.Context, path string, call ToolCall) (interface{}, error)
+}
+
+// WithAttributeProvider makes the provider's attributes available to the
+// Guard's policy conditions. The provider is private to the Guard, so Guards
+// for different tenants or directories can serve the same path differently,
+// and it takes precedence over a provider registered with
+// RegisterAttributeProvider. It panics if a path is invalid or names data the
+// caller always sends, such as user.id or params.
+//
+// Example:
+//
+//	attrs, err := hallucinationguard.LoadAttributesFile("attributes.json")
+//	guard := hallucinationguard.New(hallucinationguard.WithAttributeProvider(attrs))
+//	// condition: "user.department == 'finance' && account.balance >= params.amount"
+func WithAttributeProvider(p AttributeProvider) GuardOption {
+	return func(g *Guard) {
+		if err := g.ext().RegisterAttributeProvider(internalProvider(p)); err != nil {
+			panic("hallucinationguard: " + err.Error())
+		}
+	}
+}
+
+// RegisterAttributeProvider is WithAttributeProvider for every Guard in the
+// process, as a default that WithAttributeProvider can override. It fails if
+// a path is invalid or names data the caller always sends.
+//
+// Example:
+//
+//	err := hallucinationguard.RegisterAttributeProvider(attrs)
+func RegisterAttributeProvider(p AttributeProvider) error {
+	return policy.RegisterAttributeProvider(internalProvider(p))
+}
+
+// internalProvider exposes p to the policy engine.
+func internalProvider(p AttributeProvider) policy.AttributeProvider {
+	if f, ok := p.(*FileAttributeProvider); ok {
+		return f.p
+	}
+	return attributeAdapter{p: p}
+}
+
+// attributeAdapter exposes a public AttributeProvider to the policy engine.
+type attributeAdapter struct {
+	p AttributeProvider
+}
+
+func (a attributeAdapter) Attributes() []string {
+	return a.p.Attributes()
+}
+
+func (a attributeAdapter) Resolve(ctx context.Context, path string, tc model.ToolCall) (interface{}, error) {
+	return a.p.Resolve(ctx, path, ToolCall{Name: tc.Name, Parameters: tc.Parameters, Context: toPublicContext(tc.Context)})
+}
+
+// FileAttributeProvider is the reference AttributeProvider, backed by a JSON
+// file of entities keyed by values from the call:
+//
+//	{
+//	  "keys": {"account": "params.account_id"},
+//	  "entities": {
+//	    "user":    {"u-1": {"department": "finance"}},
+//	    "account": {"acct-9": {"balance": 1200}}
+//	  }
+//	}
+//
+// user entities are keyed by user ID and tenant entities by tenant ID unless
+// keys says otherwise. Keys may be user.id, tenant.id, session.id,
+// params.<name> or metadata.<name>.
+type FileAttributeProvider struct {
+	p *attribute.FileProvider
+}
+
+// LoadAttributesFile reads a FileAttributeProvider from path.
+//
+// Example:
+//
+//	attrs, err := hallucinationguard.LoadAttributesFile("attributes.json")
+func LoadAttributesFile(path string) (*FileAttributeProvider, error) {
+	p, err := attribute.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return &FileAttributeProvider{p: p}, nil
+}
+
+// Reload re-reads the file. Changed values apply to the next validation;
+// attribute names added to the file need the provider to be added to a new
+// Guard, or registered again.
+func (f *FileAttributeProvider) Reload() error {
+	return f.p.Reload()
+}
+
+// Attributes implements AttributeProvider.
+func (f *FileAttributeProvider) Attributes() []string {
+	return f.p.Attributes()
+}
+
+// Resolve implements AttributeProvider.
+func (f *FileAttributeProvider) Resolve(ctx context.Context, path string, call ToolCall) (interface{}, error) {
+	return f.p.Resolve(ctx, path, model.ToolCall{Name: call.Name, Parameters: call.Parameters, Context: toInternalContext(call.Context)})
+}
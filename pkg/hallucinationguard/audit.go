@@ -0,0 +1,116 @@
+package hallucinationguard
+
+import (
+	"crypto/ed25519"
+	"io"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/audit"
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// AuditReport is the outcome of verifying an audit log with VerifyAuditLog.
+type AuditReport = audit.Report
+
+// AuditProblem describes a single integrity violation in an audit log.
+type AuditProblem = audit.Problem
+
+// AuditLog is a tamper-evident decision log opened with ResumeAuditLog.
+type AuditLog = audit.Log
+
+// WithAuditLog records every decision made by ValidateToolCall to w as a
+// tamper-evident, hash-chained JSON Lines log. If key is non-nil, a signed
+// checkpoint is written every checkpointInterval records; verify the file with
+// `hguard audit verify`.
+//
+// The log starts a new chain, so w must be empty. To keep appending to the
+// same file across restarts, use ResumeAuditLog and WithResumedAuditLog.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithAuditLog(f, privateKey, 100))
+func WithAuditLog(w io.Writer, key ed25519.PrivateKey, checkpointInterval int) GuardOption {
+	return func(g *Guard) {
+		g.auditLog = audit.NewLog(w, key, checkpointInterval)
+	}
+}
+
+// ResumeAuditLog opens the audit log in rw for appending. It reads the
+// existing entries so new decisions continue their sequence numbers and
+// hash chains. rw is typically a file opened with os.O_RDWR|os.O_APPEND.
+//
+// Example:
+//
+//	f, _ := os.OpenFile("decisions.jsonl", os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+//	log, err := hallucinationguard.ResumeAuditLog(f, privateKey, 100)
+//	guard := hallucinationguard.New(WithResumedAuditLog(log))
+func ResumeAuditLog(rw io.ReadWriter, key ed25519.PrivateKey, checkpointInterval int) (*AuditLog, error) {
+	return audit.ResumeLog(rw, key, checkpointInterval)
+}
+
+// WithResumedAuditLog records every decision made by ValidateToolCall to a
+// log opened with ResumeAuditLog.
+func WithResumedAuditLog(log *AuditLog) GuardOption {
+	return func(g *Guard) {
+		g.auditLog = log
+	}
+}
+
+// CheckpointAuditLog writes a signed checkpoint covering every decision logged
+// so far. Call it before shutdown so the tail of the log is covered.
+//
+// Example:
+//
+//	defer guard.CheckpointAuditLog()
+func (g *Guard) CheckpointAuditLog() error {
+	if g.auditLog == nil {
+		return nil
+	}
+	return g.auditLog.Checkpoint()
+}
+
+// audit appends the decision to the audit log, if one is configured.
+func (g *Guard) audit(tc model.ToolCall, result model.ValidationResult) {
+	if g.auditLog == nil {
+		return
+	}
+	err := g.auditLog.Append(audit.Record{
+		Timestamp:    tc.Timestamp.UTC(),
+		ToolCallID:   result.ToolCallID,
+		ToolName:     tc.Name,
+		Parameters:   g.redactParameters(tc.Context.TenantID, tc.Name, tc.Parameters),
+		UserID:       tc.Context.UserID,
+		TenantID:     tc.Context.TenantID,
+		SessionID:    tc.Context.SessionID,
+		Status:       result.Status,
+		PolicyAction: result.PolicyAction,
+		Reason:       g.redactString(result.Reason),
+	})
+	if err != nil {
+		g.logger(tc).Error("audit log append failed for %s: %v", result.ToolCallID, err)
+	}
+}
+
+// VerifyAuditLog checks the hash chain of an audit log written by a Guard
+// configured with WithAuditLog. If pub is non-nil, checkpoint signatures are
+// verified as well. Deleted, reordered or edited records are reported as
+// problems; an error is returned only when the log cannot be read or decoded.
+//
+// Example:
+//
+//	report, err := hallucinationguard.VerifyAuditLog(f, publicKey)
+//	if err == nil && report.OK() { /* log is intact */ }
+func VerifyAuditLog(r io.Reader, pub ed25519.PublicKey) (*AuditReport, error) {
+	return audit.Verify(r, pub)
+}
+
+// VerifyAuditLogHead is VerifyAuditLog for a log whose report head was kept
+// from an earlier verification. It also reports the log as tampered when
+// that checkpoint is gone, which catches a tail deleted together with its
+// checkpoints.
+//
+// Example:
+//
+//	report, err := hallucinationguard.VerifyAuditLogHead(f, publicKey, previous.Head)
+func VerifyAuditLogHead(r io.Reader, pub ed25519.PublicKey, head string) (*AuditReport, error) {
+	return audit.VerifyHead(r, pub, head)
+}
@@ -0,0 +1,67 @@
+package hallucinationguard
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// BenchmarkValidateToolCall measures ValidateToolCall against 10k indexed
+// policies, as ValidateAndPolicy's benchmark does, plus what the Guard adds:
+// call IDs, usage charges, session history, the decision cache and the audit
+// log. Run with -benchmem to see allocations per call.
+func BenchmarkValidateToolCall(b *testing.B) {
+	var policies strings.Builder
+	policies.WriteString("policies:\n")
+	for i := 0; i < 10000; i++ {
+		tool := "gt_transfer"
+		if i%20 != 0 {
+			tool = fmt.Sprintf("tool_%d", i%500)
+		}
+		fmt.Fprintf(&policies, "  - tool_name: %s\n    type: REJECT\n    condition: \"user.role == 'role_%d' && params.amount > %d\"\n    priority: %d\n", tool, i, i, i%50)
+	}
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	configs := []struct {
+		name    string
+		session string
+		opts    []GuardOption
+	}{
+		{"default", "", nil},
+		{"session", "session456", nil},
+		{"decision-cache", "", []GuardOption{WithDecisionCache(time.Minute, 0)}},
+		{"audit-log", "", []GuardOption{WithAuditLog(io.Discard, key, 0)}},
+	}
+	for _, c := range configs {
+		b.Run(c.name, func(b *testing.B) {
+			guard := New(c.opts...)
+			ctx := context.Background()
+			if err := guard.LoadSchemasFromFile(ctx, writeTestFile(b, "schemas.yaml", testSchemas)); err != nil {
+				b.Fatal(err)
+			}
+			if err := guard.LoadPoliciesFromFile(ctx, writeTestFile(b, "policies.yaml", policies.String())); err != nil {
+				b.Fatal(err)
+			}
+			call := ToolCall{
+				Name:       "gt_transfer",
+				Parameters: map[string]interface{}{"amount": 10.0},
+				Context:    &CallContext{UserID: "user123", UserRole: "user", SessionID: c.session},
+			}
+			if result := guard.ValidateToolCall(ctx, call); !result.ExecutionAllowed {
+				b.Fatalf("Expected the call to be allowed, got %+v", result)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				guard.ValidateToolCall(ctx, call)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package hallucinationguard
+
+import (
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+)
+
+// FunctionInfo documents a helper function callable from policy conditions.
+type FunctionInfo = policy.FunctionInfo
+
+// ConditionFunctions lists the helper functions available to policy
+// conditions, sorted by name.
+//
+// Example:
+//
+//	for _, f := range hallucinationguard.ConditionFunctions() {
+//		fmt.Println(f.Signature, "-", f.Description)
+//	}
+func ConditionFunctions() []FunctionInfo {
+	return policy.Functions()
+}
+
+// CheckCondition type-checks a policy condition without evaluating it. The
+// same check runs for every condition when a policy file is loaded.
+//
+// Example:
+//
+//	err := hallucinationguard.CheckCondition("user.rol == 'admin'") // unknown field rol
+func CheckCondition(condition string) error {
+	return policy.CheckExpression(condition)
+}
+
+// ConditionFunctions lists the helper functions available to the Guard's
+// policy conditions, including those added with WithConditionFunction,
+// sorted by name.
+func (g *Guard) ConditionFunctions() []FunctionInfo {
+	return g.extensions.Functions()
+}
+
+// CheckCondition type-checks a policy condition against the Guard's
+// condition functions and attribute providers without evaluating it.
+func (g *Guard) CheckCondition(condition string) error {
+	return g.extensions.CheckExpression(condition)
+}
+
+// FunctionOption configures a function added with WithConditionFunction or
+// RegisterConditionFunction.
+type FunctionOption func(*policy.CustomFunction)
+
+// Memoize caches a condition function's results by arguments for the
+// duration of one ValidateToolCall, so a lookup used by several policies runs
+// once per call.
+func Memoize() FunctionOption {
+	return func(f *policy.CustomFunction) {
+		f.Memoize = true
+	}
+}
+
+// FunctionTimeout bounds each invocation of a condition function. A timed-out
+// call fails its condition, and the policy is skipped.
+func FunctionTimeout(d time.Duration) FunctionOption {
+	return func(f *policy.CustomFunction) {
+		f.Timeout = d
+	}
+}
+
+// FunctionDescription documents a condition function for ConditionFunctions
+// and, for functions registered with RegisterConditionFunction, `hguard
+// functions`.
+func FunctionDescription(description string) FunctionOption {
+	return func(f *policy.CustomFunction) {
+		f.Description = description
+	}
+}
+
+// WithConditionFunction makes a Go function callable from the Guard's policy
+// conditions under name. fn's parameter and result types are its declared
+// signature: conditions that call it with the wrong number or types of
+// arguments fail to load. fn may take a leading context.Context, which
+// carries the validation's deadline, and may return an error as its second
+// result.
+//
+// The function is private to the Guard: other Guards may register a
+// different function under the same name. It takes precedence over a
+// function registered with RegisterConditionFunction. It panics if fn is not
+// a function or name is invalid or names a built-in helper.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(
+//		hallucinationguard.WithConditionFunction("is_frozen", func(ctx context.Context, account string) (bool, error) {
+//			return accounts.IsFrozen(ctx, account)
+//		}, hallucinationguard.Memoize(), hallucinationguard.FunctionTimeout(50*time.Millisecond)),
+//	)
+//	// condition: "is_frozen(params.account)"
+func WithConditionFunction(name string, fn interface{}, opts ...FunctionOption) GuardOption {
+	f := conditionFunction(name, fn, opts)
+	return func(g *Guard) {
+		if err := g.ext().RegisterFunction(f); err != nil {
+			panic("hallucinationguard: " + err.Error())
+		}
+	}
+}
+
+// RegisterConditionFunction is WithConditionFunction for every Guard in the
+// process, as a default that WithConditionFunction can override. It fails if
+// fn is not a function or name is invalid or names a built-in helper.
+//
+// Example:
+//
+//	err := hallucinationguard.RegisterConditionFunction("is_weekend", isWeekend)
+func RegisterConditionFunction(name string, fn interface{}, opts ...FunctionOption) error {
+	return policy.RegisterFunction(conditionFunction(name, fn, opts))
+}
+
+func conditionFunction(name string, fn interface{}, opts []FunctionOption) policy.CustomFunction {
+	f := policy.CustomFunction{Name: name, Fn: fn}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	return f
+}
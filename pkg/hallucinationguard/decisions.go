@@ -0,0 +1,196 @@
+package hallucinationguard
+
+import (
+	"container/list"
+	"sync"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+)
+
+// DefaultDecisionCacheSize bounds the decision cache when WithDecisionCache
+// is given no size.
+const DefaultDecisionCacheSize = 10000
+
+// WithDecisionCache reuses decisions for repeated identical calls, such as
+// agent retries, for up to ttl, skipping schema and condition evaluation.
+// Calls are identical when they share the tenant, tool name, parameters and
+// the context fields the tool's conditions read; other context fields are
+// ignored. At most maxEntries decisions are kept (DefaultDecisionCacheSize
+// if zero), least recently used first out.
+//
+// Decisions are never cached when they depend on the clock, custom condition
+// functions, attribute providers, timed sequence rules or BUDGET policies, or
+// when they are pending approval or timed out. Loading schemas, policies or
+// tenants empties the cache. Session recording, audit, usage and metrics
+// still run for cached decisions.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithDecisionCache(time.Minute, 0))
+func WithDecisionCache(ttl time.Duration, maxEntries int) GuardOption {
+	return func(g *Guard) {
+		if ttl <= 0 {
+			return
+		}
+		if maxEntries <= 0 {
+			maxEntries = DefaultDecisionCacheSize
+		}
+		g.decisions = newDecisionCache(ttl, maxEntries)
+	}
+}
+
+// ClearDecisionCache drops every cached decision. The cache is cleared
+// automatically when schemas, policies or tenants are loaded through the
+// Guard; call this after changing them by other means.
+func (g *Guard) ClearDecisionCache() {
+	g.decisions.clear()
+}
+
+// decisionCache is an LRU of validation results with a TTL. It also
+// remembers each tool's policy dependencies. It is safe for concurrent use;
+// a nil cache stores nothing.
+type decisionCache struct {
+	ttl        time.Duration
+	maxEntries int
+	now        func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // Most recently used first
+	deps    map[string]memoizedDependencies
+}
+
+// memoizedDependencies are a tool's dependencies as of a generation of the
+// Guard's condition functions and attribute providers.
+type memoizedDependencies struct {
+	generation uint64
+	deps       policy.Dependencies
+}
+
+type cachedDecision struct {
+	key     string
+	result  model.ValidationResult
+	expires time.Time
+}
+
+func newDecisionCache(ttl time.Duration, maxEntries int) *decisionCache {
+	return &decisionCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		now:        time.Now,
+		entries:    map[string]*list.Element{},
+		order:      list.New(),
+		deps:       map[string]memoizedDependencies{},
+	}
+}
+
+func (c *decisionCache) get(key string) (model.ValidationResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return model.ValidationResult{}, false
+	}
+	d := e.Value.(*cachedDecision)
+	if !c.now().Before(d.expires) {
+		c.order.Remove(e)
+		delete(c.entries, key)
+		return model.ValidationResult{}, false
+	}
+	c.order.MoveToFront(e)
+	return d.result, true
+}
+
+func (c *decisionCache) put(key string, result model.ValidationResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	d := &cachedDecision{key: key, result: result, expires: c.now().Add(c.ttl)}
+	if e, ok := c.entries[key]; ok {
+		e.Value = d
+		c.order.MoveToFront(e)
+		return
+	}
+	c.entries[key] = c.order.PushFront(d)
+	for c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cachedDecision).key)
+	}
+}
+
+// dependencies returns the remembered dependencies for a tenant's tool, or
+// computes and remembers them. Dependencies remembered at another generation
+// are recomputed.
+func (c *decisionCache) dependencies(tenantID, tool string, generation uint64, compute func() policy.Dependencies) policy.Dependencies {
+	id := tenantID + "\x00" + tool
+	c.mu.Lock()
+	m, ok := c.deps[id]
+	c.mu.Unlock()
+	if ok && m.generation == generation {
+		return m.deps
+	}
+	deps := compute()
+	c.mu.Lock()
+	c.deps[id] = memoizedDependencies{generation: generation, deps: deps}
+	c.mu.Unlock()
+	return deps
+}
+
+func (c *decisionCache) clear() {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries = map[string]*list.Element{}
+	c.order.Init()
+	c.deps = map[string]memoizedDependencies{}
+}
+
+// decisionKey returns the cache key for tc, or false if the cache is off or
+// tc's decision must not be reused.
+func (g *Guard) decisionKey(tc model.ToolCall) (string, bool) {
+	if g.decisions == nil {
+		return "", false
+	}
+	tenantID := tc.Context.TenantID
+	deps := g.decisions.dependencies(tenantID, tc.Name, g.extensions.Generation(), func() policy.Dependencies {
+		set := g.policiesFor(tenantID)
+		if set == nil {
+			set = policy.Default()
+		}
+		ts, _ := g.schemasFor(tenantID).Get(tc.Name)
+		return set.Dependencies(tc.Name, ts.Tags, g.extensions)
+	})
+	return deps.Key(tc)
+}
+
+// cachedDecision returns the cached result for key, adapted to tc.
+func (g *Guard) cachedDecision(key string, tc model.ToolCall) (model.ValidationResult, bool) {
+	result, ok := g.decisions.get(key)
+	if !ok {
+		return result, false
+	}
+	result.ToolCallID = tc.ID
+	if c := result.SuggestedCorrection; c != nil {
+		result.SuggestedCorrection = &model.ToolCall{
+			ID:         tc.ID,
+			Name:       c.Name,
+			Parameters: tc.Parameters,
+			Context:    tc.Context,
+			Timestamp:  tc.Timestamp,
+		}
+	}
+	return result, true
+}
+
+// cacheDecision stores result under key unless it is specific to this call.
+func (g *Guard) cacheDecision(key string, result model.ValidationResult) {
+	switch result.Status {
+	case "pending", "timeout":
+		return
+	}
+	g.decisions.put(key, result)
+}
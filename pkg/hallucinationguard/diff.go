@@ -0,0 +1,94 @@
+package hallucinationguard
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+	"github.com/SafellmHub/hguard-go/pkg/internal/replay"
+	"github.com/SafellmHub/hguard-go/pkg/internal/schema"
+)
+
+// PolicyDiff is the outcome of DiffPolicies: the calls whose decision
+// changed, grouped by tool and by the policies matched before and after.
+type PolicyDiff = replay.Report
+
+// DecisionGroup is the changed calls of one tool that moved between the
+// same matched policies.
+type DecisionGroup = replay.Group
+
+// DecisionChange is a single call decided differently by two policy files.
+type DecisionChange = replay.Change
+
+// Decision is the action, status, reason and matched policy of a call.
+type Decision = replay.Decision
+
+// DiffOptions configures DiffPolicies.
+type DiffOptions struct {
+	// Schemas is a schema file calls are validated against before the
+	// policies, as by ValidateToolCall. Empty compares the policies alone,
+	// as if every call passed schema validation.
+	Schemas string
+	// Env selects the policies.<env>.yaml overlay of both files.
+	Env string
+	// Combining is the combining algorithm for tools neither file
+	// configures.
+	Combining string
+}
+
+// DiffPolicies replays a JSON Lines corpus of tool calls through two policy
+// files and reports every call whose action, status or reason changed. Each
+// line holds a ToolCall as JSON. The files are loaded independently of any
+// Guard, so neither affects the policies a Guard enforces. BUDGET policies
+// are not checked.
+//
+// Example:
+//
+//	diff, err := hallucinationguard.DiffPolicies(ctx, "old.yaml", "new.yaml", corpus, DiffOptions{})
+//	for _, g := range diff.Groups { /* g.Tool, g.OldPolicy -> g.NewPolicy */ }
+func DiffPolicies(ctx context.Context, oldPath, newPath string, calls io.Reader, opts DiffOptions) (*PolicyDiff, error) {
+	if a := policy.CombiningAlgorithm(opts.Combining); a != "" && !a.Valid() {
+		return nil, fmt.Errorf("unknown combining algorithm %q", opts.Combining)
+	}
+	var schemas *schema.Registry
+	if opts.Schemas != "" {
+		schemas = schema.NewRegistry(false)
+		if err := schemas.Load(opts.Schemas); err != nil {
+			return nil, fmt.Errorf("failed to load schemas from %s: %w", opts.Schemas, err)
+		}
+	}
+	before, err := diffEvaluator(oldPath, schemas, opts)
+	if err != nil {
+		return nil, err
+	}
+	after, err := diffEvaluator(newPath, schemas, opts)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := replay.ReadCalls(calls)
+	if err != nil {
+		return nil, err
+	}
+	return replay.Compare(ctx, entries, before, after), nil
+}
+
+// diffEvaluator loads a policy file into its own set and decides calls
+// against it.
+func diffEvaluator(path string, schemas *schema.Registry, opts DiffOptions) (replay.Evaluator, error) {
+	set, err := policy.LoadSet(path, opts.Env, false, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load policies from %s: %w", path, err)
+	}
+	options := schema.Options{
+		Schemas: schemas,
+		Policy:  policy.EvalOptions{Set: set, Combining: policy.CombiningAlgorithm(opts.Combining)},
+	}
+	return func(ctx context.Context, tc model.ToolCall) model.ValidationResult {
+		if schemas == nil {
+			return schema.EvaluatePolicyOnly(ctx, tc, options)
+		}
+		return schema.ValidateAndPolicyWithOptions(ctx, tc, options)
+	}, nil
+}
@@ -2,13 +2,23 @@ package hallucinationguard
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 
+	"github.com/SafellmHub/hguard-go/pkg/internal/approval"
+	"github.com/SafellmHub/hguard-go/pkg/internal/audit"
 	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
 	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+	"github.com/SafellmHub/hguard-go/pkg/internal/quota"
+	"github.com/SafellmHub/hguard-go/pkg/internal/redact"
+	"github.com/SafellmHub/hguard-go/pkg/internal/replay"
 	"github.com/SafellmHub/hguard-go/pkg/internal/schema"
+	"github.com/SafellmHub/hguard-go/pkg/internal/session"
+	"github.com/SafellmHub/hguard-go/pkg/internal/trace"
 )
 
 // Package hallucinationguard provides a guardrail system for validating and enforcing policies on LLM tool calls.
@@ -36,10 +46,11 @@ type ToolCall struct {
 // CallContext represents the context information for conditional policy evaluation
 type CallContext struct {
 	UserID          string                 `json:"user_id,omitempty"`
+	TenantID        string                 `json:"tenant_id,omitempty"` // Selects the tenant bundle loaded by LoadTenants
 	UserRole        string                 `json:"user_role,omitempty"`
 	SessionID       string                 `json:"session_id,omitempty"`
 	ConversationID  string                 `json:"conversation_id,omitempty"`
-	PreviousCalls   []string               `json:"previous_calls,omitempty"`
+	PreviousCalls   []string               `json:"previous_calls,omitempty"` // Replaced by recorded history when SessionID is set and a SessionStore is configured
 	UserPermissions []string               `json:"user_permissions,omitempty"`
 	IPAddress       string                 `json:"ip_address,omitempty"`
 	TimeOfDay       int                    `json:"time_of_day,omitempty"` // Hour of day (0-23)
@@ -60,15 +71,28 @@ type ValidationResult struct {
 	ToolCallID          string    `json:"tool_call_id,omitempty"`
 	Status              string    `json:"status,omitempty"`
 	Confidence          float64   `json:"confidence,omitempty"`
+	ApprovalToken       string    `json:"approval_token,omitempty"` // Set when Status is "pending"
 }
 
 // PolicyAction constants
 const (
-	PolicyActionALLOW          = "ALLOW"
-	PolicyActionREJECT         = "REJECT"
-	PolicyActionREWRITE        = "REWRITE"
-	PolicyActionRATE_LIMIT     = "RATE_LIMIT"
-	PolicyActionCONTEXT_REJECT = "CONTEXT_REJECT"
+	PolicyActionALLOW            = "ALLOW"
+	PolicyActionREJECT           = "REJECT"
+	PolicyActionREWRITE          = "REWRITE"
+	PolicyActionRATE_LIMIT       = "RATE_LIMIT"
+	PolicyActionCONTEXT_REJECT   = "CONTEXT_REJECT"
+	PolicyActionTIMEOUT          = "TIMEOUT" // Validation was cancelled or exceeded its EvaluationBudget
+	PolicyActionREQUIRE_APPROVAL = "REQUIRE_APPROVAL"
+	PolicyActionBUDGET           = "BUDGET" // A BUDGET policy's limit would be exceeded
+)
+
+// Combining algorithms, used by WithCombiningAlgorithm and the combining key
+// of policy files, decide the outcome when several policies match a call.
+const (
+	CombiningFirstApplicable = "first-applicable" // Highest-priority match wins (default)
+	CombiningDenyOverrides   = "deny-overrides"   // Any matching REJECT wins
+	CombiningPermitOverrides = "permit-overrides" // Any matching ALLOW wins
+	CombiningAllMustAllow    = "all-must-allow"   // Like deny-overrides, and unmatched calls are rejected
 )
 
 // SchemaLoader defines the interface for loading schemas.
@@ -95,6 +119,35 @@ type Guard struct {
 	mu           sync.RWMutex
 	schemaLoader SchemaLoader
 	policyEngine PolicyEngine
+	auditLog     *audit.Log
+
+	redactor          *redact.Redactor
+	redactCorrections bool
+
+	metrics  Metrics
+	tracer   Tracer
+	budget   EvaluationBudget
+	sessions SessionStore
+
+	approvals   ApprovalStore
+	approvalTTL time.Duration
+
+	results *trackedCalls
+	quotas  QuotaStore
+
+	policyEnv  string
+	combining  policy.CombiningAlgorithm
+	tenants    map[string]*tenantBundle
+	tenantsDir string
+
+	decisions *decisionCache
+
+	// extensions holds the Guard's own condition functions and attribute
+	// providers; nil if it has none.
+	extensions *policy.Extensions
+
+	recorder     *replay.Recorder
+	recordRedact func(*ToolCall) bool
 }
 
 // GuardOption is a functional option for configuring Guard.
@@ -126,6 +179,65 @@ func WithPolicyEngine(engine PolicyEngine) GuardOption {
 	}
 }
 
+// WithPolicyEnvironment applies the overlay policies.<env>.yaml on top of
+// each policy file loaded by the default engine, instead of the overlay named
+// by the HGUARD_ENV environment variable.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithPolicyEnvironment("prod"))
+func WithPolicyEnvironment(env string) GuardOption {
+	return func(g *Guard) {
+		g.policyEngine = defaultPolicyEngine{env: env}
+		g.policyEnv = env
+	}
+}
+
+// WithCombiningAlgorithm sets the combining algorithm for tools whose policy
+// file does not choose one with its combining key. Unknown names are ignored.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithCombiningAlgorithm(CombiningDenyOverrides))
+func WithCombiningAlgorithm(algorithm string) GuardOption {
+	return func(g *Guard) {
+		if a := policy.CombiningAlgorithm(algorithm); a.Valid() {
+			g.combining = a
+		}
+	}
+}
+
+// EvaluationBudget limits how much work a single ValidateToolCall may do.
+type EvaluationBudget struct {
+	// Timeout bounds the wall-clock time of a validation. Zero means no limit
+	// beyond the caller's context.
+	Timeout time.Duration
+	// MaxConditionMemory is expr's memory budget for each policy condition,
+	// in allocation units: it grows with every element produced by ranges,
+	// maps, filters and repeat. It bounds what a condition may allocate, not
+	// the number of operations it runs. Zero uses expr's default.
+	MaxConditionMemory uint
+	// MaxConditionOperations bounds the operations of each policy
+	// condition: every iteration of all, any, filter, map and the other
+	// predicates counts the expression nodes of its predicate. Zero means no
+	// limit.
+	MaxConditionOperations int
+	// FailOpen allows execution when a validation times out. By default
+	// timed-out calls are rejected (fail-closed).
+	FailOpen bool
+}
+
+// WithEvaluationBudget sets the evaluation budget for every validation.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithEvaluationBudget(EvaluationBudget{Timeout: 50 * time.Millisecond}))
+func WithEvaluationBudget(b EvaluationBudget) GuardOption {
+	return func(g *Guard) {
+		g.budget = b
+	}
+}
+
 // New creates a new Guard instance with optional configuration.
 //
 // Example:
@@ -136,13 +248,35 @@ func New(opts ...GuardOption) *Guard {
 	g := &Guard{
 		schemaLoader: defaultSchemaLoader{},
 		policyEngine: defaultPolicyEngine{},
+		sessions:     session.NewMemoryStore(session.DefaultTTL, session.DefaultMaxCalls),
+		approvals:    approval.NewMemoryStore(),
+		approvalTTL:  DefaultApprovalTTL,
+		results:      newTrackedCalls(),
+		quotas:       quota.NewMemoryStore(),
 	}
 	for _, opt := range opts {
 		opt(g)
 	}
+	if d, ok := g.policyEngine.(defaultPolicyEngine); ok {
+		d.ext = g.extensions
+		g.policyEngine = d
+	}
+	if d, ok := g.schemaLoader.(defaultSchemaLoader); ok {
+		d.ext = g.extensions
+		g.schemaLoader = d
+	}
 	return g
 }
 
+// ext returns the Guard's extensions table, creating it. Only options may
+// call it.
+func (g *Guard) ext() *policy.Extensions {
+	if g.extensions == nil {
+		g.extensions = policy.NewExtensions()
+	}
+	return g.extensions
+}
+
 // LoadSchemasFromFile loads tool schemas from a YAML file using the configured loader.
 //
 // Example:
@@ -151,6 +285,7 @@ func New(opts ...GuardOption) *Guard {
 func (g *Guard) LoadSchemasFromFile(ctx context.Context, path string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	defer g.decisions.clear()
 	if err := g.schemaLoader.LoadSchemas(ctx, path); err != nil {
 		return fmt.Errorf("failed to load schemas from %s: %w", path, err)
 	}
@@ -165,6 +300,7 @@ func (g *Guard) LoadSchemasFromFile(ctx context.Context, path string) error {
 func (g *Guard) LoadPoliciesFromFile(ctx context.Context, path string) error {
 	g.mu.Lock()
 	defer g.mu.Unlock()
+	defer g.decisions.clear()
 	if err := g.policyEngine.LoadPolicies(ctx, path); err != nil {
 		return fmt.Errorf("failed to load policies from %s: %w", path, err)
 	}
@@ -173,43 +309,65 @@ func (g *Guard) LoadPoliciesFromFile(ctx context.Context, path string) error {
 
 // ValidateToolCall validates a tool call using loaded schemas and policies.
 //
+// Validation stops when ctx is done or the Guard's EvaluationBudget is
+// exhausted; the result then has Status "timeout" and PolicyAction TIMEOUT.
+//
 // Example:
 //
 //	result := guard.ValidateToolCall(ctx, ToolCall{Name: "weather", Parameters: map[string]interface{}{ "city": "London" }})
 func (g *Guard) ValidateToolCall(ctx context.Context, tc ToolCall) ValidationResult {
 	g.mu.RLock()
 	defer g.mu.RUnlock()
+	start := time.Now()
 
-	// Generate ID if not provided
-	callID := fmt.Sprintf("call_%d", time.Now().UnixNano())
-
-	// Convert public context to internal context
-	var internalContext model.CallContext
-	if tc.Context != nil {
-		internalContext = model.CallContext{
-			UserID:          tc.Context.UserID,
-			UserRole:        tc.Context.UserRole,
-			SessionID:       tc.Context.SessionID,
-			ConversationID:  tc.Context.ConversationID,
-			PreviousCalls:   tc.Context.PreviousCalls,
-			UserPermissions: tc.Context.UserPermissions,
-			IPAddress:       tc.Context.IPAddress,
-			TimeOfDay:       tc.Context.TimeOfDay,
-			Metadata:        tc.Context.Metadata,
-		}
+	if g.budget.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, g.budget.Timeout)
+		defer cancel()
 	}
 
+	ctx, span := trace.Start(ctx, g.tracer, trace.SpanValidate)
+	defer span.End()
+	span.SetAttribute(trace.AttrToolName, tc.Name)
+
+	callID := newCallID()
+
 	// Convert to internal model
 	internalCall := model.ToolCall{
 		ID:         callID,
 		Name:       tc.Name,
 		Parameters: tc.Parameters,
-		Context:    internalContext,
-		Timestamp:  time.Now(),
+		Context:    toInternalContext(tc.Context),
+		Timestamp:  start,
 	}
 
 	// Validate using internal logic
-	result := schema.ValidateAndPolicy(internalCall)
+	var result model.ValidationResult
+	if err := g.loadSessionHistory(ctx, &internalCall.Context); err != nil {
+		result = model.ValidationResult{
+			ToolCallID:       callID,
+			Status:           "rejected",
+			Reason:           fmt.Sprintf("Session history unavailable: %v", err),
+			ExecutionAllowed: false,
+			PolicyAction:     string(policy.PolicyReject),
+			Origin:           model.OriginError,
+		}
+	} else if key, cacheable := g.decisionKey(internalCall); !cacheable {
+		result = schema.ValidateAndPolicyWithOptions(ctx, internalCall, g.validateOptions(internalCall))
+	} else if cached, ok := g.cachedDecision(key, internalCall); ok {
+		result = cached
+		span.SetAttribute(trace.AttrDecisionCached, true)
+	} else {
+		result = schema.ValidateAndPolicyWithOptions(ctx, internalCall, g.validateOptions(internalCall))
+		g.cacheDecision(key, result)
+	}
+
+	result = g.checkRewrittenBudget(ctx, internalCall, result)
+
+	var approvalToken string
+	if result.Status == "pending" {
+		approvalToken, result = g.parkForApproval(ctx, internalCall, result)
+	}
 
 	// Convert back to public type
 	validationResult := ValidationResult{
@@ -219,42 +377,131 @@ func (g *Guard) ValidateToolCall(ctx context.Context, tc ToolCall) ValidationRes
 		ToolCallID:       result.ToolCallID,
 		Status:           result.Status,
 		Confidence:       result.Confidence,
+		ApprovalToken:    approvalToken,
 	}
 
 	if result.SuggestedCorrection != nil {
-		publicContext := &CallContext{
-			UserID:          result.SuggestedCorrection.Context.UserID,
-			UserRole:        result.SuggestedCorrection.Context.UserRole,
-			SessionID:       result.SuggestedCorrection.Context.SessionID,
-			ConversationID:  result.SuggestedCorrection.Context.ConversationID,
-			PreviousCalls:   result.SuggestedCorrection.Context.PreviousCalls,
-			UserPermissions: result.SuggestedCorrection.Context.UserPermissions,
-			IPAddress:       result.SuggestedCorrection.Context.IPAddress,
-			TimeOfDay:       result.SuggestedCorrection.Context.TimeOfDay,
-			Metadata:        result.SuggestedCorrection.Context.Metadata,
+		correctionParams := result.SuggestedCorrection.Parameters
+		if g.redactCorrections {
+			correctionParams = g.redactParameters(internalCall.Context.TenantID, result.SuggestedCorrection.Name, correctionParams)
 		}
 		validationResult.SuggestedCorrection = &ToolCall{
 			Name:       result.SuggestedCorrection.Name,
-			Parameters: result.SuggestedCorrection.Parameters,
-			Context:    publicContext,
+			Parameters: correctionParams,
+			Context:    toPublicContext(result.SuggestedCorrection.Context),
 		}
 	}
 
+	span.SetAttribute(trace.AttrDecisionStatus, result.Status)
+	span.SetAttribute(trace.AttrDecisionAction, result.PolicyAction)
+
+	g.recordSessionCall(ctx, internalCall, result)
+	g.trackCall(internalCall, result)
+	g.recordUsage(ctx, internalCall, result)
+	g.audit(internalCall, result)
+	g.record(internalCall, result)
+	g.observeDecision(internalCall.Context.TenantID, tc.Name, validationResult, time.Since(start))
+
 	return validationResult
 }
 
+// toInternalContext converts a public call context to the internal model.
+func toInternalContext(cc *CallContext) model.CallContext {
+	if cc == nil {
+		return model.CallContext{}
+	}
+	return model.CallContext{
+		UserID:          cc.UserID,
+		TenantID:        cc.TenantID,
+		UserRole:        cc.UserRole,
+		SessionID:       cc.SessionID,
+		ConversationID:  cc.ConversationID,
+		PreviousCalls:   cc.PreviousCalls,
+		UserPermissions: cc.UserPermissions,
+		IPAddress:       cc.IPAddress,
+		TimeOfDay:       cc.TimeOfDay,
+		Metadata:        cc.Metadata,
+	}
+}
+
+// toPublicContext converts an internal call context to the public type.
+func toPublicContext(cc model.CallContext) *CallContext {
+	return &CallContext{
+		UserID:          cc.UserID,
+		TenantID:        cc.TenantID,
+		UserRole:        cc.UserRole,
+		SessionID:       cc.SessionID,
+		ConversationID:  cc.ConversationID,
+		PreviousCalls:   cc.PreviousCalls,
+		UserPermissions: cc.UserPermissions,
+		IPAddress:       cc.IPAddress,
+		TimeOfDay:       cc.TimeOfDay,
+		Metadata:        cc.Metadata,
+	}
+}
+
+// validateOptions returns the evaluation hooks for a single validation.
+func (g *Guard) validateOptions(tc model.ToolCall) schema.Options {
+	tenantID := tc.Context.TenantID
+	var opts schema.Options
+	if b := g.bundle(tenantID); b != nil {
+		opts.Schemas = b.schemas
+		opts.Policy.Set = b.policies
+	}
+	opts.Policy.Tracer = g.tracer
+	opts.Policy.Logger = g.logger(tc)
+	opts.Policy.MaxConditionMemory = g.budget.MaxConditionMemory
+	opts.Policy.MaxConditionOperations = g.budget.MaxConditionOperations
+	opts.Policy.Combining = g.combining
+	opts.Policy.Extensions = g.extensions
+	opts.FailOpen = g.budget.FailOpen
+	if g.quotas != nil {
+		opts.Policy.Budget = budgetChecker{g: g}
+	}
+	if g.metrics != nil {
+		tenant := g.tenantLabel(tenantID)
+		opts.Policy.Observer = metricsObserver{m: g.metrics, tenant: tenant}
+		opts.OnFuzzyMatch = func(input, suggestion string) {
+			g.metrics.FuzzyCorrection(tenant, suggestion)
+		}
+	}
+	return opts
+}
+
 // defaultSchemaLoader is the default implementation using the internal schema package.
 // Implements SchemaLoader.
-type defaultSchemaLoader struct{}
+// Cost expressions may use the functions and providers of ext.
+type defaultSchemaLoader struct {
+	ext *policy.Extensions
+}
 
 func (d defaultSchemaLoader) LoadSchemas(ctx context.Context, path string) error {
-	return schema.LoadSchemasFromYAML(path)
+	return schema.LoadSchemasWithExtensions(path, d.ext)
 }
 
 // defaultPolicyEngine is the default implementation using the internal policy package.
 // Implements PolicyEngine.
-type defaultPolicyEngine struct{}
+// An empty env applies the overlay named by HGUARD_ENV, if any. Conditions
+// may use the functions and providers of ext.
+type defaultPolicyEngine struct {
+	env string
+	ext *policy.Extensions
+}
 
 func (d defaultPolicyEngine) LoadPolicies(ctx context.Context, path string) error {
-	return policy.LoadPoliciesFromYAML(path)
+	env := d.env
+	if env == "" {
+		env = os.Getenv(policy.EnvVar)
+	}
+	return policy.LoadPoliciesWithExtensions(path, env, d.ext)
+}
+
+// newCallID returns a random tool call ID. IDs key the result tracker and
+// session history, so they must stay unique across concurrent calls.
+func newCallID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic("hallucinationguard: crypto/rand failed: " + err.Error())
+	}
+	return "call_" + hex.EncodeToString(b[:])
 }
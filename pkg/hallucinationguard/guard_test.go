@@ -0,0 +1,703 @@
+package hallucinationguard
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+	"github.com/SafellmHub/hguard-go/pkg/internal/logging"
+)
+
+const testSchemas = `
+schemas:
+  - name: gt_transfer
+    cost: "params.amount"
+    parameters:
+      amount: {type: number, required: true}
+  - name: gt_lookup
+    parameters:
+      amount: {type: number, required: true}
+  - name: gt_search
+    parameters:
+      query: {type: string, required: true}
+  - name: gt_email
+    parameters:
+      to: {type: string, required: true}
+      body: {type: string, required: true, sensitive: true}
+  - name: gt_mail
+    parameters:
+      to: {type: string, required: true}
+      body: {type: string, required: true, sensitive: true}
+`
+
+// guardSetup is the YAML a test Guard loads: schemas, testSchemas when
+// empty, and policies, none when empty.
+type guardSetup struct {
+	schemas  string
+	policies string
+}
+
+// guard returns a Guard built with opts that has loaded the setup.
+func (s guardSetup) guard(t *testing.T, opts ...GuardOption) *Guard {
+	t.Helper()
+	if s.schemas == "" {
+		s.schemas = testSchemas
+	}
+	if s.policies == "" {
+		s.policies = "policies: []\n"
+	}
+	guard := New(opts...)
+	ctx := context.Background()
+	if err := guard.LoadSchemasFromFile(ctx, writeTestFile(t, "schemas.yaml", s.schemas)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := guard.LoadPoliciesFromFile(ctx, writeTestFile(t, "policies.yaml", s.policies)); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return guard
+}
+
+// newTestGuard returns a Guard built with opts that has loaded testSchemas
+// and the given policies.
+func newTestGuard(t *testing.T, policies string, opts ...GuardOption) *Guard {
+	t.Helper()
+	return guardSetup{policies: policies}.guard(t, opts...)
+}
+
+func writeTestFile(t testing.TB, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestResumeApproved(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log bytes.Buffer
+	store := NewMemoryApprovalStore()
+	setup := guardSetup{policies: `
+policies:
+  - tool_name: gt_transfer
+    type: REQUIRE_APPROVAL
+    condition: "params.amount > 100"
+    priority: 10
+  - tool_name: gt_transfer
+    type: BUDGET
+    limit: 250
+    priority: 100
+`}
+	guard := setup.guard(t, WithApprovalStore(store, time.Hour), WithAuditLog(&log, key, 0))
+	ctx := context.Background()
+
+	park := func() string {
+		result := guard.ValidateToolCall(ctx, ToolCall{
+			Name:       "gt_transfer",
+			Parameters: map[string]interface{}{"amount": 200},
+			Context:    &CallContext{UserID: "alice"},
+		})
+		if result.Status != "pending" || result.ApprovalToken == "" {
+			t.Fatalf("Expected a pending call with a token, got %+v", result)
+		}
+		return result.ApprovalToken
+	}
+	first, second := park(), park()
+
+	if err := guard.Approve(ctx, first, "alice"); !errors.Is(err, ErrApprovalSelfApproval) {
+		t.Errorf("Expected ErrApprovalSelfApproval, got %v", err)
+	}
+	if err := guard.Approve(ctx, first, ""); !errors.Is(err, ErrApprovalNoApprover) {
+		t.Errorf("Expected ErrApprovalNoApprover, got %v", err)
+	}
+	for _, token := range []string{first, second} {
+		if err := guard.Approve(ctx, token, "bob"); err != nil {
+			t.Fatalf("Approve failed: %v", err)
+		}
+	}
+	if n := strings.Count(log.String(), "Approved by bob"); n != 2 {
+		t.Errorf("Expected both approvals to be audited, got %d records", n)
+	}
+
+	tc, err := guard.ResumeApproved(ctx, first)
+	if err != nil || tc.Name != "gt_transfer" || tc.Context.UserID != "alice" {
+		t.Fatalf("Expected the approved call, got %+v (%v)", tc, err)
+	}
+	if _, err := guard.ResumeApproved(ctx, first); !errors.Is(err, ErrApprovalNotApproved) {
+		t.Errorf("Expected a second resume to fail, got %v", err)
+	}
+	if usage, _ := guard.Usage(ctx, "alice"); usage.Daily != 200 {
+		t.Errorf("Expected the resumed call to be charged, got %+v", usage)
+	}
+
+	// The second call no longer fits the budget, and stays approved.
+	if _, err := guard.ResumeApproved(ctx, second); !errors.Is(err, ErrApprovalBudgetExceeded) {
+		t.Errorf("Expected ErrApprovalBudgetExceeded, got %v", err)
+	}
+	if req, err := store.Get(ctx, second); err != nil || req.Status != ApprovalApproved {
+		t.Errorf("Expected the request to stay approved, got %+v (%v)", req, err)
+	}
+}
+
+func TestConcurrentCallIDs(t *testing.T) {
+	guard := newTestGuard(t, "policies: []\n")
+	ctx := context.Background()
+	const calls = 64
+	ids := make(chan string, calls)
+	var wg sync.WaitGroup
+	for i := 0; i < calls; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result := guard.ValidateToolCall(ctx, ToolCall{Name: "gt_search", Parameters: map[string]interface{}{"query": "go"}})
+			ids <- result.ToolCallID
+		}()
+	}
+	wg.Wait()
+	close(ids)
+
+	seen := map[string]bool{}
+	for id := range ids {
+		if seen[id] {
+			t.Fatalf("Duplicate call ID %q", id)
+		}
+		seen[id] = true
+		if rv := guard.ValidateToolResult(ctx, id, map[string]interface{}{}); rv.ToolName != "gt_search" {
+			t.Errorf("Expected call %q to be tracked, got %+v", id, rv)
+		}
+	}
+}
+
+func TestDecisionCache(t *testing.T) {
+	setup := guardSetup{policies: `
+policies:
+  - tool_name: gt_search
+    type: REJECT
+    condition: "user.role == 'guest'"
+`}
+	rejectAll := writeTestFile(t, "reject.yaml", `
+policies:
+  - tool_name: gt_search
+    type: REJECT
+`)
+	guard := setup.guard(t, WithDecisionCache(time.Minute, 0))
+	ctx := context.Background()
+	call := ToolCall{Name: "gt_search", Parameters: map[string]interface{}{"query": "go"}, Context: &CallContext{UserRole: "user"}}
+
+	steps := []struct {
+		name    string
+		before  func() error
+		allowed bool
+	}{
+		{"first call", nil, true},
+		// Policies changed behind the Guard's back are not seen until the
+		// cache is cleared.
+		{"policies changed outside the Guard", func() error { return policy.LoadPoliciesFromYAML(rejectAll) }, true},
+		{"cache cleared", func() error { guard.ClearDecisionCache(); return nil }, false},
+		// Loading policies through the Guard drops the cached rejection.
+		{"policies reloaded through the Guard", func() error {
+			return guard.LoadPoliciesFromFile(ctx, writeTestFile(t, "policies.yaml", setup.policies))
+		}, true},
+	}
+	for _, step := range steps {
+		if step.before != nil {
+			if err := step.before(); err != nil {
+				t.Fatalf("%s: %v", step.name, err)
+			}
+		}
+		if result := guard.ValidateToolCall(ctx, call); result.ExecutionAllowed != step.allowed {
+			t.Errorf("%s: expected allowed=%v, got %+v", step.name, step.allowed, result)
+		}
+	}
+}
+
+func TestQuotaRecording(t *testing.T) {
+	setup := guardSetup{policies: `
+policies:
+  - tool_name: gt_lookup
+    type: REWRITE
+    target: gt_transfer
+    priority: 10
+  - tool_name: gt_transfer
+    type: BUDGET
+    limit: 100
+    priority: 100
+`}
+	guard := setup.guard(t)
+	ctx := context.Background()
+	acme := &CallContext{UserID: "carol", TenantID: "acme"}
+	// The tenant_id metadata identifies the tenant for user budgets too.
+	viaMetadata := &CallContext{UserID: "carol", Metadata: map[string]interface{}{"tenant_id": "acme"}}
+
+	calls := []struct {
+		name    string
+		tool    string
+		amount  float64
+		cc      *CallContext
+		allowed bool
+		check   func(ValidationResult) bool
+		usage   float64
+	}{
+		{"transfer", "gt_transfer", 40, acme, true, nil, 40},
+		// Rewritten calls are charged as the tool they run as.
+		{"rewritten lookup", "gt_lookup", 30, viaMetadata, true,
+			func(r ValidationResult) bool { return r.Status == "rewritten" }, 70},
+		{"rewrite over budget", "gt_lookup", 50, viaMetadata, false,
+			func(r ValidationResult) bool { return r.PolicyAction == PolicyActionBUDGET }, 70},
+		{"transfer over budget", "gt_transfer", 50, acme, false,
+			func(r ValidationResult) bool { return r.PolicyAction == PolicyActionBUDGET }, 70},
+		// Negative and non-finite costs are budget errors and never charged.
+		{"negative cost", "gt_transfer", -50, acme, false, invalidCost, 70},
+		{"NaN cost", "gt_transfer", math.NaN(), acme, false, invalidCost, 70},
+		{"infinite cost", "gt_transfer", math.Inf(1), acme, false, invalidCost, 70},
+	}
+	for _, c := range calls {
+		result := guard.ValidateToolCall(ctx, ToolCall{Name: c.tool, Parameters: map[string]interface{}{"amount": c.amount}, Context: c.cc})
+		if result.ExecutionAllowed != c.allowed || (c.check != nil && !c.check(result)) {
+			t.Errorf("%s: unexpected result %+v", c.name, result)
+		}
+		u, err := guard.TenantUsage(ctx, "acme", "carol")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.Daily != c.usage {
+			t.Errorf("%s: expected %v charged, got %v", c.name, c.usage, u.Daily)
+		}
+	}
+}
+
+func invalidCost(r ValidationResult) bool { return strings.Contains(r.Error, "invalid cost") }
+
+func TestCostUsesGuardExtensions(t *testing.T) {
+	schemas := `
+schemas:
+  - name: gt_fee
+    cost: "fee(params.amount)"
+    parameters:
+      amount: {type: number, required: true}
+`
+	ctx := context.Background()
+	if err := New().LoadSchemasFromFile(ctx, writeTestFile(t, "schemas.yaml", schemas)); err == nil {
+		t.Error("Expected a Guard without the function to reject the cost")
+	}
+	guard := guardSetup{schemas: schemas}.guard(t, WithConditionFunction("fee", func(amount float64) float64 { return amount * 2 }))
+	call := ToolCall{Name: "gt_fee", Parameters: map[string]interface{}{"amount": 5.0}, Context: &CallContext{UserID: "carol"}}
+	if result := guard.ValidateToolCall(ctx, call); !result.ExecutionAllowed {
+		t.Fatalf("Expected the call to be allowed, got %+v", result)
+	}
+	if u, err := guard.Usage(ctx, "carol"); err != nil || u.Daily != 10 {
+		t.Errorf("Expected a charge of 10, got %+v (%v)", u, err)
+	}
+}
+
+func TestEvaluationBudgetOperations(t *testing.T) {
+	policies := `
+policies:
+  - tool_name: gt_search
+    type: REJECT
+    condition: "count(1..100000, # > 0) > 0"
+`
+	ctx := context.Background()
+	call := ToolCall{Name: "gt_search", Parameters: map[string]interface{}{"query": "go"}}
+
+	guard := newTestGuard(t, policies, WithEvaluationBudget(EvaluationBudget{MaxConditionOperations: 1000}))
+	if result := guard.ValidateToolCall(ctx, call); result.ExecutionAllowed || result.PolicyAction != PolicyActionTIMEOUT {
+		t.Errorf("Expected the operation budget to time out, got %+v", result)
+	}
+	guard = newTestGuard(t, policies, WithEvaluationBudget(EvaluationBudget{MaxConditionOperations: 1000, FailOpen: true}))
+	if result := guard.ValidateToolCall(ctx, call); !result.ExecutionAllowed || result.Status != "timeout" {
+		t.Errorf("Expected fail-open to allow the call, got %+v", result)
+	}
+
+	// Cost expressions run under the same budget.
+	guard = newTestGuard(t, "policies:\n  - tool_name: gt_heavy\n    type: BUDGET\n    limit: 100\n", WithEvaluationBudget(EvaluationBudget{MaxConditionOperations: 1000}))
+	heavy := "schemas:\n  - name: gt_heavy\n    cost: \"count(1..100000, # > 0) * 0.01\"\n"
+	if err := guard.LoadSchemasFromFile(ctx, writeTestFile(t, "heavy.yaml", heavy)); err != nil {
+		t.Fatal(err)
+	}
+	call = ToolCall{Name: "gt_heavy", Parameters: map[string]interface{}{}, Context: &CallContext{UserID: "carol"}}
+	if result := guard.ValidateToolCall(ctx, call); result.ExecutionAllowed || result.PolicyAction != PolicyActionTIMEOUT {
+		t.Errorf("Expected the cost's operation budget to time out, got %+v", result)
+	}
+}
+
+func TestRecorderSamplingAndRedaction(t *testing.T) {
+	ctx := context.Background()
+	metadata := map[string]interface{}{"trace": "trace-123", "plan": "pro"}
+	email := ToolCall{
+		Name:       "gt_email",
+		Parameters: map[string]interface{}{"to": "eve@example.com", "body": "the secret plan"},
+		Context:    &CallContext{UserID: "eve", Metadata: metadata},
+	}
+	search := ToolCall{Name: "gt_search", Parameters: map[string]interface{}{"query": "skipped"}}
+
+	cases := []struct {
+		name     string
+		options  func(w *bytes.Buffer) []GuardOption
+		calls    []ToolCall
+		recorded int
+		leaked   []string
+		kept     []string
+	}{
+		{
+			name: "redacted",
+			options: func(w *bytes.Buffer) []GuardOption {
+				return []GuardOption{WithRedaction(RedactionConfig{}), WithRecorder(w, RecorderOptions{Redact: func(call *ToolCall) bool {
+					delete(call.Context.Metadata, "trace")
+					return call.Name != "gt_search"
+				}})}
+			},
+			calls:    []ToolCall{email, search},
+			recorded: 1,
+			leaked:   []string{"the secret plan", "eve@example.com", "trace-123", "skipped"},
+			kept:     []string{"pro"},
+		},
+		{
+			name: "sampled",
+			options: func(w *bytes.Buffer) []GuardOption {
+				return []GuardOption{WithRecorder(w, RecorderOptions{SampleRate: 1e-9})}
+			},
+			calls: func() []ToolCall {
+				calls := make([]ToolCall, 100)
+				for i := range calls {
+					calls[i] = search
+				}
+				return calls
+			}(),
+		},
+	}
+	for _, c := range cases {
+		var recording bytes.Buffer
+		guard := guardSetup{}.guard(t, c.options(&recording)...)
+		for _, call := range c.calls {
+			guard.ValidateToolCall(ctx, call)
+		}
+		out := recording.String()
+		if n := strings.Count(out, "\n"); n != c.recorded {
+			t.Errorf("%s: expected %d recorded calls, got %q", c.name, c.recorded, out)
+		}
+		for _, leaked := range c.leaked {
+			if strings.Contains(out, leaked) {
+				t.Errorf("%s: expected %q to be kept out of the recording: %s", c.name, leaked, out)
+			}
+		}
+		for _, kept := range c.kept {
+			if !strings.Contains(out, kept) {
+				t.Errorf("%s: expected %q to be recorded: %s", c.name, kept, out)
+			}
+		}
+	}
+	if metadata["trace"] != "trace-123" {
+		t.Errorf("Expected the caller's metadata to be left alone, got %v", metadata)
+	}
+}
+
+func TestReplaySkipsBudgetDecisions(t *testing.T) {
+	var recording bytes.Buffer
+	guard := newTestGuard(t, `
+policies:
+  - tool_name: gt_transfer
+    type: BUDGET
+    limit: 100
+`, WithRecorder(&recording, RecorderOptions{}))
+	ctx := context.Background()
+	for _, amount := range []int{60, 60} {
+		guard.ValidateToolCall(ctx, ToolCall{Name: "gt_transfer", Parameters: map[string]interface{}{"amount": amount}, Context: &CallContext{UserID: "dan"}})
+	}
+	if !strings.Contains(recording.String(), `"origin":"budget"`) {
+		t.Fatalf("Expected the budget rejection to be tagged: %s", recording.String())
+	}
+
+	report, err := guard.ReplayRecording(ctx, &recording)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Calls != 1 || report.Skipped != 1 || report.Changed != 0 {
+		t.Errorf("Expected the budget rejection to be skipped, got %+v", report)
+	}
+}
+
+func TestWithRedaction(t *testing.T) {
+	_, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	setup := guardSetup{policies: `
+policies:
+  - tool_name: gt_mail
+    type: REWRITE
+    target: gt_email
+`}
+	call := ToolCall{Name: "gt_mail", Parameters: map[string]interface{}{"to": "eve@example.com", "body": "the secret plan"}}
+	ctx := context.Background()
+
+	// Corrections are only redacted when asked; audit records always are.
+	for _, c := range []struct {
+		name   string
+		config RedactionConfig
+		body   string
+	}{
+		{"corrections redacted", RedactionConfig{RedactCorrections: true}, "[REDACTED]"},
+		{"corrections kept", RedactionConfig{}, "the secret plan"},
+	} {
+		var log bytes.Buffer
+		guard := setup.guard(t, WithAuditLog(&log, key, 0), WithRedaction(c.config))
+		result := guard.ValidateToolCall(ctx, call)
+		if result.SuggestedCorrection == nil {
+			t.Fatalf("%s: expected a correction, got %+v", c.name, result)
+		}
+		params := result.SuggestedCorrection.Parameters
+		if params["body"] != c.body {
+			t.Errorf("%s: expected the corrected body %q, got %v", c.name, c.body, params["body"])
+		}
+		if c.config.RedactCorrections && strings.Contains(params["to"].(string), "eve@example.com") {
+			t.Errorf("%s: expected the corrected address to be redacted, got %v", c.name, params["to"])
+		}
+		if strings.Contains(log.String(), "the secret plan") || strings.Contains(log.String(), "eve@example.com") {
+			t.Errorf("%s: expected the audit record to be redacted: %s", c.name, log.String())
+		}
+	}
+	if call.Parameters["body"] != "the secret plan" {
+		t.Errorf("Expected the caller's parameters to be left alone, got %v", call.Parameters)
+	}
+}
+
+func TestWithRedactionLogs(t *testing.T) {
+	var out bytes.Buffer
+	logging.SetOutput(&out)
+	defer logging.SetOutput(os.Stdout)
+	setup := guardSetup{policies: `
+policies:
+  - tool_name: gt_email
+    type: REJECT
+    condition: "int(params.body) > 0"
+  - tool_name: gt_email
+    type: REJECT
+    condition: "int(params.to) > 0"
+`}
+	call := ToolCall{Name: "gt_email", Parameters: map[string]interface{}{"to": "eve@example.com", "body": "the secret plan"}}
+	ctx := context.Background()
+
+	// Each Guard's settings apply to its own log lines only.
+	for _, c := range []struct {
+		name   string
+		opts   []GuardOption
+		want   []string
+		leaked []string
+	}{
+		{"redacted", []GuardOption{WithRedaction(RedactionConfig{})}, []string{"[REDACTED]"}, []string{"the secret plan", "eve@example.com"}},
+		{"not redacted", nil, []string{"the secret plan"}, nil},
+	} {
+		out.Reset()
+		setup.guard(t, c.opts...).ValidateToolCall(ctx, call)
+		for _, want := range c.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%s: expected %q in the log: %s", c.name, want, out.String())
+			}
+		}
+		for _, leaked := range c.leaked {
+			if strings.Contains(out.String(), leaked) {
+				t.Errorf("%s: expected %q to be kept out of the log: %s", c.name, leaked, out.String())
+			}
+		}
+	}
+}
+
+func TestWithConditionFunction(t *testing.T) {
+	policies := `
+policies:
+  - tool_name: gt_search
+    type: REJECT
+    condition: "blocked(params.query)"
+`
+	strict := newTestGuard(t, policies, WithConditionFunction("blocked", func(q string) bool { return q != "" }))
+	lenient := newTestGuard(t, policies, WithConditionFunction("blocked", func(q string) bool { return q == "rm -rf" }))
+	ctx := context.Background()
+	search := func(guard *Guard, query string) bool {
+		return guard.ValidateToolCall(ctx, ToolCall{Name: "gt_search", Parameters: map[string]interface{}{"query": query}}).ExecutionAllowed
+	}
+
+	if search(strict, "go") {
+		t.Error("Expected the strict Guard's function to block the query")
+	}
+	if !search(lenient, "go") || search(lenient, "rm -rf") {
+		t.Error("Expected the lenient Guard to use its own function")
+	}
+	if err := New().LoadPoliciesFromFile(ctx, writeTestFile(t, "policies.yaml", policies)); err == nil {
+		t.Error("Expected a Guard without the function to reject the policies")
+	}
+	if err := strict.CheckCondition("blocked('x')"); err != nil {
+		t.Errorf("Expected the Guard's function to be known, got %v", err)
+	}
+}
+
+// tierProvider serves account.tier with a fixed value.
+type tierProvider string
+
+func (p tierProvider) Attributes() []string { return []string{"account.tier"} }
+
+func (p tierProvider) Resolve(ctx context.Context, path string, call ToolCall) (interface{}, error) {
+	return string(p), nil
+}
+
+func TestWithAttributeProvider(t *testing.T) {
+	policies := `
+policies:
+  - tool_name: gt_search
+    type: REJECT
+    condition: "account.tier == 'frozen'"
+`
+	gold := newTestGuard(t, policies, WithAttributeProvider(tierProvider("gold")), WithDecisionCache(time.Minute, 0))
+	frozen := newTestGuard(t, policies, WithAttributeProvider(tierProvider("frozen")), WithDecisionCache(time.Minute, 0))
+	ctx := context.Background()
+	call := ToolCall{Name: "gt_search", Parameters: map[string]interface{}{"query": "go"}}
+
+	for i := 0; i < 2; i++ {
+		if !gold.ValidateToolCall(ctx, call).ExecutionAllowed {
+			t.Error("Expected the gold Guard's provider to allow the call")
+		}
+		if frozen.ValidateToolCall(ctx, call).ExecutionAllowed {
+			t.Error("Expected the frozen Guard's provider to reject the call")
+		}
+	}
+	if err := New().LoadPoliciesFromFile(ctx, writeTestFile(t, "policies.yaml", policies)); err == nil {
+		t.Error("Expected a Guard without the provider to reject the policies")
+	}
+}
+
+func TestReloadTenantRejectsPaths(t *testing.T) {
+	root := t.TempDir()
+	tenants := filepath.Join(root, "tenants")
+	for _, dir := range []string{filepath.Join(tenants, "acme"), filepath.Join(root, "outside")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, TenantPoliciesFile), []byte("policies: []\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	guard := New()
+	ctx := context.Background()
+	if err := guard.LoadTenants(ctx, tenants); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []string{"", ".", "..", "../outside", "x/acme", `x\acme`} {
+		if err := guard.ReloadTenant(ctx, id); err == nil {
+			t.Errorf("Expected tenant ID %q to be rejected", id)
+		}
+	}
+	if err := guard.ReloadTenant(ctx, "acme"); err != nil {
+		t.Errorf("Expected acme to reload, got %v", err)
+	}
+	if got := guard.Tenants(); len(got) != 1 || got[0] != "acme" {
+		t.Errorf("Expected only acme to be loaded, got %v", got)
+	}
+}
+
+func TestMetricsTenantLabel(t *testing.T) {
+	tenants := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tenants, "acme"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	acmePolicies := "policies:\n  - tool_name: gt_search\n    type: REJECT\n    condition: \"params.query == 'secret'\"\n"
+	if err := os.WriteFile(filepath.Join(tenants, "acme", TenantPoliciesFile), []byte(acmePolicies), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	reg := NewMetricsRegistry()
+	guard := newTestGuard(t, "policies: []\n", WithMetrics(reg))
+	ctx := context.Background()
+	if err := guard.LoadTenants(ctx, tenants); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tenant := range []string{"acme", "", "no-such-tenant"} {
+		guard.ValidateToolCall(ctx, ToolCall{
+			Name:       "gt_search",
+			Parameters: map[string]interface{}{"query": "secret"},
+			Context:    &CallContext{UserID: "user123", TenantID: tenant},
+		})
+	}
+
+	var out bytes.Buffer
+	if err := reg.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+	for _, want := range []string{
+		`hguard_decisions_total{tenant="acme",tool="gt_search",action="REJECT",status="rejected"} 1`,
+		// Unknown tenants see the global base and are counted with it.
+		`hguard_decisions_total{tenant="",tool="gt_search",action="ALLOW",status="approved"} 2`,
+		`hguard_expr_cache_misses_total{tenant="acme"} 1`,
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("Expected %s in:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "no-such-tenant") {
+		t.Errorf("Expected unknown tenants not to get their own label:\n%s", text)
+	}
+}
+
+func TestTenantRoles(t *testing.T) {
+	guard := newTestGuard(t, "roles:\n  user:\n    permissions: [email_send]\npolicies: []\n")
+	tenants := t.TempDir()
+	writeTenantFile(t, tenants, "acme", TenantPoliciesFile, "roles:\n  auditor:\n    inherits: [user]\n    permissions: [audit_read]\npolicies: []\n")
+	if err := guard.LoadTenants(context.Background(), tenants); err != nil {
+		t.Fatal(err)
+	}
+
+	acme := &CallContext{TenantID: "acme", UserRole: "auditor", UserPermissions: []string{"export"}}
+	if !guard.HasRole(acme, "user") {
+		t.Error("Expected the tenant's auditor to inherit user")
+	}
+	if got := strings.Join(guard.Permissions(acme), ","); got != "export,audit_read,email_send" {
+		t.Errorf("Expected the tenant's grants after the extra ones, got %s", got)
+	}
+	global := &CallContext{UserRole: "auditor"}
+	if guard.HasRole(global, "user") || len(guard.Permissions(global)) != 0 {
+		t.Error("Expected tenant roles to stay inside the tenant")
+	}
+}
+
+// writeTenantFile writes a file of a tenant bundle under dir.
+func writeTenantFile(t *testing.T, dir, tenantID, name, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Join(dir, tenantID), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, tenantID, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConditionErrorMetricsSelector(t *testing.T) {
+	reg := NewMetricsRegistry()
+	guard := newTestGuard(t, `
+policies:
+  - tool_names: [gt_search, gt_lookup]
+    type: REJECT
+    condition: "int(params.query) > 0"
+`, WithMetrics(reg))
+	guard.ValidateToolCall(context.Background(), ToolCall{Name: "gt_search", Parameters: map[string]interface{}{"query": "go"}})
+
+	var out bytes.Buffer
+	if err := reg.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	if want := `hguard_condition_errors_total{tenant="",tool="gt_search,gt_lookup"} 1`; !strings.Contains(out.String(), want) {
+		t.Errorf("Expected %s in:\n%s", want, out.String())
+	}
+}
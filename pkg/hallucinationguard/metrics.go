@@ -0,0 +1,145 @@
+package hallucinationguard
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+	"github.com/SafellmHub/hguard-go/pkg/internal/metrics"
+)
+
+// Metrics receives instrumentation events from a Guard. Implement it to bridge
+// to your own metrics system, or use NewMetricsRegistry for a built-in
+// registry that renders the Prometheus text format. Implementations must be
+// safe for concurrent use.
+//
+// Every event carries the tenant whose bundle the call was validated against,
+// or "" for calls that saw only the global schemas and policies.
+type Metrics interface {
+	// ObserveDecision is called once per ValidateToolCall with the tool name
+	// (or "unknown" for tools without a schema), the resulting policy action
+	// and status, and the total evaluation latency.
+	ObserveDecision(tenant, tool, action, status string, latency time.Duration)
+	// FuzzyCorrection is called when an unknown tool name is corrected.
+	FuzzyCorrection(tenant, suggestion string)
+	// ConditionError is called when a policy condition fails to evaluate,
+	// with the tools the policy targets: its tool name, tool_names and tags
+	// joined by commas, such as "send_*,tag=payments".
+	ConditionError(tenant, selector string)
+	// ExprCacheLookup is called for every compiled expression cache lookup.
+	ExprCacheLookup(tenant string, hit bool)
+}
+
+// unknownToolLabel is used in place of tool names that have no schema, which
+// keeps label cardinality bounded when a model invents tool names.
+const unknownToolLabel = "unknown"
+
+// MetricsRegistry is an in-process Metrics implementation that can render the
+// Prometheus text exposition format.
+//
+// Example:
+//
+//	reg := hallucinationguard.NewMetricsRegistry()
+//	guard := hallucinationguard.New(hallucinationguard.WithMetrics(reg))
+//	http.Handle("/metrics", reg)
+type MetricsRegistry struct {
+	registry         *metrics.Registry
+	decisions        *metrics.CounterVec
+	latency          *metrics.HistogramVec
+	fuzzyCorrections *metrics.CounterVec
+	conditionErrors  *metrics.CounterVec
+	cacheHits        *metrics.CounterVec
+	cacheMisses      *metrics.CounterVec
+}
+
+// NewMetricsRegistry creates a registry with the Guard's metric families.
+func NewMetricsRegistry() *MetricsRegistry {
+	r := metrics.NewRegistry()
+	return &MetricsRegistry{
+		registry:         r,
+		decisions:        r.Counter("hguard_decisions_total", "Tool call decisions by tenant, tool, policy action and status.", "tenant", "tool", "action", "status"),
+		latency:          r.Histogram("hguard_evaluation_duration_seconds", "Time spent validating a tool call.", nil, "tenant", "tool"),
+		fuzzyCorrections: r.Counter("hguard_fuzzy_corrections_total", "Unknown tool names corrected by fuzzy matching, by tenant and suggested tool.", "tenant", "suggestion"),
+		conditionErrors:  r.Counter("hguard_condition_errors_total", "Policy conditions that failed to compile or evaluate, by tenant and the tools the policy targets.", "tenant", "tool"),
+		cacheHits:        r.Counter("hguard_expr_cache_hits_total", "Compiled expression cache hits by tenant.", "tenant"),
+		cacheMisses:      r.Counter("hguard_expr_cache_misses_total", "Compiled expression cache misses by tenant.", "tenant"),
+	}
+}
+
+// ObserveDecision implements Metrics.
+func (m *MetricsRegistry) ObserveDecision(tenant, tool, action, status string, latency time.Duration) {
+	m.decisions.Inc(tenant, tool, action, status)
+	m.latency.Observe(latency.Seconds(), tenant, tool)
+}
+
+// FuzzyCorrection implements Metrics.
+func (m *MetricsRegistry) FuzzyCorrection(tenant, suggestion string) {
+	m.fuzzyCorrections.Inc(tenant, suggestion)
+}
+
+// ConditionError implements Metrics.
+func (m *MetricsRegistry) ConditionError(tenant, selector string) {
+	m.conditionErrors.Inc(tenant, selector)
+}
+
+// ExprCacheLookup implements Metrics.
+func (m *MetricsRegistry) ExprCacheLookup(tenant string, hit bool) {
+	if hit {
+		m.cacheHits.Inc(tenant)
+	} else {
+		m.cacheMisses.Inc(tenant)
+	}
+}
+
+// WritePrometheus writes all metrics in the Prometheus text exposition format.
+func (m *MetricsRegistry) WritePrometheus(w io.Writer) error {
+	return m.registry.WriteText(w)
+}
+
+// ServeHTTP serves the metrics in the Prometheus text exposition format, so the
+// registry can be mounted directly as a /metrics handler.
+func (m *MetricsRegistry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := m.WritePrometheus(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// WithMetrics reports decisions, fuzzy corrections, condition errors, latency
+// and expression cache usage to m.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithMetrics(hallucinationguard.NewMetricsRegistry()))
+func WithMetrics(m Metrics) GuardOption {
+	return func(g *Guard) {
+		g.metrics = m
+	}
+}
+
+// metricsObserver adapts Metrics to the policy engine's Observer for one
+// tenant's evaluation.
+type metricsObserver struct {
+	m      Metrics
+	tenant string
+}
+
+func (o metricsObserver) ExprCacheLookup(hit bool) {
+	o.m.ExprCacheLookup(o.tenant, hit)
+}
+
+func (o metricsObserver) ConditionError(p policy.Policy, err error) {
+	o.m.ConditionError(o.tenant, p.Selector())
+}
+
+// observeDecision reports a completed validation to the configured Metrics.
+func (g *Guard) observeDecision(tenantID, toolName string, result ValidationResult, latency time.Duration) {
+	if g.metrics == nil {
+		return
+	}
+	if _, ok := g.schemasFor(tenantID).Get(toolName); !ok {
+		toolName = unknownToolLabel
+	}
+	g.metrics.ObserveDecision(g.tenantLabel(tenantID), toolName, result.PolicyAction, result.Status, latency)
+}
@@ -0,0 +1,217 @@
+package hallucinationguard
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+	"github.com/SafellmHub/hguard-go/pkg/internal/quota"
+)
+
+// QuotaStore holds per-user and per-tenant usage counters for BUDGET
+// policies. Implement it to share budgets across processes (e.g. with Redis
+// INCRBYFLOAT and EXPIREAT); the default is an in-memory store.
+//
+// Budgets are soft limits: a call is checked against the counters with Get
+// and charged with Add once allowed, so concurrent calls that each fit the
+// remaining budget can together overrun it by up to one call's cost each.
+type QuotaStore = quota.Store
+
+// NewMemoryQuotaStore creates an in-memory QuotaStore.
+func NewMemoryQuotaStore() QuotaStore {
+	return quota.NewMemoryStore()
+}
+
+// WithQuotaStore sets the store that tracks the cost of allowed calls. Pass
+// nil to disable usage tracking, in which case BUDGET policies are skipped.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithQuotaStore(myRedisQuotaStore))
+func WithQuotaStore(store QuotaStore) GuardOption {
+	return func(g *Guard) {
+		g.quotas = store
+	}
+}
+
+// Usage is the cost a user has consumed in the current UTC day and month.
+type Usage struct {
+	UserID   string  `json:"user_id"`
+	TenantID string  `json:"tenant_id,omitempty"`
+	Daily    float64 `json:"daily"`
+	Monthly  float64 `json:"monthly"`
+}
+
+// Usage reports a user's consumption for the current periods, outside any
+// tenant.
+//
+// Example:
+//
+//	usage, err := guard.Usage(ctx, "user123")
+func (g *Guard) Usage(ctx context.Context, userID string) (Usage, error) {
+	return g.TenantUsage(ctx, "", userID)
+}
+
+// TenantUsage reports a user's consumption within a tenant. Counters of the
+// same user ID in different tenants are independent.
+//
+// Example:
+//
+//	usage, err := guard.TenantUsage(ctx, "acme", "user123")
+func (g *Guard) TenantUsage(ctx context.Context, tenantID, userID string) (Usage, error) {
+	usage := Usage{UserID: userID, TenantID: tenantID}
+	if g.quotas == nil {
+		return usage, nil
+	}
+	id := budgetSubject(quota.ScopeUser, model.CallContext{UserID: userID, TenantID: tenantID})
+	now := time.Now()
+	var err error
+	if usage.Daily, err = g.quotas.Get(ctx, quota.Key(quota.ScopeUser, id, quota.Daily, now)); err != nil {
+		return usage, err
+	}
+	if usage.Monthly, err = g.quotas.Get(ctx, quota.Key(quota.ScopeUser, id, quota.Monthly, now)); err != nil {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// budgetChecker enforces BUDGET policies against the Guard's QuotaStore.
+type budgetChecker struct{ g *Guard }
+
+// CheckBudget implements policy.BudgetChecker.
+func (b budgetChecker) CheckBudget(ctx context.Context, p policy.Policy, tc model.ToolCall) (string, error) {
+	scope, period := budgetScope(p), budgetPeriod(p)
+	id := budgetSubject(scope, tc.Context)
+	if id == "" {
+		return "", nil
+	}
+	cost, err := b.g.toolCost(ctx, tc)
+	if err != nil {
+		return "", err
+	}
+	used, err := b.g.quotas.Get(ctx, quota.Key(scope, id, period, tc.Timestamp))
+	if err != nil {
+		return "", err
+	}
+	if used+cost > p.Limit {
+		return fmt.Sprintf("%s %s budget exceeded: %.2f used, call costs %.2f, limit %.2f", scope, period, used, cost, p.Limit), nil
+	}
+	return "", nil
+}
+
+// recordUsage charges an allowed call's cost to its user and tenant for the
+// current day and month. For rewritten calls the corrected tool is charged.
+func (g *Guard) recordUsage(ctx context.Context, tc model.ToolCall, result model.ValidationResult) {
+	if g.quotas == nil || !result.ExecutionAllowed {
+		return
+	}
+	if result.SuggestedCorrection != nil {
+		tc.Name, tc.Parameters = result.SuggestedCorrection.Name, result.SuggestedCorrection.Parameters
+	}
+	cost, err := g.toolCost(ctx, tc)
+	if err != nil {
+		g.logger(tc).Error("usage not recorded for %s: %v", tc.ID, err)
+		return
+	}
+	if cost == 0 {
+		return
+	}
+	for _, scope := range []string{quota.ScopeUser, quota.ScopeTenant} {
+		id := budgetSubject(scope, tc.Context)
+		if id == "" {
+			continue
+		}
+		for _, period := range []string{quota.Daily, quota.Monthly} {
+			key := quota.Key(scope, id, period, tc.Timestamp)
+			if _, err := g.quotas.Add(ctx, key, cost, quota.PeriodEnd(period, tc.Timestamp)); err != nil {
+				g.logger(tc).Error("quota store add failed for %s: %v", tc.ID, err)
+			}
+		}
+	}
+}
+
+// checkRewrittenBudget applies the BUDGET policies of the tool a rewritten
+// call runs as, with that tool's cost; evaluation checked the requested tool.
+// A call that would exceed one is rejected. The caller must hold g.mu.
+func (g *Guard) checkRewrittenBudget(ctx context.Context, tc model.ToolCall, result model.ValidationResult) model.ValidationResult {
+	c := result.SuggestedCorrection
+	if c == nil || !result.ExecutionAllowed || c.Name == tc.Name {
+		return result
+	}
+	tc.Name, tc.Parameters = c.Name, c.Parameters
+	budget, exceeded := g.checkBudgets(ctx, tc)
+	if !exceeded {
+		return result
+	}
+	result.Status = "rejected"
+	if budget.Action == policy.PolicyTimeout {
+		result.Status = "timeout"
+	}
+	result.Confidence = 1.0
+	result.ExecutionAllowed = false
+	result.PolicyAction = string(budget.Action)
+	result.PolicyID = budget.PolicyID
+	result.Origin = model.OriginBudget
+	result.Reason = budget.Reason
+	result.SuggestedCorrection = nil
+	result.Modifications = nil
+	return result
+}
+
+// checkBudgets applies the BUDGET policies to a call released outside
+// ValidateToolCall, such as an approved one. The caller must hold g.mu.
+func (g *Guard) checkBudgets(ctx context.Context, tc model.ToolCall) (policy.PolicyResult, bool) {
+	if g.quotas == nil {
+		return policy.PolicyResult{}, false
+	}
+	opts := g.validateOptions(tc)
+	if ts, ok := g.schemasFor(tc.Context.TenantID).Get(tc.Name); ok {
+		opts.Policy.ToolTags = ts.Tags
+	}
+	return policy.CheckBudgets(ctx, tc, opts.Policy)
+}
+
+func (g *Guard) toolCost(ctx context.Context, tc model.ToolCall) (float64, error) {
+	opts := policy.EvalOptions{
+		MaxConditionMemory:     g.budget.MaxConditionMemory,
+		MaxConditionOperations: g.budget.MaxConditionOperations,
+		Set:                    g.policiesFor(tc.Context.TenantID),
+		Extensions:             g.extensions,
+	}
+	return g.schemasFor(tc.Context.TenantID).ToolCost(ctx, tc, opts)
+}
+
+func budgetScope(p policy.Policy) string {
+	if p.Scope == "" {
+		return quota.ScopeUser
+	}
+	return p.Scope
+}
+
+func budgetPeriod(p policy.Policy) string {
+	if p.Period == "" {
+		return quota.Daily
+	}
+	return p.Period
+}
+
+// budgetSubject returns the user or tenant a budget applies to. Tenants are
+// identified by CallContext.TenantID, falling back to the "tenant_id"
+// metadata key, for both scopes; users inside a tenant are keyed as
+// "<tenant>/<user>" so tenants never share counters. Calls without a subject
+// are not metered.
+func budgetSubject(scope string, cc model.CallContext) string {
+	tenant := cc.TenantID
+	if tenant == "" {
+		tenant, _ = cc.Metadata["tenant_id"].(string)
+	}
+	if scope == quota.ScopeTenant {
+		return tenant
+	}
+	if cc.UserID == "" || tenant == "" {
+		return cc.UserID
+	}
+	return tenant + "/" + cc.UserID
+}
@@ -0,0 +1,123 @@
+package hallucinationguard
+
+import (
+	"context"
+	"io"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/replay"
+	"github.com/SafellmHub/hguard-go/pkg/internal/schema"
+)
+
+// RecorderOptions configures WithRecorder.
+type RecorderOptions struct {
+	// SampleRate is the fraction of calls recorded, between 0 and 1. Zero
+	// records every call.
+	SampleRate float64
+	// Redact is called with each sampled call before it is written, after
+	// sensitive parameters were masked as configured by WithRedaction. It may
+	// modify the call, e.g. to drop metadata, or return false to skip it.
+	Redact func(call *ToolCall) bool
+}
+
+// WithRecorder records tool calls validated by ValidateToolCall to w as JSON
+// Lines, each with its context, session history and decision. Recordings
+// are corpora for `hguard diff`, and ReplayRecording checks them against
+// the current schemas and policies.
+//
+// Example:
+//
+//	f, _ := os.OpenFile("calls.jsonl", os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+//	guard := hallucinationguard.New(WithRecorder(f, RecorderOptions{SampleRate: 0.05}))
+func WithRecorder(w io.Writer, opts RecorderOptions) GuardOption {
+	return func(g *Guard) {
+		g.recorder = replay.NewRecorder(w, opts.SampleRate)
+		g.recordRedact = opts.Redact
+	}
+}
+
+// record writes a sampled call and its decision to the recorder, if one is
+// configured.
+func (g *Guard) record(tc model.ToolCall, result model.ValidationResult) {
+	if g.recorder == nil || !g.recorder.Sample() {
+		return
+	}
+	tenantID := tc.Context.TenantID
+	call := ToolCall{
+		Name:       tc.Name,
+		Parameters: g.redactParameters(tenantID, tc.Name, tc.Parameters),
+		Context:    toPublicContext(tc.Context),
+	}
+	if g.recordRedact != nil {
+		// The hook gets its own maps so it cannot alter the caller's.
+		call.Parameters = copyMap(call.Parameters)
+		call.Context.Metadata = copyMap(call.Context.Metadata)
+		if !g.recordRedact(&call) {
+			return
+		}
+	}
+	internal := model.ToolCall{
+		ID:         result.ToolCallID,
+		Name:       call.Name,
+		Parameters: call.Parameters,
+		Context:    toInternalContext(call.Context),
+		Timestamp:  tc.Timestamp.UTC(),
+	}
+	for _, h := range tc.Context.History {
+		h.Parameters = g.redactParameters(tenantID, h.ToolName, h.Parameters)
+		internal.Context.History = append(internal.Context.History, h)
+	}
+	decision := replay.DecisionOf(result)
+	decision.Reason = g.redactString(decision.Reason)
+	if err := g.recorder.Write(replay.Record{ToolCall: internal, Decision: &decision}); err != nil {
+		g.logger(tc).Error("recording failed for %s: %v", result.ToolCallID, err)
+	}
+}
+
+// ReplayRecording re-validates every call of a recording written by
+// WithRecorder against the schemas and policies currently loaded, including
+// tenant bundles. It reports the calls whose action, status or reason
+// differ from the recorded decision. Calls keep their recorded time and
+// session history.
+//
+// Replaying has no side effects: nothing is written to sessions, usage,
+// approvals, the audit log, the recording or metrics. BUDGET policies are
+// not checked, so calls recorded as decided by a budget or by an
+// infrastructure error, such as an unavailable session store, are skipped.
+// Calls whose decisions depend on values masked when they were recorded may
+// replay differently.
+//
+// Example:
+//
+//	report, err := guard.ReplayRecording(ctx, f)
+//	if err == nil && report.Changed > 0 { /* regressions */ }
+func (g *Guard) ReplayRecording(ctx context.Context, r io.Reader) (*PolicyDiff, error) {
+	entries, err := replay.ReadCalls(r)
+	if err != nil {
+		return nil, err
+	}
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return replay.Replay(ctx, entries, func(ctx context.Context, tc model.ToolCall) model.ValidationResult {
+		opts := g.validateOptions(tc)
+		opts.Policy.Budget = nil
+		opts.Policy.Observer = nil
+		opts.OnFuzzyMatch = nil
+		result := schema.ValidateAndPolicyWithOptions(ctx, tc, opts)
+		// Recorded reasons were redacted; compare like with like.
+		result.Reason = g.redactString(result.Reason)
+		return result
+	}), nil
+}
+
+// copyMap returns a shallow copy of m, or nil if m is nil.
+func copyMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,87 @@
+package hallucinationguard
+
+import (
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/logging"
+	"github.com/SafellmHub/hguard-go/pkg/internal/redact"
+)
+
+// Built-in PII detector names for RedactionConfig.Detectors.
+const (
+	DetectorEmail      = redact.DetectorEmail
+	DetectorPhone      = redact.DetectorPhone
+	DetectorCreditCard = redact.DetectorCreditCard
+	DetectorIBAN       = redact.DetectorIBAN
+	DetectorAPIKey     = redact.DetectorAPIKey
+)
+
+// RedactionConfig configures masking of sensitive parameter values.
+//
+// Parameters flagged `sensitive: true` in a tool schema are always masked in
+// full. Other string values are scanned by the selected detectors and only the
+// matching substrings are masked.
+type RedactionConfig struct {
+	Detectors         []string // Built-in detectors to enable; empty enables all
+	Mask              string   // Replacement text; defaults to "[REDACTED]"
+	RedactCorrections bool     // Also mask parameters echoed in SuggestedCorrection
+}
+
+// WithRedaction masks sensitive values in audit records, in the log output
+// about the Guard's calls and, optionally, in suggested corrections. Each
+// Guard masks its own log messages; SetLogRedaction covers the rest.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithRedaction(RedactionConfig{RedactCorrections: true}))
+func WithRedaction(cfg RedactionConfig) GuardOption {
+	return func(g *Guard) {
+		g.redactor = redact.New(redact.SelectDetectors(cfg.Detectors), cfg.Mask)
+		g.redactCorrections = cfg.RedactCorrections
+	}
+}
+
+// SetLogRedaction masks detector matches in the SDK's structured log output
+// with cfg's Detectors and Mask. It is a process-wide setting for messages
+// not about a call of a Guard configured with WithRedaction, such as those
+// logged while loading policies. A nil cfg turns it off.
+//
+// Example:
+//
+//	hallucinationguard.SetLogRedaction(&hallucinationguard.RedactionConfig{Detectors: []string{DetectorEmail}})
+func SetLogRedaction(cfg *RedactionConfig) {
+	if cfg == nil {
+		logging.SetRedactor(nil)
+		return
+	}
+	logging.SetRedactor(redact.New(redact.SelectDetectors(cfg.Detectors), cfg.Mask).Value)
+}
+
+// logger returns the logger for messages about a call. With redaction
+// configured it masks the call's sensitive parameters and the Guard's
+// detector matches; without it, messages go to the process-wide logger,
+// which SetLogRedaction configures.
+func (g *Guard) logger(tc model.ToolCall) *logging.Logger {
+	if g.redactor == nil {
+		return nil
+	}
+	sensitive := g.schemasFor(tc.Context.TenantID).SensitiveParameters(tc.Name)
+	return logging.New(g.redactor.Masker(tc.Parameters, sensitive))
+}
+
+// redactParameters returns params with sensitive values masked according to
+// the schema of the named tool. It returns params unchanged when redaction is
+// not configured.
+func (g *Guard) redactParameters(tenantID, toolName string, params map[string]interface{}) map[string]interface{} {
+	if g.redactor == nil {
+		return params
+	}
+	return g.redactor.Parameters(params, g.schemasFor(tenantID).SensitiveParameters(toolName))
+}
+
+// redactString masks detector matches in s when redaction is configured.
+func (g *Guard) redactString(s string) string {
+	if g.redactor == nil {
+		return s
+	}
+	return g.redactor.String(s)
+}
@@ -0,0 +1,151 @@
+package hallucinationguard
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/redact"
+	"github.com/SafellmHub/hguard-go/pkg/internal/schema"
+	"github.com/SafellmHub/hguard-go/pkg/internal/trace"
+)
+
+// Result validation statuses.
+const (
+	ResultApproved  = schema.ResultApproved
+	ResultRejected  = schema.ResultRejected
+	ResultTruncated = schema.ResultTruncated // Result was cut to the schema's max_size
+	ResultRedacted  = schema.ResultRedacted  // Sensitive data in the result was masked
+)
+
+// PolicyActionRESULT marks audit records written by ValidateToolResult.
+const PolicyActionRESULT = "RESULT"
+
+// maxTrackedCalls bounds how many allowed call IDs are remembered for
+// ValidateToolResult; the oldest are forgotten first.
+const maxTrackedCalls = 10000
+
+// ResultValidation is the outcome of validating a tool's output.
+//
+// Example:
+//
+//	rv := guard.ValidateToolResult(ctx, result.ToolCallID, output)
+//	if rv.Allowed { /* hand rv.Result to the model */ }
+type ResultValidation struct {
+	Allowed    bool        `json:"allowed"`
+	Status     string      `json:"status"`
+	Error      string      `json:"error,omitempty"`
+	ToolCallID string      `json:"tool_call_id"`
+	ToolName   string      `json:"tool_name,omitempty"`
+	Result     interface{} `json:"result,omitempty"` // Possibly truncated or redacted; nil when rejected
+}
+
+// trackedCalls remembers recently allowed calls so their results can be
+// matched to a tool schema. It is safe for concurrent use.
+type trackedCalls struct {
+	mu    sync.Mutex
+	calls map[string]model.ToolCall
+	order []string
+}
+
+func newTrackedCalls() *trackedCalls {
+	return &trackedCalls{calls: map[string]model.ToolCall{}}
+}
+
+func (t *trackedCalls) add(tc model.ToolCall) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.calls[tc.ID]; !ok {
+		t.order = append(t.order, tc.ID)
+	}
+	t.calls[tc.ID] = tc
+	for len(t.order) > maxTrackedCalls {
+		delete(t.calls, t.order[0])
+		t.order = t.order[1:]
+	}
+}
+
+func (t *trackedCalls) get(id string) (model.ToolCall, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	tc, ok := t.calls[id]
+	return tc, ok
+}
+
+// defaultResultRedactor detects PII in results when no WithRedaction is set.
+var defaultResultRedactor = redact.New(redact.DefaultDetectors(), "")
+
+// ValidateToolResult checks the output of an allowed call against the
+// `returns:` block of its tool schema: JSON shape, forbidden patterns, PII and
+// maximum size. callID is the ToolCallID from ValidateToolCall (or the call
+// released by ResumeApproved). Results of unknown or forgotten calls are
+// rejected; tools without a `returns:` block approve any result.
+//
+// Example:
+//
+//	rv := guard.ValidateToolResult(ctx, result.ToolCallID, `{"temperature": 21}`)
+func (g *Guard) ValidateToolResult(ctx context.Context, callID string, result interface{}) ResultValidation {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+
+	_, span := trace.Start(ctx, g.tracer, trace.SpanValidateResult)
+	defer span.End()
+
+	tc, ok := g.results.get(callID)
+	if !ok {
+		rv := ResultValidation{
+			Status:     ResultRejected,
+			Error:      fmt.Sprintf("unknown tool call ID %q", callID),
+			ToolCallID: callID,
+		}
+		span.SetAttribute(trace.AttrDecisionStatus, rv.Status)
+		return rv
+	}
+	span.SetAttribute(trace.AttrToolName, tc.Name)
+
+	var returns *schema.ResultSchema
+	if ts, ok := g.schemasFor(tc.Context.TenantID).Get(tc.Name); ok {
+		returns = ts.Returns
+	}
+	redactor := g.redactor
+	if redactor == nil {
+		redactor = defaultResultRedactor
+	}
+	check := schema.ValidateResult(returns, result, redactor)
+
+	rv := ResultValidation{
+		Allowed:    check.Allowed,
+		Status:     check.Status,
+		Error:      check.Reason,
+		ToolCallID: callID,
+		ToolName:   tc.Name,
+		Result:     check.Result,
+	}
+	span.SetAttribute(trace.AttrDecisionStatus, rv.Status)
+
+	audited := stamped(tc)
+	audited.Parameters = nil
+	g.audit(audited, model.ValidationResult{
+		ToolCallID:       callID,
+		Status:           check.Status,
+		ExecutionAllowed: check.Allowed,
+		PolicyAction:     PolicyActionRESULT,
+		Reason:           check.Reason,
+	})
+	return rv
+}
+
+// trackCall remembers an allowed call for ValidateToolResult. For rewritten
+// calls the corrected tool is tracked.
+func (g *Guard) trackCall(tc model.ToolCall, result model.ValidationResult) {
+	if !result.ExecutionAllowed {
+		return
+	}
+	if result.SuggestedCorrection != nil {
+		tc.Name, tc.Parameters = result.SuggestedCorrection.Name, result.SuggestedCorrection.Parameters
+	}
+	tc.Timestamp = time.Now()
+	g.results.add(tc)
+}
@@ -0,0 +1,43 @@
+package hallucinationguard
+
+import (
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+)
+
+// HasRole reports whether the caller's UserRole is role or inherits from it
+// in the `roles:` sections its calls see: those of its tenant bundle, if
+// any, and the global ones. It matches the has_role condition function.
+//
+// Example:
+//
+//	guard.HasRole(&CallContext{UserRole: "admin", TenantID: "acme"}, "manager")
+func (g *Guard) HasRole(cc *CallContext, role string) bool {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.roleSet(cc).HasRole(toInternalContext(cc).UserRole, role)
+}
+
+// Permissions returns the effective permissions of a caller: its
+// UserPermissions followed by the grants of its UserRole, including
+// inherited roles and roles defined in its tenant bundle. These are the
+// permissions conditions see in user.permissions.
+//
+// Example:
+//
+//	perms := guard.Permissions(&CallContext{UserRole: "manager", TenantID: "acme"})
+func (g *Guard) Permissions(cc *CallContext) []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	perms := g.roleSet(cc).EffectivePermissions(toInternalContext(cc))
+	return append([]string{}, perms...)
+}
+
+// roleSet returns the policy set whose roles apply to a caller.
+func (g *Guard) roleSet(cc *CallContext) *policy.Set {
+	if cc != nil {
+		if set := g.policiesFor(cc.TenantID); set != nil {
+			return set
+		}
+	}
+	return policy.Default()
+}
@@ -0,0 +1,99 @@
+package hallucinationguard
+
+import (
+	"context"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/session"
+)
+
+// SessionCall is an approved tool call recorded in a session's history.
+type SessionCall = model.SessionCall
+
+// SessionStore persists the approved calls of each session. Implement it to
+// share history across processes (e.g. backed by Redis); the default is an
+// in-memory store. Implementations must be safe for concurrent use.
+type SessionStore = session.Store
+
+// MemorySessionStore is the default in-memory SessionStore.
+type MemorySessionStore = session.MemoryStore
+
+// NewMemorySessionStore creates an in-memory SessionStore whose sessions
+// expire ttl after their last call and keep at most maxCalls calls.
+// Non-positive values use defaults of 24 hours and 1000 calls.
+//
+// Example:
+//
+//	store := hallucinationguard.NewMemorySessionStore(time.Hour, 500)
+func NewMemorySessionStore(ttl time.Duration, maxCalls int) *MemorySessionStore {
+	return session.NewMemoryStore(ttl, maxCalls)
+}
+
+// WithSessionStore sets the store used to record approved calls per
+// CallContext.SessionID. Pass nil to disable Guard-managed history, in which
+// case conditions see only the caller-supplied PreviousCalls.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithSessionStore(myRedisStore))
+func WithSessionStore(store SessionStore) GuardOption {
+	return func(g *Guard) {
+		g.sessions = store
+	}
+}
+
+// SessionHistory returns the calls the Guard has approved for a session,
+// oldest first.
+//
+// Example:
+//
+//	history, err := guard.SessionHistory(ctx, "session456")
+func (g *Guard) SessionHistory(ctx context.Context, sessionID string) ([]SessionCall, error) {
+	if g.sessions == nil {
+		return nil, nil
+	}
+	return g.sessions.History(ctx, sessionID)
+}
+
+// loadSessionHistory replaces caller-supplied history with the recorded one.
+// When a session store is configured and the call carries a SessionID, both
+// session.history and session.previous_calls come from the store, so callers
+// cannot forge or forget earlier calls.
+func (g *Guard) loadSessionHistory(ctx context.Context, cc *model.CallContext) error {
+	if g.sessions == nil || cc.SessionID == "" {
+		return nil
+	}
+	history, err := g.sessions.History(ctx, cc.SessionID)
+	if err != nil {
+		return err
+	}
+	previous := make([]string, len(history))
+	for i, c := range history {
+		previous[i] = c.ToolName
+	}
+	cc.History = history
+	cc.PreviousCalls = previous
+	return nil
+}
+
+// recordSessionCall appends an allowed call to its session's history. For
+// rewritten calls the corrected tool is recorded.
+func (g *Guard) recordSessionCall(ctx context.Context, tc model.ToolCall, result model.ValidationResult) {
+	if g.sessions == nil || tc.Context.SessionID == "" || !result.ExecutionAllowed {
+		return
+	}
+	name, params := tc.Name, tc.Parameters
+	if result.SuggestedCorrection != nil {
+		name, params = result.SuggestedCorrection.Name, result.SuggestedCorrection.Parameters
+	}
+	err := g.sessions.Append(ctx, tc.Context.SessionID, SessionCall{
+		ToolCallID: tc.ID,
+		ToolName:   name,
+		Parameters: params,
+		Timestamp:  tc.Timestamp,
+	})
+	if err != nil {
+		g.logger(tc).Error("session store append failed for %s: %v", tc.ID, err)
+	}
+}
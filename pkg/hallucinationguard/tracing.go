@@ -0,0 +1,47 @@
+package hallucinationguard
+
+import (
+	"github.com/SafellmHub/hguard-go/pkg/internal/trace"
+)
+
+// Tracer starts spans for tool call validation. It mirrors the shape of an
+// OpenTelemetry tracer so an adapter is only a few lines:
+//
+//	type otelTracer struct{ t oteltrace.Tracer }
+//
+//	func (o otelTracer) Start(ctx context.Context, name string) (context.Context, hallucinationguard.Span) {
+//		ctx, span := o.t.Start(ctx, name)
+//		return ctx, otelSpan{span}
+//	}
+//
+// Start must return a context carrying the new span so that spans started
+// from it become its children.
+type Tracer = trace.Tracer
+
+// Span is a single timed operation created by a Tracer.
+type Span = trace.Span
+
+// Span names emitted by the Guard. ValidateToolCall creates one
+// SpanValidate span per call, with child spans for the schema lookup, fuzzy
+// match, parameter validation, policy evaluation and every condition evaluated.
+const (
+	SpanValidate           = trace.SpanValidate
+	SpanSchemaLookup       = trace.SpanSchemaLookup
+	SpanFuzzyMatch         = trace.SpanFuzzyMatch
+	SpanValidateParameters = trace.SpanValidateParameters
+	SpanEvaluatePolicy     = trace.SpanEvaluatePolicy
+	SpanCondition          = trace.SpanCondition
+	SpanValidateResult     = trace.SpanValidateResult // One per ValidateToolResult
+)
+
+// WithTracer creates spans for every validation with t. The default is a
+// no-op tracer.
+//
+// Example:
+//
+//	guard := hallucinationguard.New(WithTracer(otelTracer{otel.Tracer("hguard")}))
+func WithTracer(t Tracer) GuardOption {
+	return func(g *Guard) {
+		g.tracer = t
+	}
+}
@@ -0,0 +1,261 @@
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// Package approval parks tool calls that need a human decision.
+//
+// A call matched by a REQUIRE_APPROVAL policy is stored as a pending Request
+// identified by an opaque token. An approver later approves or denies it, and
+// an approved request can be consumed exactly once to obtain the call for
+// execution.
+//
+// Example usage:
+//
+//	store := approval.NewMemoryStore()
+//	err := store.Add(ctx, approval.Request{Token: approval.NewToken(), ToolCall: tc, ExpiresAt: exp})
+//	req, err := store.Approve(ctx, token, "alice")
+//	req, err = store.Consume(ctx, token)
+//
+// Status is the lifecycle state of a request.
+type Status string
+
+const (
+	StatusPending  Status = "pending"
+	StatusApproved Status = "approved"
+	StatusDenied   Status = "denied"
+	StatusExpired  Status = "expired"
+	StatusConsumed Status = "consumed"
+)
+
+// Errors returned by Store implementations.
+var (
+	ErrNotFound     = errors.New("approval request not found")
+	ErrExpired      = errors.New("approval request expired")
+	ErrNotPending   = errors.New("approval request is not pending")
+	ErrNotApproved  = errors.New("approval request is not approved")
+	ErrSelfApproval = errors.New("approval request cannot be approved by its requester")
+	ErrNoApprover   = errors.New("approval decision requires an approver")
+)
+
+// Request is a tool call awaiting (or having received) a human decision.
+type Request struct {
+	Token      string         `json:"token"`
+	ToolCall   model.ToolCall `json:"tool_call"`
+	Reason     string         `json:"reason,omitempty"`
+	PolicyID   string         `json:"policy_id,omitempty"`
+	Status     Status         `json:"status"`
+	CreatedAt  time.Time      `json:"created_at"`
+	ExpiresAt  time.Time      `json:"expires_at"`
+	Approver   string         `json:"approver,omitempty"`
+	DecidedAt  time.Time      `json:"decided_at,omitempty"`
+	Note       string         `json:"note,omitempty"`
+	ConsumedAt time.Time      `json:"consumed_at,omitempty"`
+}
+
+// SelfApproval reports whether approver is the user who made the call.
+// Anonymous calls and approvers never match.
+func (r Request) SelfApproval(approver string) bool {
+	return approver != "" && approver == r.ToolCall.Context.UserID
+}
+
+// Store holds approval requests. Implementations must be safe for concurrent
+// use, must treat requests past ExpiresAt as StatusExpired, must make
+// Consume atomic so an approved call is released at most once, and must
+// refuse to let the requester approve their own call with ErrSelfApproval.
+// Decisions without an approver fail with ErrNoApprover.
+type Store interface {
+	// Add stores a new pending request.
+	Add(ctx context.Context, req Request) error
+	// Get returns a request by token.
+	Get(ctx context.Context, token string) (Request, error)
+	// List returns requests with the given status, oldest first. An empty
+	// status returns all requests.
+	List(ctx context.Context, status Status) ([]Request, error)
+	// Approve records approver's approval of a pending request.
+	Approve(ctx context.Context, token, approver string) (Request, error)
+	// Deny records approver's denial of a pending request.
+	Deny(ctx context.Context, token, approver, note string) (Request, error)
+	// Consume marks an approved request as used and returns it.
+	Consume(ctx context.Context, token string) (Request, error)
+}
+
+// NewToken returns a random, URL-safe approval token.
+func NewToken() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic("approval: crypto/rand failed: " + err.Error())
+	}
+	return "apr_" + hex.EncodeToString(b)
+}
+
+// DefaultRetention is how long MemoryStore keeps denied, consumed and
+// expired requests, so their final status can still be looked up.
+const DefaultRetention = time.Hour
+
+// MemoryStore is an in-memory Store. Requests are evicted DefaultRetention
+// after they are denied, consumed or expire.
+type MemoryStore struct {
+	mu        sync.Mutex
+	requests  map[string]*Request
+	retention time.Duration
+	swept     time.Time
+	now       func() time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{requests: map[string]*Request{}, retention: DefaultRetention, now: time.Now}
+}
+
+// Add implements Store.
+func (m *MemoryStore) Add(ctx context.Context, req Request) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if req.Status == "" {
+		req.Status = StatusPending
+	}
+	now := m.now()
+	if req.CreatedAt.IsZero() {
+		req.CreatedAt = now
+	}
+	m.evictSettled(now)
+	m.requests[req.Token] = &req
+	return nil
+}
+
+// Get implements Store.
+func (m *MemoryStore) Get(ctx context.Context, token string) (Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	req, ok := m.requests[token]
+	if !ok {
+		return Request{}, ErrNotFound
+	}
+	m.expire(req)
+	return *req, nil
+}
+
+// List implements Store.
+func (m *MemoryStore) List(ctx context.Context, status Status) ([]Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var out []Request
+	for _, req := range m.requests {
+		m.expire(req)
+		if status == "" || req.Status == status {
+			out = append(out, *req)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].CreatedAt.Before(out[j].CreatedAt) })
+	return out, nil
+}
+
+// Approve implements Store.
+func (m *MemoryStore) Approve(ctx context.Context, token, approver string) (Request, error) {
+	return m.decide(token, approver, "", StatusApproved)
+}
+
+// Deny implements Store.
+func (m *MemoryStore) Deny(ctx context.Context, token, approver, note string) (Request, error) {
+	return m.decide(token, approver, note, StatusDenied)
+}
+
+func (m *MemoryStore) decide(token, approver, note string, status Status) (Request, error) {
+	if approver == "" {
+		return Request{}, ErrNoApprover
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	req, ok := m.requests[token]
+	if !ok {
+		return Request{}, ErrNotFound
+	}
+	m.expire(req)
+	switch req.Status {
+	case StatusPending:
+	case StatusExpired:
+		return *req, ErrExpired
+	default:
+		return *req, ErrNotPending
+	}
+	if status == StatusApproved && req.SelfApproval(approver) {
+		return *req, ErrSelfApproval
+	}
+	req.Status = status
+	req.Approver = approver
+	req.Note = note
+	req.DecidedAt = m.now()
+	return *req, nil
+}
+
+// Consume implements Store.
+func (m *MemoryStore) Consume(ctx context.Context, token string) (Request, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	req, ok := m.requests[token]
+	if !ok {
+		return Request{}, ErrNotFound
+	}
+	m.expire(req)
+	switch req.Status {
+	case StatusApproved:
+	case StatusExpired:
+		return *req, ErrExpired
+	default:
+		return *req, ErrNotApproved
+	}
+	req.Status = StatusConsumed
+	req.ConsumedAt = m.now()
+	return *req, nil
+}
+
+// expire moves undecided or unconsumed requests past their deadline to
+// StatusExpired. The caller must hold m.mu.
+func (m *MemoryStore) expire(req *Request) {
+	if req.ExpiresAt.IsZero() || m.now().Before(req.ExpiresAt) {
+		return
+	}
+	if req.Status == StatusPending || req.Status == StatusApproved {
+		req.Status = StatusExpired
+	}
+}
+
+// sweepInterval bounds how often Add scans for requests to evict.
+const sweepInterval = time.Minute
+
+// evictSettled drops requests that were denied, consumed or expired more
+// than m.retention ago, at most once per sweepInterval. The caller must hold
+// m.mu.
+func (m *MemoryStore) evictSettled(now time.Time) {
+	if now.Sub(m.swept) < sweepInterval {
+		return
+	}
+	m.swept = now
+	for token, req := range m.requests {
+		m.expire(req)
+		var settled time.Time
+		switch req.Status {
+		case StatusDenied:
+			settled = req.DecidedAt
+		case StatusConsumed:
+			settled = req.ConsumedAt
+		case StatusExpired:
+			settled = req.ExpiresAt
+		default:
+			continue
+		}
+		if now.Sub(settled) >= m.retention {
+			delete(m.requests, token)
+		}
+	}
+}
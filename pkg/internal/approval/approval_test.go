@@ -0,0 +1,118 @@
+package approval
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestApprovalLifecycle(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	approved, denied := NewToken(), NewToken()
+	for _, token := range []string{approved, denied} {
+		store.Add(ctx, Request{
+			Token:     token,
+			ToolCall:  model.ToolCall{Name: "quote"},
+			ExpiresAt: now.Add(time.Hour),
+		})
+	}
+
+	pending, _ := store.List(ctx, StatusPending)
+	if len(pending) != 2 {
+		t.Fatalf("Expected 2 pending requests, got %d", len(pending))
+	}
+
+	if _, err := store.Consume(ctx, approved); !errors.Is(err, ErrNotApproved) {
+		t.Errorf("Expected ErrNotApproved before approval, got %v", err)
+	}
+	req, err := store.Approve(ctx, approved, "alice")
+	if err != nil || req.Approver != "alice" || req.Status != StatusApproved {
+		t.Fatalf("Expected approval by alice, got %+v (%v)", req, err)
+	}
+	if _, err := store.Consume(ctx, approved); err != nil {
+		t.Errorf("Expected first Consume to succeed, got %v", err)
+	}
+	if _, err := store.Consume(ctx, approved); !errors.Is(err, ErrNotApproved) {
+		t.Errorf("Expected second Consume to fail, got %v", err)
+	}
+
+	if _, err := store.Deny(ctx, denied, "bob", "too large"); err != nil {
+		t.Fatalf("Deny failed: %v", err)
+	}
+	if _, err := store.Approve(ctx, denied, "alice"); !errors.Is(err, ErrNotPending) {
+		t.Errorf("Expected ErrNotPending after denial, got %v", err)
+	}
+}
+
+func TestApprovalExpiry(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	token := NewToken()
+	store.Add(ctx, Request{Token: token, ExpiresAt: now.Add(time.Minute)})
+	store.Approve(ctx, token, "alice")
+
+	now = now.Add(2 * time.Minute)
+	if _, err := store.Consume(ctx, token); !errors.Is(err, ErrExpired) {
+		t.Errorf("Expected ErrExpired, got %v", err)
+	}
+}
+
+func TestApprovalSelfApproval(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	token := NewToken()
+	store.Add(ctx, Request{Token: token, ToolCall: model.ToolCall{Context: model.CallContext{UserID: "alice"}}})
+
+	if _, err := store.Approve(ctx, token, "alice"); !errors.Is(err, ErrSelfApproval) {
+		t.Fatalf("Expected ErrSelfApproval, got %v", err)
+	}
+	if _, err := store.Approve(ctx, token, ""); !errors.Is(err, ErrNoApprover) {
+		t.Fatalf("Expected ErrNoApprover, got %v", err)
+	}
+	if _, err := store.Approve(ctx, token, "bob"); err != nil {
+		t.Errorf("Expected approval by another user to succeed, got %v", err)
+	}
+}
+
+func TestApprovalEviction(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+
+	denied, consumed, expired, pending := NewToken(), NewToken(), NewToken(), NewToken()
+	for _, token := range []string{denied, consumed, expired} {
+		store.Add(ctx, Request{Token: token, ExpiresAt: now.Add(time.Minute)})
+	}
+	store.Add(ctx, Request{Token: pending, ExpiresAt: now.Add(48 * time.Hour)})
+	store.Deny(ctx, denied, "bob", "no")
+	store.Approve(ctx, consumed, "bob")
+	store.Consume(ctx, consumed)
+
+	now = now.Add(DefaultRetention / 2)
+	store.Add(ctx, Request{Token: NewToken(), ExpiresAt: now.Add(time.Minute)})
+	if all, _ := store.List(ctx, ""); len(all) != 5 {
+		t.Fatalf("Expected settled requests to be kept within retention, got %d", len(all))
+	}
+
+	now = now.Add(DefaultRetention)
+	store.Add(ctx, Request{Token: NewToken(), ExpiresAt: now.Add(time.Minute)})
+	for _, token := range []string{denied, consumed, expired} {
+		if _, err := store.Get(ctx, token); !errors.Is(err, ErrNotFound) {
+			t.Errorf("Expected settled request to be evicted, got %v", err)
+		}
+	}
+	if req, err := store.Get(ctx, pending); err != nil || req.Status != StatusPending {
+		t.Errorf("Expected pending request to be kept, got %+v (%v)", req, err)
+	}
+}
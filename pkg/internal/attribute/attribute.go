@@ -0,0 +1,167 @@
+package attribute
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// Package attribute provides a reference policy.AttributeProvider backed by a
+// JSON file of entities.
+//
+// The file groups entities by attribute root and identifies each entity by a
+// key taken from the tool call. Users are keyed by user ID and tenants by
+// tenant ID unless the file says otherwise; other roots must name their key.
+//
+//	{
+//	  "keys": {"account": "params.account_id"},
+//	  "entities": {
+//	    "user":    {"u-1": {"department": "finance", "role": "manager"}},
+//	    "account": {"acct-9": {"balance": 1200, "frozen": false}}
+//	  }
+//	}
+//
+// With that file, conditions can use user.department, user.role,
+// account.balance and account.frozen.
+//
+// Example usage:
+//
+//	p, err := attribute.LoadFile("attributes.json")
+//	err = policy.RegisterAttributeProvider(p)
+
+// defaultKeys identify entities of the well-known roots.
+var defaultKeys = map[string]string{
+	"user":   "user.id",
+	"tenant": "tenant.id",
+}
+
+// fileData is the on-disk layout of a FileProvider.
+type fileData struct {
+	Keys     map[string]string                            `json:"keys,omitempty"`
+	Entities map[string]map[string]map[string]interface{} `json:"entities"`
+}
+
+// FileProvider resolves attributes from a JSON file. It is safe for
+// concurrent use.
+type FileProvider struct {
+	path string
+
+	mu   sync.RWMutex
+	data fileData
+}
+
+// LoadFile reads a FileProvider from path.
+func LoadFile(path string) (*FileProvider, error) {
+	p := &FileProvider{path: path}
+	if err := p.Reload(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Reload re-reads the file. Changed values take effect immediately; attribute
+// names that were not in the file when the provider was registered need the
+// provider to be registered again.
+func (p *FileProvider) Reload() error {
+	b, err := os.ReadFile(p.path)
+	if err != nil {
+		return fmt.Errorf("failed to read attributes file: %w", err)
+	}
+	var data fileData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return fmt.Errorf("failed to parse attributes file %s: %w", p.path, err)
+	}
+	if data.Keys == nil {
+		data.Keys = map[string]string{}
+	}
+	for root := range data.Entities {
+		if _, ok := data.Keys[root]; !ok {
+			key, ok := defaultKeys[root]
+			if !ok {
+				return fmt.Errorf("attributes file %s: no key for %s entities", p.path, root)
+			}
+			data.Keys[root] = key
+		}
+		if _, err := keyOf(data.Keys[root], model.ToolCall{}); err != nil {
+			return fmt.Errorf("attributes file %s: %s: %w", p.path, root, err)
+		}
+	}
+	p.mu.Lock()
+	p.data = data
+	p.mu.Unlock()
+	return nil
+}
+
+// Attributes implements policy.AttributeProvider. It lists every field of
+// every entity, as root.field.
+func (p *FileProvider) Attributes() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	seen := map[string]bool{}
+	var paths []string
+	for root, entities := range p.data.Entities {
+		for _, fields := range entities {
+			for field := range fields {
+				path := root + "." + field
+				if !seen[path] {
+					seen[path] = true
+					paths = append(paths, path)
+				}
+			}
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Resolve implements policy.AttributeProvider. Calls without a key for the
+// entity, and entities missing from the file, resolve to nil.
+func (p *FileProvider) Resolve(ctx context.Context, path string, tc model.ToolCall) (interface{}, error) {
+	root, field, ok := strings.Cut(path, ".")
+	if !ok {
+		return nil, fmt.Errorf("invalid attribute path %q", path)
+	}
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	key, err := keyOf(p.data.Keys[root], tc)
+	if err != nil || key == "" {
+		return nil, err
+	}
+	return p.data.Entities[root][key][field], nil
+}
+
+// keyOf evaluates a key reference such as "user.id" or "params.account_id"
+// against tc.
+func keyOf(ref string, tc model.ToolCall) (string, error) {
+	source, name, _ := strings.Cut(ref, ".")
+	switch {
+	case ref == "user.id":
+		return tc.Context.UserID, nil
+	case ref == "tenant.id":
+		return tc.Context.TenantID, nil
+	case ref == "session.id":
+		return tc.Context.SessionID, nil
+	case source == "params" && name != "":
+		return text(tc.Parameters[name]), nil
+	case source == "metadata" && name != "":
+		return text(tc.Context.Metadata[name]), nil
+	}
+	return "", fmt.Errorf("unsupported key %q: want user.id, tenant.id, session.id, params.<name> or metadata.<name>", ref)
+}
+
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,75 @@
+package attribute
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "attributes.json")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestFileProvider(t *testing.T) {
+	p, err := LoadFile(writeFile(t, `{
+		"keys": {"account": "params.account_id"},
+		"entities": {
+			"user": {"u-1": {"department": "finance"}, "u-2": {"department": "sales", "role": "manager"}},
+			"account": {"acct-9": {"balance": 1200}}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	want := []string{"account.balance", "user.department", "user.role"}
+	if got := p.Attributes(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	tc := model.ToolCall{
+		Name:       "transfer",
+		Parameters: map[string]interface{}{"account_id": "acct-9"},
+		Context:    model.CallContext{UserID: "u-1"},
+	}
+	tests := []struct {
+		path string
+		want interface{}
+	}{
+		{"user.department", "finance"},
+		{"user.role", nil},
+		{"account.balance", float64(1200)},
+	}
+	for _, tt := range tests {
+		got, err := p.Resolve(context.Background(), tt.path, tc)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: expected %v, got %v", tt.path, tt.want, got)
+		}
+	}
+	if got, _ := p.Resolve(context.Background(), "account.balance", model.ToolCall{Name: "transfer"}); got != nil {
+		t.Errorf("Expected nil without an account key, got %v", got)
+	}
+}
+
+func TestFileProviderErrors(t *testing.T) {
+	for name, content := range map[string]string{
+		"Missing key":     `{"entities": {"account": {"acct-9": {"balance": 1}}}}`,
+		"Unsupported key": `{"keys": {"account": "tool.name"}, "entities": {"account": {}}}`,
+		"Malformed":       `{"entities": [`,
+	} {
+		if _, err := LoadFile(writeFile(t, content)); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
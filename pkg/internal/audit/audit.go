@@ -0,0 +1,381 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// Package audit provides a tamper-evident decision log for tool call validation.
+//
+// Every record is chained to the SHA-256 hash of the previous record, and signed
+// checkpoints are written periodically so that a verifier holding the public key
+// can detect edits, deletions and reordering. Checkpoints are chained to each
+// other too; the digest of the last one (Report.Head) can be kept elsewhere to
+// detect later truncation of the log.
+//
+// Example usage:
+//
+//	log := audit.NewLog(f, privateKey, 100)
+//	err := log.Append(audit.Record{ToolName: "weather", Status: "approved"})
+//	report, err := audit.Verify(f, publicKey)
+//
+// GenesisHash is the previous hash used for the first record of a log.
+const GenesisHash = "0000000000000000000000000000000000000000000000000000000000000000"
+
+// Entry types written to the log.
+const (
+	EntryRecord     = "record"
+	EntryCheckpoint = "checkpoint"
+)
+
+// Record is a single tool call decision.
+type Record struct {
+	Seq          uint64                 `json:"seq"`
+	Timestamp    time.Time              `json:"timestamp"`
+	ToolCallID   string                 `json:"tool_call_id"`
+	ToolName     string                 `json:"tool_name"`
+	Parameters   map[string]interface{} `json:"parameters,omitempty"`
+	UserID       string                 `json:"user_id,omitempty"`
+	TenantID     string                 `json:"tenant_id,omitempty"`
+	SessionID    string                 `json:"session_id,omitempty"`
+	Status       string                 `json:"status"`
+	PolicyAction string                 `json:"policy_action,omitempty"`
+	Reason       string                 `json:"reason,omitempty"`
+	PrevHash     string                 `json:"prev_hash"`
+	Hash         string                 `json:"hash"`
+}
+
+// Checkpoint is a signed statement over the hash of the record at Seq and the
+// digest of the previous checkpoint (GenesisHash for the first).
+type Checkpoint struct {
+	Seq       uint64    `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Hash      string    `json:"hash"`
+	Prev      string    `json:"prev"`
+	Signature string    `json:"signature"`
+}
+
+// Digest identifies the checkpoint in the chain of checkpoints.
+func (cp Checkpoint) Digest() string {
+	sum := sha256.Sum256(checkpointMessage(cp.Seq, cp.Hash, cp.Prev))
+	return hex.EncodeToString(sum[:])
+}
+
+// Entry is one line of the log. Exactly one of Record or Checkpoint is set.
+type Entry struct {
+	Type       string      `json:"type"`
+	Record     *Record     `json:"record,omitempty"`
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// Log appends hash-chained records to a writer as JSON Lines.
+// It is safe for concurrent use.
+type Log struct {
+	mu                 sync.Mutex
+	w                  io.Writer
+	key                ed25519.PrivateKey
+	checkpointInterval uint64
+	seq                uint64
+	lastHash           string
+	lastCheckpoint     string
+}
+
+// NewLog creates a log writing to w. If key is non-nil, a signed checkpoint is
+// written after every checkpointInterval records (and on Checkpoint).
+//
+// The log starts a new chain at sequence 1, so w must not already hold
+// records; use ResumeLog to continue an existing file.
+//
+// Example:
+//
+//	log := audit.NewLog(f, privateKey, 100)
+func NewLog(w io.Writer, key ed25519.PrivateKey, checkpointInterval int) *Log {
+	if checkpointInterval < 0 {
+		checkpointInterval = 0
+	}
+	return &Log{
+		w:                  w,
+		key:                key,
+		checkpointInterval: uint64(checkpointInterval),
+		lastHash:           GenesisHash,
+		lastCheckpoint:     GenesisHash,
+	}
+}
+
+// ResumeLog reads the log in rw to its end and continues its chains, so
+// records appended after a restart follow the last sequence number, record
+// hash and checkpoint. The entries read are not verified. rw is typically a
+// file opened with os.O_RDWR|os.O_APPEND.
+//
+// Example:
+//
+//	f, _ := os.OpenFile("decisions.jsonl", os.O_CREATE|os.O_RDWR|os.O_APPEND, 0o600)
+//	log, err := audit.ResumeLog(f, privateKey, 100)
+func ResumeLog(rw io.ReadWriter, key ed25519.PrivateKey, checkpointInterval int) (*Log, error) {
+	l := NewLog(rw, key, checkpointInterval)
+	scanner := bufio.NewScanner(rw)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrMalformed, line, err)
+		}
+		switch {
+		case e.Type == EntryRecord && e.Record != nil:
+			l.seq, l.lastHash = e.Record.Seq, e.Record.Hash
+		case e.Type == EntryCheckpoint && e.Checkpoint != nil:
+			l.lastCheckpoint = e.Checkpoint.Digest()
+		default:
+			return nil, fmt.Errorf("%w: line %d: unknown entry", ErrMalformed, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	return l, nil
+}
+
+// Append chains r to the previous record and writes it. Seq, PrevHash and Hash
+// are assigned by the log.
+func (l *Log) Append(r Record) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	r.Seq = l.seq + 1
+	r.PrevHash = l.lastHash
+	if r.Timestamp.IsZero() {
+		r.Timestamp = time.Now().UTC()
+	}
+	hash, err := HashRecord(r)
+	if err != nil {
+		return err
+	}
+	r.Hash = hash
+
+	if err := l.write(Entry{Type: EntryRecord, Record: &r}); err != nil {
+		return err
+	}
+	l.seq = r.Seq
+	l.lastHash = r.Hash
+
+	if l.key != nil && l.checkpointInterval > 0 && l.seq%l.checkpointInterval == 0 {
+		return l.checkpoint()
+	}
+	return nil
+}
+
+// Checkpoint writes a signed checkpoint over the latest record. It is a no-op
+// when the log has no signing key or no records.
+func (l *Log) Checkpoint() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.key == nil || l.seq == 0 {
+		return nil
+	}
+	return l.checkpoint()
+}
+
+func (l *Log) checkpoint() error {
+	cp := Checkpoint{
+		Seq:       l.seq,
+		Timestamp: time.Now().UTC(),
+		Hash:      l.lastHash,
+		Prev:      l.lastCheckpoint,
+	}
+	cp.Signature = hex.EncodeToString(ed25519.Sign(l.key, checkpointMessage(cp.Seq, cp.Hash, cp.Prev)))
+	if err := l.write(Entry{Type: EntryCheckpoint, Checkpoint: &cp}); err != nil {
+		return err
+	}
+	l.lastCheckpoint = cp.Digest()
+	return nil
+}
+
+func (l *Log) write(e Entry) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit entry: %w", err)
+	}
+	b = append(b, '\n')
+	if _, err := l.w.Write(b); err != nil {
+		return fmt.Errorf("failed to write audit entry: %w", err)
+	}
+	return nil
+}
+
+// HashRecord returns the hex SHA-256 of the record's canonical JSON encoding
+// with the Hash field cleared. The previous hash is part of the encoding, which
+// is what chains records together.
+func HashRecord(r Record) (string, error) {
+	r.Hash = ""
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode audit record: %w", err)
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func checkpointMessage(seq uint64, hash, prev string) []byte {
+	return []byte(fmt.Sprintf("hguard-audit-checkpoint:%d:%s:%s", seq, hash, prev))
+}
+
+// Problem describes a single integrity violation found by Verify.
+type Problem struct {
+	Line    int    `json:"line"`
+	Seq     uint64 `json:"seq,omitempty"`
+	Message string `json:"message"`
+}
+
+// Report is the outcome of verifying a log.
+type Report struct {
+	Records         int       `json:"records"`
+	Checkpoints     int       `json:"checkpoints"`
+	LastCheckpoint  uint64    `json:"last_checkpoint"`
+	Head            string    `json:"head,omitempty"` // Digest of the last checkpoint
+	UncheckpointedN int       `json:"uncheckpointed"`
+	Problems        []Problem `json:"problems,omitempty"`
+}
+
+// OK reports whether no integrity problems were found.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// ErrMalformed is returned by Verify when a line cannot be decoded at all.
+var ErrMalformed = errors.New("malformed audit log")
+
+// Verify reads a log and checks that every record hashes correctly, links to
+// its predecessor, and carries the next sequence number. Each checkpoint must
+// match the record it covers and link to the previous checkpoint; if pub is
+// non-nil, checkpoint signatures are verified too. Records after the last
+// checkpoint are reported in Report.UncheckpointedN since truncation of that
+// tail cannot be proven.
+//
+// Example:
+//
+//	report, err := audit.Verify(f, publicKey)
+//	if !report.OK() { /* tampered */ }
+func Verify(r io.Reader, pub ed25519.PublicKey) (*Report, error) {
+	return VerifyHead(r, pub, "")
+}
+
+// VerifyHead is Verify for a log whose Report.Head was recorded earlier,
+// e.g. by a previous verification. If head is not the digest of one of the
+// log's checkpoints, the log was truncated (checkpoints included) or
+// rewritten since.
+//
+// Example:
+//
+//	report, err := audit.VerifyHead(f, publicKey, lastHead)
+func VerifyHead(r io.Reader, pub ed25519.PublicKey, head string) (*Report, error) {
+	report := &Report{}
+	hashes := map[uint64]string{}
+	var expectedSeq uint64 = 1
+	prevHash := GenesisHash
+	prevCheckpoint := GenesisHash
+	headFound := head == ""
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		// Decode numbers verbatim so re-hashing reproduces the written bytes.
+		var e Entry
+		dec := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		dec.UseNumber()
+		if err := dec.Decode(&e); err != nil {
+			return report, fmt.Errorf("%w: line %d: %v", ErrMalformed, line, err)
+		}
+		problem := func(seq uint64, format string, args ...interface{}) {
+			report.Problems = append(report.Problems, Problem{Line: line, Seq: seq, Message: fmt.Sprintf(format, args...)})
+		}
+
+		switch e.Type {
+		case EntryRecord:
+			if e.Record == nil {
+				return report, fmt.Errorf("%w: line %d: record entry without record", ErrMalformed, line)
+			}
+			rec := *e.Record
+			report.Records++
+			if rec.Seq != expectedSeq {
+				if rec.Seq > expectedSeq {
+					problem(rec.Seq, "sequence gap: expected %d, got %d (records deleted)", expectedSeq, rec.Seq)
+				} else {
+					problem(rec.Seq, "sequence out of order: expected %d, got %d (records reordered or duplicated)", expectedSeq, rec.Seq)
+				}
+			}
+			if rec.PrevHash != prevHash {
+				problem(rec.Seq, "broken chain: prev_hash does not match preceding record")
+			}
+			computed, err := HashRecord(rec)
+			if err != nil {
+				return report, err
+			}
+			if computed != rec.Hash {
+				problem(rec.Seq, "hash mismatch: record contents were modified")
+			}
+			hashes[rec.Seq] = rec.Hash
+			prevHash = rec.Hash
+			expectedSeq = rec.Seq + 1
+
+		case EntryCheckpoint:
+			if e.Checkpoint == nil {
+				return report, fmt.Errorf("%w: line %d: checkpoint entry without checkpoint", ErrMalformed, line)
+			}
+			cp := *e.Checkpoint
+			report.Checkpoints++
+			if pub != nil {
+				sig, err := hex.DecodeString(cp.Signature)
+				if err != nil || !ed25519.Verify(pub, checkpointMessage(cp.Seq, cp.Hash, cp.Prev), sig) {
+					problem(cp.Seq, "invalid checkpoint signature")
+				}
+			}
+			if cp.Prev != prevCheckpoint {
+				problem(cp.Seq, "broken checkpoint chain: prev does not match preceding checkpoint (checkpoints deleted or reordered)")
+			}
+			prevCheckpoint = cp.Digest()
+			report.Head = prevCheckpoint
+			headFound = headFound || prevCheckpoint == head
+			if h, ok := hashes[cp.Seq]; !ok {
+				problem(cp.Seq, "checkpoint refers to missing record %d", cp.Seq)
+			} else if h != cp.Hash {
+				problem(cp.Seq, "checkpoint hash does not match record %d", cp.Seq)
+			}
+			if cp.Seq > report.LastCheckpoint {
+				report.LastCheckpoint = cp.Seq
+			}
+
+		default:
+			problem(0, "unknown entry type %q", e.Type)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return report, err
+	}
+
+	if !headFound {
+		report.Problems = append(report.Problems, Problem{Line: line, Message: fmt.Sprintf("checkpoint %s not found (log truncated or rewritten)", head)})
+	}
+	if expectedSeq > 1 && expectedSeq-1 > report.LastCheckpoint {
+		report.UncheckpointedN = int(expectedSeq - 1 - report.LastCheckpoint)
+	}
+	return report, nil
+}
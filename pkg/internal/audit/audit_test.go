@@ -0,0 +1,162 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"strings"
+	"testing"
+)
+
+func writeLog(t *testing.T, key ed25519.PrivateKey, n int) []string {
+	t.Helper()
+	var buf bytes.Buffer
+	log := NewLog(&buf, key, 2)
+	for i := 0; i < n; i++ {
+		err := log.Append(Record{
+			ToolCallID: "call",
+			ToolName:   "transfer",
+			Parameters: map[string]interface{}{"amount": 100 + i, "note": "x"},
+			Status:     "approved",
+		})
+		if err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	if err := log.Checkpoint(); err != nil {
+		t.Fatalf("Checkpoint failed: %v", err)
+	}
+	return strings.Split(strings.TrimSpace(buf.String()), "\n")
+}
+
+func verifyLines(t *testing.T, lines []string, pub ed25519.PublicKey) *Report {
+	t.Helper()
+	report, err := Verify(strings.NewReader(strings.Join(lines, "\n")), pub)
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	return report
+}
+
+func TestVerifyIntactLog(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	lines := writeLog(t, priv, 5)
+
+	report := verifyLines(t, lines, pub)
+	if !report.OK() {
+		t.Fatalf("Expected intact log, got problems: %+v", report.Problems)
+	}
+	if report.Records != 5 {
+		t.Errorf("Expected 5 records, got %d", report.Records)
+	}
+	if report.LastCheckpoint != 5 || report.UncheckpointedN != 0 {
+		t.Errorf("Expected final checkpoint at 5, got %d (%d uncovered)", report.LastCheckpoint, report.UncheckpointedN)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	otherPub, _, _ := ed25519.GenerateKey(nil)
+
+	tests := []struct {
+		name   string
+		mutate func([]string) []string
+		pub    ed25519.PublicKey
+	}{
+		{
+			name: "Edited record",
+			mutate: func(l []string) []string {
+				l[0] = strings.Replace(l[0], `"approved"`, `"rejected"`, 1)
+				return l
+			},
+			pub: pub,
+		},
+		{
+			name: "Deleted record",
+			mutate: func(l []string) []string {
+				return append(l[:0:0], append([]string{l[0]}, l[2:]...)...)
+			},
+			pub: pub,
+		},
+		{
+			name: "Reordered records",
+			mutate: func(l []string) []string {
+				l[0], l[1] = l[1], l[0]
+				return l
+			},
+			pub: pub,
+		},
+		{
+			name:   "Wrong signing key",
+			mutate: func(l []string) []string { return l },
+			pub:    otherPub,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := tt.mutate(writeLog(t, priv, 5))
+			report := verifyLines(t, lines, tt.pub)
+			if report.OK() {
+				t.Error("Expected tampering to be detected")
+			}
+		})
+	}
+}
+
+func TestResumeLog(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	var buf bytes.Buffer
+	lines := writeLog(t, priv, 3)
+	buf.WriteString(strings.Join(lines, "\n") + "\n")
+
+	// A restarted process continues the record and checkpoint chains.
+	log, err := ResumeLog(&buf, priv, 2)
+	if err != nil {
+		t.Fatalf("ResumeLog failed: %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := log.Append(Record{ToolName: "transfer", Status: "approved"}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+	lines = append(lines, strings.Split(strings.TrimSpace(buf.String()), "\n")...)
+	report := verifyLines(t, lines, pub)
+	if !report.OK() || report.Records != 5 || report.LastCheckpoint != 4 {
+		t.Fatalf("Expected an intact log of 5 records, got %+v", report)
+	}
+
+	if _, err := ResumeLog(bytes.NewBufferString("{"), priv, 2); err == nil {
+		t.Error("Expected an error for a malformed log")
+	}
+}
+
+func TestVerifyHead(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	lines := writeLog(t, priv, 5) // checkpoints after records 2, 4 and 5
+	head := verifyLines(t, lines, pub).Head
+
+	// Dropping the tail with its checkpoint leaves a consistent prefix that
+	// only the recorded head exposes.
+	truncated := lines[:len(lines)-3]
+	if report := verifyLines(t, truncated, pub); !report.OK() {
+		t.Fatalf("Expected a consistent prefix, got %+v", report.Problems)
+	}
+	report, err := VerifyHead(strings.NewReader(strings.Join(truncated, "\n")), pub, head)
+	if err != nil {
+		t.Fatalf("VerifyHead failed: %v", err)
+	}
+	if report.OK() {
+		t.Error("Expected truncation to be detected against the recorded head")
+	}
+
+	// Deleting a checkpoint in the middle breaks the checkpoint chain.
+	var withoutFirst []string
+	for i, l := range lines {
+		if i != 2 {
+			withoutFirst = append(withoutFirst, l)
+		}
+	}
+	if report := verifyLines(t, withoutFirst, pub); report.OK() {
+		t.Error("Expected a deleted checkpoint to be detected")
+	}
+}
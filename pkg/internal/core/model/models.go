@@ -14,24 +14,44 @@ type ToolCall struct {
 // CallContext represents the context of a tool call
 type CallContext struct {
 	UserID          string                 `json:"user_id"`
+	TenantID        string                 `json:"tenant_id,omitempty"`
 	UserRole        string                 `json:"user_role"`
 	SessionID       string                 `json:"session_id"`
 	ConversationID  string                 `json:"conversation_id"`
 	PreviousCalls   []string               `json:"previous_calls"`
 	UserPermissions []string               `json:"user_permissions"`
 	IPAddress       string                 `json:"ip_address"`
-	TimeOfDay       int                    `json:"time_of_day"` // Hour of day (0-23)
-	Metadata        map[string]interface{} `json:"metadata"`    // Arbitrary context data
+	TimeOfDay       int                    `json:"time_of_day"`       // Hour of day (0-23)
+	Metadata        map[string]interface{} `json:"metadata"`          // Arbitrary context data
+	History         []SessionCall          `json:"history,omitempty"` // Approved calls recorded by the Guard for this session
+}
+
+// SessionCall is an approved tool call recorded in a session's history
+type SessionCall struct {
+	ToolCallID string                 `json:"tool_call_id"`
+	ToolName   string                 `json:"tool_name"`
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
 }
 
 // ValidationResult represents the result of validating a tool call
 type ValidationResult struct {
 	ToolCallID          string                 `json:"tool_call_id"`
-	Status              string                 `json:"status"` // approved, rejected, rewritten
+	Status              string                 `json:"status"` // approved, rejected, rewritten, pending, timeout
 	Confidence          float64                `json:"confidence"`
 	Reason              string                 `json:"reason,omitempty"`
 	Modifications       map[string]interface{} `json:"modifications,omitempty"`
 	ExecutionAllowed    bool                   `json:"execution_allowed"`
 	SuggestedCorrection *ToolCall              `json:"suggested_correction,omitempty"`
 	PolicyAction        string                 `json:"policy_action,omitempty"`
+	PolicyID            string                 `json:"policy_id,omitempty"`
+	Origin              string                 `json:"origin,omitempty"` // Set when the decision did not come from conditions alone
 }
+
+// Origin constants name what, besides policy conditions, decided a result.
+// Such decisions depend on state outside the call and cannot be reproduced
+// from it.
+const (
+	OriginBudget = "budget" // A BUDGET policy or its usage counters
+	OriginError  = "error"  // An infrastructure failure, such as an unavailable session store
+)
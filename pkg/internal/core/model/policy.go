@@ -4,9 +4,12 @@ package model
 type PolicyAction string
 
 const (
-	PolicyActionALLOW          PolicyAction = "ALLOW"
-	PolicyActionREJECT         PolicyAction = "REJECT"
-	PolicyActionREWRITE        PolicyAction = "REWRITE"
-	PolicyActionRATE_LIMIT     PolicyAction = "RATE_LIMIT"
-	PolicyActionCONTEXT_REJECT PolicyAction = "CONTEXT_REJECT"
+	PolicyActionALLOW            PolicyAction = "ALLOW"
+	PolicyActionREJECT           PolicyAction = "REJECT"
+	PolicyActionREWRITE          PolicyAction = "REWRITE"
+	PolicyActionRATE_LIMIT       PolicyAction = "RATE_LIMIT"
+	PolicyActionCONTEXT_REJECT   PolicyAction = "CONTEXT_REJECT"
+	PolicyActionTIMEOUT          PolicyAction = "TIMEOUT"
+	PolicyActionREQUIRE_APPROVAL PolicyAction = "REQUIRE_APPROVAL"
+	PolicyActionBUDGET           PolicyAction = "BUDGET"
 )
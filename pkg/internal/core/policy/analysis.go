@@ -0,0 +1,916 @@
+package policy
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+// Static analysis explores, for each tool, every combination of the
+// predicates its policies test, and simulates the combining algorithm on
+// each. Conditions are split into predicates:
+//
+//   - comparisons of a path with string literals (==, !=, in [...]); the
+//     path takes each literal mentioned, or any other value;
+//   - has_role, over the roles mentioned in conditions and defined in the
+//     set;
+//   - has_permission and 'p' in user.permissions, consistent with the
+//     permissions the role grants;
+//   - anything else, such as params.amount > 100, as an independent
+//     predicate that may be true or false.
+//
+// Independent predicates may combine in ways no call can produce, such as
+// params.amount > 1000 without params.amount > 100, so a conflict may be
+// reported between policies that never match together. Dead rules are
+// exact in that sense: a policy that decides none of the combinations
+// decides no call. BUDGET policies and sequence rules are not analyzed.
+//
+// Policies are named by their id. Policies without one that share a
+// selector and type are told apart by their position among them in the
+// file, as in file_operations:REJECT#2.
+//
+// Example:
+//
+//	set, err := policy.LoadSet("policies.yaml", "", false, nil)
+//	analysis := set.Analyze(map[string][]string{"transfer_money": {"finance"}}, "")
+//	for _, dead := range analysis.DeadRules { /* ... */ }
+
+// maxScenarios bounds the predicate combinations explored per tool.
+const maxScenarios = 1 << 16
+
+// Analysis is the result of Set.Analyze.
+type Analysis struct {
+	Tools     []ToolAnalysis `json:"tools"`
+	Conflicts []Conflict     `json:"conflicts,omitempty"`
+	DeadRules []DeadRule     `json:"dead_rules,omitempty"`
+	// DefaultAllow lists the tools some calls reach without any matching
+	// policy, which the implicit default:allow then approves.
+	DefaultAllow []string `json:"default_allow,omitempty"`
+}
+
+// ToolAnalysis lists the decisions a tool's calls can reach.
+type ToolAnalysis struct {
+	Tool      string             `json:"tool"`
+	Algorithm CombiningAlgorithm `json:"algorithm"`
+	Policies  int                `json:"policies"`
+	Scenarios int                `json:"scenarios"` // Predicate combinations explored
+	// Truncated is set when the tool's policies test more combinations than
+	// can be explored; its outcomes are then unknown.
+	Truncated bool      `json:"truncated,omitempty"`
+	Outcomes  []Outcome `json:"outcomes,omitempty"`
+}
+
+// Outcome is a decision and the predicates leading to it. Each entry of
+// When is an alternative, written as a condition, or "always".
+type Outcome struct {
+	PolicyID string     `json:"policy_id"`
+	Action   PolicyType `json:"action"`
+	When     []string   `json:"when"`
+}
+
+// Conflict is a pair of policies with the same priority and different
+// actions (or REWRITE targets) that can match the same call. When is an
+// example of predicates matching both.
+type Conflict struct {
+	Priority     int        `json:"priority"`
+	First        string     `json:"first"`
+	FirstAction  PolicyType `json:"first_action"`
+	Second       string     `json:"second"`
+	SecondAction PolicyType `json:"second_action"`
+	Tools        []string   `json:"tools"`
+	When         string     `json:"when"`
+}
+
+// DeadRule is a policy of the set that decides no call.
+type DeadRule struct {
+	PolicyID string `json:"policy_id"`
+	Reason   string `json:"reason"`
+}
+
+// Analyze explores the set's policies for the given tools, mapped to the
+// tags their schemas declare, and for every tool a policy names exactly.
+// algorithm is used for tools the set does not configure; empty means
+// FirstApplicable.
+func (s *Set) Analyze(tools map[string][]string, algorithm CombiningAlgorithm) *Analysis {
+	names := make([]string, 0, len(tools)+len(s.policies))
+	for name := range tools {
+		names = append(names, name)
+	}
+	for name := range s.policies {
+		if _, ok := tools[name]; !ok && name != "*" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	a := &analyzer{
+		set:       s,
+		rules:     map[ruleKey]*ruleStats{},
+		conflicts: map[[2]ruleKey]*Conflict{},
+		names:     ruleNames(s),
+	}
+	analysis := &Analysis{}
+	opts := EvalOptions{Set: s, Combining: algorithm}
+	for _, name := range names {
+		ta := a.tool(name, tools[name], opts.algorithm(name))
+		analysis.Tools = append(analysis.Tools, ta)
+		for _, o := range ta.Outcomes {
+			if o.PolicyID == defaultAllow().PolicyID {
+				analysis.DefaultAllow = append(analysis.DefaultAllow, name)
+			}
+		}
+	}
+
+	for _, c := range a.conflicts {
+		analysis.Conflicts = append(analysis.Conflicts, *c)
+	}
+	sort.Slice(analysis.Conflicts, func(i, j int) bool {
+		ci, cj := analysis.Conflicts[i], analysis.Conflicts[j]
+		if ci.First != cj.First {
+			return ci.First < cj.First
+		}
+		return ci.Second < cj.Second
+	})
+	analysis.DeadRules = a.deadRules()
+	return analysis
+}
+
+// ruleKey identifies a policy across the tools it applies to.
+type ruleKey struct {
+	selector, id, condition, target string
+	priority                        int
+	typ                             PolicyType
+}
+
+func keyOf(p Policy) ruleKey {
+	return ruleKey{p.Selector(), p.id(), p.Condition, p.Target, p.Priority, p.Type}
+}
+
+// ruleStats collects what the analysis learned about one policy.
+type ruleStats struct {
+	applies     bool
+	truncated   bool
+	satisfiable bool
+	decides     bool
+	shadowedBy  map[string]bool
+}
+
+// analyzer holds the state shared by the tools of one analysis.
+type analyzer struct {
+	set       *Set
+	rules     map[ruleKey]*ruleStats
+	conflicts map[[2]ruleKey]*Conflict
+	names     map[ruleKey]string
+}
+
+// name returns the name a policy is reported by.
+func (a *analyzer) name(p Policy) string {
+	if name, ok := a.names[keyOf(p)]; ok {
+		return name
+	}
+	return p.id()
+}
+
+// ruleNames names the policies of a set, and of the default set for sets
+// layered over it, by id. Policies whose ids collide get their position
+// among the colliding ones, in registration order, as a suffix.
+func ruleNames(s *Set) map[ruleKey]string {
+	var keys []ruleKey
+	seen := map[ruleKey]bool{}
+	add := func(p Policy) {
+		if key := keyOf(p); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	sets := []*Set{s}
+	if s.inherit {
+		sets = append(sets, Default())
+	}
+	for _, set := range sets {
+		tools := make([]string, 0, len(set.policies))
+		for tool := range set.policies {
+			tools = append(tools, tool)
+		}
+		sort.Strings(tools)
+		for _, tool := range tools {
+			for _, p := range set.policies[tool] {
+				add(p)
+			}
+		}
+		for _, pp := range set.patterns.items {
+			add(pp.policy)
+		}
+	}
+
+	count := map[string]int{}
+	for _, key := range keys {
+		count[key.id]++
+	}
+	names := make(map[ruleKey]string, len(keys))
+	position := map[string]int{}
+	for _, key := range keys {
+		if count[key.id] == 1 {
+			names[key] = key.id
+			continue
+		}
+		position[key.id]++
+		names[key] = fmt.Sprintf("%s#%d", key.id, position[key.id])
+	}
+	return names
+}
+
+func (a *analyzer) stats(p Policy) *ruleStats {
+	key := keyOf(p)
+	st, ok := a.rules[key]
+	if !ok {
+		st = &ruleStats{shadowedBy: map[string]bool{}}
+		a.rules[key] = st
+	}
+	return st
+}
+
+// tool explores the policies of one tool.
+func (a *analyzer) tool(name string, tags []string, algorithm CombiningAlgorithm) ToolAnalysis {
+	var list []Policy
+	for _, p := range a.set.applicable(name, tags) {
+		if p.Type != PolicyBudget {
+			list = append(list, p)
+		}
+	}
+	formulas := make([]*formula, len(list))
+	for i, p := range list {
+		formulas[i] = parseFormula(p.Condition)
+		a.stats(p).applies = true
+	}
+	ta := ToolAnalysis{Tool: name, Algorithm: algorithm, Policies: len(list)}
+
+	sp := newSpace(a.set, name, formulas)
+	if !sp.bounded() {
+		ta.Truncated = true
+		for _, p := range list {
+			a.stats(p).truncated = true
+		}
+		return ta
+	}
+
+	// Pairs of same-priority policies that disagree, and the dimensions
+	// an example of their overlap needs.
+	type pair struct {
+		i, j int
+		dims []bool
+	}
+	var pairs []pair
+	for i := range list {
+		for j := i + 1; j < len(list) && list[j].Priority == list[i].Priority; j++ {
+			if conflicting(list[i], list[j]) {
+				pairs = append(pairs, pair{i, j, sp.uses(formulas[i], formulas[j])})
+			}
+		}
+	}
+
+	outcomes := map[int]*outcomeCubes{}
+	truth := make([]bool, len(list))
+	results := make([]PolicyResult, 0, len(list))
+	sp.each(func(sc []int) {
+		ta.Scenarios++
+		for i, f := range formulas {
+			truth[i] = sp.eval(f, sc)
+		}
+		winner, result := decide(algorithm, list, truth, results, a.name)
+		oc, ok := outcomes[winner]
+		if !ok {
+			oc = &outcomeCubes{result: result}
+			outcomes[winner] = oc
+		}
+		oc.cubes = append(oc.cubes, slices.Clone(sc))
+
+		for i, p := range list {
+			if !truth[i] {
+				continue
+			}
+			st := a.stats(p)
+			st.satisfiable = true
+			if i == winner {
+				st.decides = true
+			} else {
+				st.shadowedBy[result.PolicyID] = true
+			}
+		}
+		for _, pr := range pairs {
+			if truth[pr.i] && truth[pr.j] {
+				a.conflict(name, list[pr.i], list[pr.j], sp.render(mask(sc, pr.dims)))
+			}
+		}
+	})
+
+	winners := make([]int, 0, len(outcomes))
+	for w := range outcomes {
+		winners = append(winners, w)
+	}
+	// Policies in priority order, then the default decision.
+	sort.Slice(winners, func(i, j int) bool {
+		wi, wj := winners[i], winners[j]
+		if (wi < 0) != (wj < 0) {
+			return wj < 0
+		}
+		return wi < wj
+	})
+	for _, w := range winners {
+		oc := outcomes[w]
+		o := Outcome{PolicyID: oc.result.PolicyID, Action: oc.result.Action}
+		for _, cube := range mergeCubes(oc.cubes, sp.sizes()) {
+			o.When = append(o.When, sp.render(cube))
+		}
+		sort.Strings(o.When)
+		ta.Outcomes = append(ta.Outcomes, o)
+	}
+	return ta
+}
+
+// outcomeCubes collects the combinations leading to one decision.
+type outcomeCubes struct {
+	result PolicyResult
+	cubes  [][]int
+}
+
+// decide simulates evaluatePolicies on the policies whose conditions hold.
+// It returns the position of the deciding policy, or -1 with the default
+// decision.
+func decide(algorithm CombiningAlgorithm, list []Policy, truth []bool, matched []PolicyResult, name func(Policy) string) (int, PolicyResult) {
+	matched = matched[:0]
+	for i, p := range list {
+		if !truth[i] {
+			continue
+		}
+		// The position stands in for the reason so combine's choice can
+		// be traced back to a policy.
+		r := PolicyResult{Action: p.Type, Target: p.Target, Matched: true, PolicyID: name(p), Reason: strconv.Itoa(i)}
+		if algorithm.decisive(r) {
+			return i, r
+		}
+		matched = append(matched, r)
+	}
+	r := algorithm.combine(matched)
+	if !r.Matched {
+		return -1, r
+	}
+	i, _ := strconv.Atoi(r.Reason)
+	return i, r
+}
+
+// conflicting reports whether two policies would decide a call
+// differently.
+func conflicting(p, q Policy) bool {
+	return p.Type != q.Type || (p.Type == PolicyRewrite && p.Target != q.Target)
+}
+
+// conflict records that two policies match together for a tool.
+func (a *analyzer) conflict(tool string, p, q Policy, when string) {
+	key := [2]ruleKey{keyOf(p), keyOf(q)}
+	c, ok := a.conflicts[key]
+	if !ok {
+		c = &Conflict{
+			Priority:     p.Priority,
+			First:        a.name(p),
+			FirstAction:  p.Type,
+			Second:       a.name(q),
+			SecondAction: q.Type,
+			When:         when,
+		}
+		a.conflicts[key] = c
+	}
+	if !slices.Contains(c.Tools, tool) {
+		c.Tools = append(c.Tools, tool)
+	}
+}
+
+// deadRules lists the set's own policies that decide no call of any tool
+// explored in full.
+func (a *analyzer) deadRules() []DeadRule {
+	var own []Policy
+	for _, list := range a.set.policies {
+		own = append(own, list...)
+	}
+	for _, pp := range a.set.patterns.items {
+		own = append(own, pp.policy)
+	}
+
+	var dead []DeadRule
+	seen := map[ruleKey]bool{}
+	for _, p := range own {
+		key := keyOf(p)
+		if p.Type == PolicyBudget || seen[key] {
+			continue
+		}
+		seen[key] = true
+		st := a.rules[key]
+		var reason string
+		switch {
+		case st == nil || !st.applies:
+			reason = "applies to none of the analyzed tools"
+		case st.truncated || st.decides:
+			continue
+		case !st.satisfiable:
+			reason = "condition can never be true"
+		default:
+			by := make([]string, 0, len(st.shadowedBy))
+			for id := range st.shadowedBy {
+				by = append(by, id)
+			}
+			sort.Strings(by)
+			reason = "always decided by " + strings.Join(by, ", ")
+		}
+		dead = append(dead, DeadRule{PolicyID: a.name(p), Reason: reason})
+	}
+	sort.SliceStable(dead, func(i, j int) bool { return dead[i].PolicyID < dead[j].PolicyID })
+	return dead
+}
+
+// Predicate kinds.
+const (
+	atomEqual      = iota // path == value
+	atomRole              // has_role(value)
+	atomPermission        // has_permission(value)
+	atomOpaque            // any other predicate; value is its source
+)
+
+type atom struct {
+	kind  int
+	path  string
+	value string
+}
+
+// Formula operators.
+const (
+	opAtom = iota
+	opAnd
+	opOr
+	opNot
+	opTrue
+	opFalse
+)
+
+// formula is a condition as a tree of predicates.
+type formula struct {
+	op   int
+	args []*formula
+	atom atom
+}
+
+func predicate(kind int, path, value string) *formula {
+	return &formula{op: opAtom, atom: atom{kind: kind, path: path, value: value}}
+}
+
+func negate(f *formula) *formula {
+	return &formula{op: opNot, args: []*formula{f}}
+}
+
+// parseFormula splits a condition into predicates. A condition that does
+// not parse is a single opaque predicate.
+func parseFormula(condition string) *formula {
+	if condition == "" {
+		return &formula{op: opTrue}
+	}
+	tree, err := parser.Parse(condition)
+	if err != nil {
+		return predicate(atomOpaque, "", condition)
+	}
+	return toFormula(tree.Node)
+}
+
+func toFormula(node ast.Node) *formula {
+	switch n := node.(type) {
+	case *ast.BoolNode:
+		if n.Value {
+			return &formula{op: opTrue}
+		}
+		return &formula{op: opFalse}
+	case *ast.UnaryNode:
+		if n.Operator == "!" || n.Operator == "not" {
+			return negate(toFormula(n.Node))
+		}
+	case *ast.BinaryNode:
+		switch n.Operator {
+		case "&&", "and":
+			return &formula{op: opAnd, args: []*formula{toFormula(n.Left), toFormula(n.Right)}}
+		case "||", "or":
+			return &formula{op: opOr, args: []*formula{toFormula(n.Left), toFormula(n.Right)}}
+		case "==", "!=":
+			for _, sides := range [2][2]ast.Node{{n.Left, n.Right}, {n.Right, n.Left}} {
+				lit, isString := sides[1].(*ast.StringNode)
+				path, isPath := memberPath(sides[0])
+				if !isString || !isPath {
+					continue
+				}
+				f := predicate(atomEqual, path, lit.Value)
+				if n.Operator == "!=" {
+					f = negate(f)
+				}
+				return f
+			}
+		case "in":
+			if f, ok := membership(n.Left, n.Right); ok {
+				return f
+			}
+		}
+	case *ast.CallNode:
+		callee, isIdent := n.Callee.(*ast.IdentifierNode)
+		if !isIdent || len(n.Arguments) != 1 {
+			break
+		}
+		lit, isString := n.Arguments[0].(*ast.StringNode)
+		switch {
+		case isString && callee.Value == "has_role":
+			return predicate(atomRole, "", lit.Value)
+		case isString && callee.Value == "has_permission":
+			return predicate(atomPermission, "", lit.Value)
+		}
+	}
+	return predicate(atomOpaque, "", node.String())
+}
+
+// membership handles 'p' in user.permissions and path in ['a', 'b'].
+func membership(left, right ast.Node) (*formula, bool) {
+	if lit, ok := left.(*ast.StringNode); ok {
+		if path, ok := memberPath(right); ok && path == "user.permissions" {
+			return predicate(atomPermission, "", lit.Value), true
+		}
+		return nil, false
+	}
+	path, isPath := memberPath(left)
+	array, isArray := right.(*ast.ArrayNode)
+	if !isPath || !isArray {
+		return nil, false
+	}
+	f := &formula{op: opFalse}
+	for _, node := range array.Nodes {
+		lit, ok := node.(*ast.StringNode)
+		if !ok {
+			return nil, false
+		}
+		f = &formula{op: opOr, args: []*formula{f, predicate(atomEqual, path, lit.Value)}}
+	}
+	return f, true
+}
+
+// walk calls fn for every predicate of f.
+func (f *formula) walk(fn func(atom)) {
+	if f.op == opAtom {
+		fn(f.atom)
+	}
+	for _, arg := range f.args {
+		arg.walk(fn)
+	}
+}
+
+// dimension is one variable of a tool's predicate space. Path dimensions
+// take one of values or, at index len(values), any other value; the others
+// are true (1) or false (0).
+type dimension struct {
+	kind   int
+	name   string
+	values []string
+}
+
+func (d dimension) size() int {
+	if d.kind == atomEqual {
+		return len(d.values) + 1
+	}
+	return 2
+}
+
+// space is the predicate space of one tool.
+type space struct {
+	set    *Set
+	tool   string
+	dims   []dimension
+	lookup map[atom]int // path, permission or opaque predicate -> dimension
+	role   int          // user.role dimension, or -1
+}
+
+func newSpace(s *Set, tool string, formulas []*formula) *space {
+	paths := map[string]map[string]bool{}
+	perms, opaque := map[string]bool{}, map[string]bool{}
+	roleNames := map[string]bool{}
+	needRoles := false
+	for _, f := range formulas {
+		f.walk(func(a atom) {
+			switch a.kind {
+			case atomEqual:
+				if a.path == "tool.name" {
+					return
+				}
+				if paths[a.path] == nil {
+					paths[a.path] = map[string]bool{}
+				}
+				paths[a.path][a.value] = true
+			case atomRole:
+				roleNames[a.value] = true
+				needRoles = true
+			case atomPermission:
+				perms[a.value] = true
+				needRoles = true
+			case atomOpaque:
+				opaque[a.value] = true
+			}
+		})
+	}
+	if needRoles {
+		if paths["user.role"] == nil {
+			paths["user.role"] = map[string]bool{}
+		}
+		for role := range roleNames {
+			paths["user.role"][role] = true
+		}
+		for _, role := range s.roleNames() {
+			paths["user.role"][role] = true
+		}
+	}
+
+	sp := &space{set: s, tool: tool, lookup: map[atom]int{}, role: -1}
+	add := func(kind int, name string, values map[string]bool) {
+		sp.lookup[atom{kind: kind, path: name}] = len(sp.dims)
+		sp.dims = append(sp.dims, dimension{kind: kind, name: name, values: sortedKeys(values)})
+	}
+	if values, ok := paths["user.role"]; ok {
+		sp.role = len(sp.dims)
+		add(atomEqual, "user.role", values)
+	}
+	for _, path := range sortedKeys(paths) {
+		if path != "user.role" {
+			add(atomEqual, path, paths[path])
+		}
+	}
+	for _, perm := range sortedKeys(perms) {
+		add(atomPermission, perm, nil)
+	}
+	for _, source := range sortedKeys(opaque) {
+		add(atomOpaque, source, nil)
+	}
+	return sp
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// roleNames lists the roles the set (and, for inheriting sets, the default
+// set) defines.
+func (s *Set) roleNames() []string {
+	names := sortedKeys(s.roleClosure)
+	if s.inherit {
+		names = append(names, Default().roleNames()...)
+	}
+	return names
+}
+
+func (sp *space) sizes() []int {
+	sizes := make([]int, len(sp.dims))
+	for i, d := range sp.dims {
+		sizes[i] = d.size()
+	}
+	return sizes
+}
+
+// bounded reports whether the space is small enough to explore.
+func (sp *space) bounded() bool {
+	total := 1
+	for _, d := range sp.dims {
+		total *= d.size()
+		if total > maxScenarios {
+			return false
+		}
+	}
+	return true
+}
+
+// each calls fn with every combination consistent with the role hierarchy.
+// fn must not keep sc.
+func (sp *space) each(fn func(sc []int)) {
+	sizes := sp.sizes()
+	sc := make([]int, len(sizes))
+	for {
+		if sp.feasible(sc) {
+			fn(sc)
+		}
+		i := len(sc) - 1
+		for ; i >= 0; i-- {
+			if sc[i]++; sc[i] < sizes[i] {
+				break
+			}
+			sc[i] = 0
+		}
+		if i < 0 {
+			return
+		}
+	}
+}
+
+// userRole returns the role of a combination, if it is a named one.
+func (sp *space) userRole(sc []int) (string, bool) {
+	if sp.role < 0 {
+		return "", false
+	}
+	d := sp.dims[sp.role]
+	if v := sc[sp.role]; v < len(d.values) {
+		return d.values[v], true
+	}
+	return "", false
+}
+
+// feasible rejects combinations lacking a permission the role grants.
+func (sp *space) feasible(sc []int) bool {
+	role, ok := sp.userRole(sc)
+	if !ok {
+		return true
+	}
+	granted := sp.set.RolePermissions(role)
+	for i, d := range sp.dims {
+		if d.kind == atomPermission && sc[i] == 0 {
+			if _, found := slices.BinarySearch(granted, d.name); found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// eval evaluates a formula for a combination.
+func (sp *space) eval(f *formula, sc []int) bool {
+	switch f.op {
+	case opTrue:
+		return true
+	case opFalse:
+		return false
+	case opNot:
+		return !sp.eval(f.args[0], sc)
+	case opAnd:
+		return sp.eval(f.args[0], sc) && sp.eval(f.args[1], sc)
+	case opOr:
+		return sp.eval(f.args[0], sc) || sp.eval(f.args[1], sc)
+	}
+	a := f.atom
+	switch a.kind {
+	case atomEqual:
+		if a.path == "tool.name" {
+			return a.value == sp.tool
+		}
+		i := sp.lookup[atom{kind: atomEqual, path: a.path}]
+		d := sp.dims[i]
+		return sc[i] < len(d.values) && d.values[sc[i]] == a.value
+	case atomRole:
+		role, ok := sp.userRole(sc)
+		return ok && sp.set.HasRole(role, a.value)
+	}
+	return sc[sp.lookup[atom{kind: a.kind, path: a.value}]] == 1
+}
+
+// uses marks the dimensions the formulas depend on.
+func (sp *space) uses(formulas ...*formula) []bool {
+	used := make([]bool, len(sp.dims))
+	for _, f := range formulas {
+		f.walk(func(a atom) {
+			switch {
+			case a.kind == atomEqual && a.path != "tool.name":
+				used[sp.lookup[atom{kind: atomEqual, path: a.path}]] = true
+			case a.kind == atomRole || a.kind == atomPermission:
+				used[sp.role] = true
+				if a.kind == atomPermission {
+					used[sp.lookup[atom{kind: a.kind, path: a.value}]] = true
+				}
+			case a.kind == atomOpaque:
+				used[sp.lookup[atom{kind: a.kind, path: a.value}]] = true
+			}
+		})
+	}
+	return used
+}
+
+// wildcard marks a dimension of a cube that may take every value.
+const wildcard = -1
+
+// mask copies a combination, keeping only the used dimensions.
+func mask(sc []int, used []bool) []int {
+	cube := slices.Clone(sc)
+	for i := range cube {
+		if !used[i] {
+			cube[i] = wildcard
+		}
+	}
+	return cube
+}
+
+// mergeCubes condenses disjoint cubes by repeatedly replacing the cubes
+// that differ only in one dimension, and together cover all its values,
+// with a single cube leaving that dimension open.
+func mergeCubes(cubes [][]int, sizes []int) [][]int {
+	for merged := true; merged; {
+		merged = false
+		for d := range sizes {
+			groups := map[string][]int{}
+			var order []string
+			for i, cube := range cubes {
+				if cube[d] == wildcard {
+					continue
+				}
+				key := cubeKey(cube, d)
+				if _, ok := groups[key]; !ok {
+					order = append(order, key)
+				}
+				groups[key] = append(groups[key], i)
+			}
+			drop := map[int]bool{}
+			var added [][]int
+			for _, key := range order {
+				group := groups[key]
+				if len(group) != sizes[d] {
+					continue
+				}
+				open := slices.Clone(cubes[group[0]])
+				open[d] = wildcard
+				added = append(added, open)
+				for _, i := range group {
+					drop[i] = true
+				}
+			}
+			if len(added) == 0 {
+				continue
+			}
+			merged = true
+			kept := added
+			for i, cube := range cubes {
+				if !drop[i] {
+					kept = append(kept, cube)
+				}
+			}
+			cubes = kept
+		}
+	}
+	return cubes
+}
+
+// cubeKey renders a cube without dimension d.
+func cubeKey(cube []int, d int) string {
+	var b strings.Builder
+	for i, v := range cube {
+		if i != d {
+			b.WriteString(strconv.Itoa(v))
+		}
+		b.WriteByte(',')
+	}
+	return b.String()
+}
+
+// render writes a cube as a condition. Permissions the role grants go
+// without saying.
+func (sp *space) render(cube []int) string {
+	var granted []string
+	if sp.role >= 0 && cube[sp.role] != wildcard {
+		if role, ok := sp.userRole(cube); ok {
+			granted = sp.set.RolePermissions(role)
+		}
+	}
+	var terms []string
+	for i, v := range cube {
+		if v == wildcard {
+			continue
+		}
+		d := sp.dims[i]
+		switch {
+		case d.kind == atomEqual && v < len(d.values):
+			terms = append(terms, fmt.Sprintf("%s == '%s'", d.name, d.values[v]))
+		case d.kind == atomEqual && len(d.values) == 0:
+			// A role dimension with no roles to name.
+		case d.kind == atomEqual && len(d.values) == 1:
+			terms = append(terms, fmt.Sprintf("%s != '%s'", d.name, d.values[0]))
+		case d.kind == atomEqual:
+			terms = append(terms, fmt.Sprintf("%s not in ['%s']", d.name, strings.Join(d.values, "', '")))
+		case d.kind == atomPermission && v == 1:
+			if _, implied := slices.BinarySearch(granted, d.name); implied {
+				continue
+			}
+			terms = append(terms, fmt.Sprintf("has_permission('%s')", d.name))
+		case d.kind == atomPermission:
+			terms = append(terms, fmt.Sprintf("!has_permission('%s')", d.name))
+		case v == 1:
+			terms = append(terms, d.name)
+		default:
+			terms = append(terms, fmt.Sprintf("!(%s)", d.name))
+		}
+	}
+	if len(terms) == 0 {
+		return "always"
+	}
+	return strings.Join(terms, " && ")
+}
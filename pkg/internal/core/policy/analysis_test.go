@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAnalyze(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	content := `
+roles:
+  analyst: {permissions: [reports]}
+  admin: {inherits: [analyst], permissions: [payments]}
+policies:
+  - id: admins-only
+    tool_name: transfer
+    type: REJECT
+    condition: "!has_role('admin')"
+    priority: 20
+  - id: large-transfers
+    tool_name: transfer
+    type: REQUIRE_APPROVAL
+    condition: "params.amount > 1000"
+    priority: 10
+  - id: log-transfers
+    tool_name: transfer
+    type: LOG
+    condition: "params.currency == 'EUR' && params.amount > 1000"
+    priority: 10
+  - id: unreachable
+    tool_name: transfer
+    type: REJECT
+    condition: "params.currency == 'EUR' && params.currency == 'USD'"
+  - id: shadowed
+    tool_name: transfer
+    type: REJECT
+    condition: "!has_permission('payments')"
+  - id: report-access
+    tool_name: report
+    type: REJECT
+    condition: "!('reports' in user.permissions)"
+  - id: ops-tools
+    tool_name: "ops_*"
+    type: LOG
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := LoadSet(path, "", false, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	analysis := set.Analyze(map[string][]string{"search": nil}, "")
+
+	if names := toolNames(analysis); !reflect.DeepEqual(names, []string{"report", "search", "transfer"}) {
+		t.Fatalf("Unexpected tools: %v", names)
+	}
+	report := analysis.Tools[0]
+	want := []Outcome{
+		{PolicyID: "report-access", Action: PolicyReject, When: []string{"user.role not in ['admin', 'analyst'] && !has_permission('reports')"}},
+		{PolicyID: "default:allow", Action: PolicyAllow, When: []string{"has_permission('reports')"}},
+	}
+	if !reflect.DeepEqual(report.Outcomes, want) {
+		t.Errorf("Unexpected report outcomes: %+v", report.Outcomes)
+	}
+	transfer := analysis.Tools[2]
+	if transfer.Algorithm != FirstApplicable || transfer.Truncated || len(transfer.Outcomes) != 3 {
+		t.Fatalf("Unexpected transfer analysis: %+v", transfer)
+	}
+	if approval := transfer.Outcomes[1]; approval.PolicyID != "large-transfers" || !reflect.DeepEqual(approval.When, []string{"user.role == 'admin' && params.amount > 1000"}) {
+		t.Errorf("Unexpected approval outcome: %+v", approval)
+	}
+
+	if !reflect.DeepEqual(analysis.DefaultAllow, []string{"report", "search", "transfer"}) {
+		t.Errorf("Unexpected default:allow tools: %v", analysis.DefaultAllow)
+	}
+	if len(analysis.Conflicts) != 1 {
+		t.Fatalf("Expected one conflict, got %+v", analysis.Conflicts)
+	}
+	if c := analysis.Conflicts[0]; c.First != "large-transfers" || c.Second != "log-transfers" || c.When != "params.currency == 'EUR' && params.amount > 1000" {
+		t.Errorf("Unexpected conflict: %+v", c)
+	}
+	wantDead := []DeadRule{
+		{PolicyID: "log-transfers", Reason: "always decided by admins-only, large-transfers"},
+		{PolicyID: "ops-tools", Reason: "applies to none of the analyzed tools"},
+		{PolicyID: "shadowed", Reason: "always decided by admins-only"},
+		{PolicyID: "unreachable", Reason: "condition can never be true"},
+	}
+	if !reflect.DeepEqual(analysis.DeadRules, wantDead) {
+		t.Errorf("Unexpected dead rules: %+v", analysis.DeadRules)
+	}
+
+	// Patterns are reachable once a tool they match is analyzed.
+	analysis = set.Analyze(map[string][]string{"ops_restart": nil}, DenyOverrides)
+	if ops := analysis.Tools[0]; ops.Tool != "ops_restart" || len(ops.Outcomes) != 1 || ops.Outcomes[0].When[0] != "always" {
+		t.Errorf("Unexpected ops analysis: %+v", ops)
+	}
+	for _, dead := range analysis.DeadRules {
+		if dead.PolicyID == "ops-tools" {
+			t.Errorf("Expected ops-tools to decide ops_restart calls")
+		}
+	}
+}
+
+func TestAnalyzeNamesPoliciesWithoutID(t *testing.T) {
+	set := NewSet(false)
+	set.Register(Policy{ToolName: "delete_file", Type: PolicyReject, Condition: "user.role == 'guest'", Priority: 10})
+	set.Register(Policy{ToolName: "delete_file", Type: PolicyAllow, Priority: 5})
+	set.Register(Policy{ToolName: "delete_file", Type: PolicyReject, Condition: "user.role == 'guest'"})
+
+	analysis := set.Analyze(nil, "")
+	want := []DeadRule{{PolicyID: "delete_file:REJECT#2", Reason: "always decided by delete_file:REJECT#1"}}
+	if !reflect.DeepEqual(analysis.DeadRules, want) {
+		t.Errorf("Unexpected dead rules: %+v", analysis.DeadRules)
+	}
+}
+
+func TestMergeCubes(t *testing.T) {
+	cubes := [][]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}, {2, 1}}
+	got := mergeCubes(cubes, []int{3, 2})
+	want := [][]int{{wildcard, 1}, {0, 0}, {1, 0}}
+	if len(got) != 3 {
+		t.Fatalf("Expected 3 cubes, got %v", got)
+	}
+	for _, w := range want {
+		found := false
+		for _, g := range got {
+			found = found || reflect.DeepEqual(g, w)
+		}
+		if !found {
+			t.Errorf("Expected %v in %v", w, got)
+		}
+	}
+}
+
+func toolNames(a *Analysis) []string {
+	var names []string
+	for _, ta := range a.Tools {
+		names = append(names, ta.Tool)
+	}
+	return names
+}
@@ -0,0 +1,242 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// Attribute providers supply data the caller did not send, such as a user's
+// department or an account's balance. A provider declares the attribute
+// paths it serves; conditions may then reference those paths as if they were
+// part of the environment. Lookups are lazy: a provider is only consulted
+// when a condition actually evaluates the path, and each path is resolved at
+// most once per evaluation.
+//
+// Paths under user and metadata fill in values the caller left empty, so a
+// provider declaring user.role backs up CallContext.UserRole. Other paths,
+// like account.balance, exist only through the provider.
+//
+// Example:
+//
+//	ext := policy.NewExtensions()
+//	err := ext.RegisterAttributeProvider(directory) // declares "user.department", "account.balance"
+//	// condition: "user.department == 'finance' && account.balance >= params.amount"
+
+// AttributeProvider resolves attributes for a tool call on demand.
+type AttributeProvider interface {
+	// Attributes lists the dotted paths the provider serves, e.g.
+	// "user.department" or "account.balance".
+	Attributes() []string
+	// Resolve returns the value of path for tc. A nil value means the
+	// attribute is unknown for this call; an error fails the condition.
+	Resolve(ctx context.Context, path string, tc model.ToolCall) (interface{}, error)
+}
+
+// attributeFunction is the hidden helper conditions call to resolve a
+// provider attribute.
+const attributeFunction = "hguard_attr"
+
+// attributeOption declares the hidden helper to expr.
+var attributeOption = expr.Function(attributeFunction, func(params ...interface{}) (interface{}, error) {
+	return params[0].(*callScope).attribute(params[1].(string), params[2])
+}, new(func(*callScope, string, interface{}) interface{}))
+
+var (
+	attributesMu       sync.RWMutex
+	attributeProviders = map[string]AttributeProvider{}
+	// reservedRoots hold the call's own data and cannot be provided.
+	reservedRoots = map[string]bool{"params": true, "session": true, "tool": true, "time": true, "request": true, callScopeVar: true, permissionsFunction: true}
+)
+
+// RegisterAttributeProvider makes the provider's attributes available to
+// conditions in every policy set. A path registered again is served by the
+// later provider. Use an Extensions table for providers that only some
+// evaluations should see.
+func RegisterAttributeProvider(p AttributeProvider) error {
+	paths := p.Attributes()
+	for _, path := range paths {
+		if err := validAttributePath(path); err != nil {
+			return err
+		}
+	}
+	attributesMu.Lock()
+	for _, path := range paths {
+		attributeProviders[path] = p
+	}
+	attributesMu.Unlock()
+	// Programs compiled before the paths existed are stale, and so are
+	// indexes over the paths.
+	registrations.Add(1)
+	return nil
+}
+
+// Attributes returns the attribute paths served by providers registered at
+// package level, sorted.
+func Attributes() []string {
+	attributesMu.RLock()
+	defer attributesMu.RUnlock()
+	paths := make([]string, 0, len(attributeProviders))
+	for path := range attributeProviders {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func validAttributePath(path string) error {
+	parts := strings.Split(path, ".")
+	if len(parts) < 2 {
+		return fmt.Errorf("invalid attribute path %q: want root.name", path)
+	}
+	for _, part := range parts {
+		if !identifier.MatchString(part) {
+			return fmt.Errorf("invalid attribute path %q", path)
+		}
+	}
+	if reservedRoots[parts[0]] {
+		return fmt.Errorf("invalid attribute path %q: %s cannot be provided", path, parts[0])
+	}
+	if parts[0] == "user" && len(parts) == 2 && userField(parts[1]) && parts[1] != "role" && parts[1] != "permissions" {
+		return fmt.Errorf("invalid attribute path %q: %s is set by the caller", path, path)
+	}
+	return nil
+}
+
+// userField reports whether name is a field of UserEnv.
+func userField(name string) bool {
+	t := reflect.TypeOf(UserEnv{})
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("expr") == name {
+			return true
+		}
+	}
+	return false
+}
+
+// attributePaths returns a snapshot of the paths registered at package
+// level.
+func attributePaths() map[string]bool {
+	attributesMu.RLock()
+	defer attributesMu.RUnlock()
+	if len(attributeProviders) == 0 {
+		return nil
+	}
+	paths := make(map[string]bool, len(attributeProviders))
+	for path := range attributeProviders {
+		paths[path] = true
+	}
+	return paths
+}
+
+// attribute resolves path for the scope's call, once per scope. fallback is
+// the value the caller sent for the path, which wins when it is set.
+func (s *callScope) attribute(path string, fallback interface{}) (interface{}, error) {
+	if !isZero(fallback) {
+		return fallback, nil
+	}
+	p, ok := s.ext.provider(path)
+	if !ok {
+		return nil, nil
+	}
+	s.mu.Lock()
+	v, ok := s.attrs[path]
+	s.mu.Unlock()
+	if ok {
+		return v, nil
+	}
+	v, err := p.Resolve(s.ctx, path, s.tc)
+	if err != nil {
+		return nil, fmt.Errorf("attribute %s: %w", path, err)
+	}
+	s.mu.Lock()
+	if s.attrs == nil {
+		s.attrs = map[string]interface{}{}
+	}
+	s.attrs[path] = v
+	s.mu.Unlock()
+	return v, nil
+}
+
+// userContext returns cc with the role and permissions filled in from
+// providers when the caller did not send them. Lookup errors panic so the
+// condition fails; expr recovers them into evaluation errors.
+func (s *callScope) userContext(cc model.CallContext) model.CallContext {
+	if cc.UserRole == "" {
+		v, err := s.attribute("user.role", nil)
+		if err != nil {
+			panic(err)
+		}
+		cc.UserRole = text(v)
+	}
+	if len(cc.UserPermissions) == 0 {
+		v, err := s.attribute("user.permissions", nil)
+		if err != nil {
+			panic(err)
+		}
+		switch perms := v.(type) {
+		case []string:
+			cc.UserPermissions = perms
+		case []interface{}:
+			for _, p := range perms {
+				cc.UserPermissions = append(cc.UserPermissions, text(p))
+			}
+		}
+	}
+	return cc
+}
+
+func isZero(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	}
+	return rv.IsZero()
+}
+
+// memberPath renders a chain of member accesses on an identifier, such as
+// account.balance or user["department"], as a dotted path.
+func memberPath(node ast.Node) (string, bool) {
+	switch n := node.(type) {
+	case *ast.IdentifierNode:
+		return n.Value, true
+	case *ast.MemberNode:
+		prop, ok := n.Property.(*ast.StringNode)
+		if !ok || n.Optional {
+			return "", false
+		}
+		base, ok := memberPath(n.Node)
+		if !ok {
+			return "", false
+		}
+		return base + "." + prop.Value, true
+	}
+	return "", false
+}
+
+// attributeCall rewrites an access to a provided path into a call to the
+// hidden attribute helper. Paths the environment already has pass their
+// current value as the fallback.
+func attributeCall(path string, node ast.Node) ast.Node {
+	var fallback ast.Node = &ast.NilNode{}
+	root, rest, _ := strings.Cut(path, ".")
+	if root == "metadata" || (root == "user" && userField(rest)) {
+		fallback = node
+	}
+	return &ast.CallNode{
+		Callee:    &ast.IdentifierNode{Value: attributeFunction},
+		Arguments: []ast.Node{&ast.IdentifierNode{Value: callScopeVar}, &ast.StringNode{Value: path}, fallback},
+	}
+}
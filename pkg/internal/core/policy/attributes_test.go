@@ -0,0 +1,147 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// directory is a test AttributeProvider that counts its lookups.
+type directory struct {
+	values  map[string]map[string]interface{} // user ID -> path -> value
+	lookups map[string]int
+}
+
+func (d *directory) Attributes() []string {
+	return []string{"user.department", "user.role", "account.balance", "account.limits", "metadata.tier"}
+}
+
+func (d *directory) Resolve(ctx context.Context, path string, tc model.ToolCall) (interface{}, error) {
+	d.lookups[path]++
+	if tc.Context.UserID == "broken" {
+		return nil, errors.New("directory unavailable")
+	}
+	return d.values[tc.Context.UserID][path], nil
+}
+
+func TestAttributeProviders(t *testing.T) {
+	dir := &directory{
+		values: map[string]map[string]interface{}{
+			"attr-u1": {"user.department": "finance", "user.role": "manager", "account.balance": 500, "account.limits": map[string]interface{}{"daily": 100}, "metadata.tier": "gold"},
+			"attr-u2": {"user.department": "sales", "account.balance": 50},
+		},
+		lookups: map[string]int{},
+	}
+	ext := NewExtensions()
+	if err := ext.RegisterAttributeProvider(dir); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, path := range []string{"user.id", "params.amount", "balance"} {
+		if err := ext.RegisterAttributeProvider(pathProvider(path)); err == nil {
+			t.Errorf("Expected error registering %s", path)
+		}
+	}
+
+	set := NewSet(false)
+	set.Register(Policy{ID: "finance-only", ToolName: "transfer", Type: PolicyReject, Condition: "user.department != 'finance'", Priority: 30})
+	set.Register(Policy{ID: "overdraft", ToolName: "transfer", Type: PolicyReject, Condition: "account.balance < params.amount", Priority: 20})
+	set.Register(Policy{ID: "daily-limit", ToolName: "transfer", Type: PolicyReject, Condition: "params.amount > account.limits.daily", Priority: 10})
+	set.Register(Policy{ID: "managers", ToolName: "report", Type: PolicyAllow, Condition: "has_role('manager') && metadata.tier == 'gold'", Priority: 10})
+	set.Register(Policy{ID: "others", ToolName: "report", Type: PolicyReject, Priority: 1})
+	set.Register(Policy{ID: "reports-only", ToolName: "audit", Type: PolicyReject, Condition: "!('reports' in user.permissions) || !has_permission('reports')"})
+	closure, err := compileRoles(map[string]Role{"manager": {Permissions: []string{"reports"}}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	set.roleClosure = closure
+
+	call := func(tool, user, role string, amount int) model.ToolCall {
+		return model.ToolCall{
+			Name:       tool,
+			Parameters: map[string]interface{}{"amount": amount},
+			Context:    model.CallContext{UserID: user, UserRole: role},
+		}
+	}
+	tests := []struct {
+		name     string
+		tc       model.ToolCall
+		policyID string
+	}{
+		{"Provided department and balance", call("transfer", "attr-u1", "", 80), "default:allow"},
+		{"Nested provided value", call("transfer", "attr-u1", "", 200), "daily-limit"},
+		{"Insufficient balance", call("transfer", "attr-u1", "", 600), "overdraft"},
+		{"Other department", call("transfer", "attr-u2", "", 10), "finance-only"},
+		{"Unknown user", call("transfer", "nobody", "", 10), "finance-only"},
+		{"Provided role backs up has_role", call("report", "attr-u1", "", 0), "managers"},
+		{"Sent role wins", call("report", "attr-u1", "viewer", 0), "others"},
+		{"Lookup error skips the policy", call("report", "broken", "", 0), "others"},
+		{"Provided role grants user.permissions", call("audit", "attr-u1", "", 0), "default:allow"},
+		{"No provided role", call("audit", "attr-u2", "", 0), "reports-only"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluatePolicyWithOptions(context.Background(), tt.tc, EvalOptions{Set: set, Extensions: ext})
+			if result.PolicyID != tt.policyID {
+				t.Errorf("Expected %s, got %s (%s)", tt.policyID, result.PolicyID, result.Reason)
+			}
+		})
+	}
+
+	// Each path is looked up once per call, and only when a condition
+	// reaches it: other departments never look up the balance.
+	dir.lookups = map[string]int{}
+	EvaluatePolicyWithOptions(context.Background(), call("transfer", "attr-u2", "", 10), EvalOptions{Set: set, Extensions: ext})
+	if dir.lookups["user.department"] != 1 || dir.lookups["account.balance"] != 0 {
+		t.Errorf("Expected only a department lookup, got %v", dir.lookups)
+	}
+	dir.lookups = map[string]int{}
+	EvaluatePolicyWithOptions(context.Background(), call("transfer", "attr-u1", "", 80), EvalOptions{Set: set, Extensions: ext})
+	if dir.lookups["user.department"] != 1 || dir.lookups["account.balance"] != 1 || dir.lookups["account.limits"] != 1 {
+		t.Errorf("Expected one lookup per path, got %v", dir.lookups)
+	}
+
+	if err := ext.CheckExpression("account.owner == 'x'"); err == nil {
+		t.Error("Expected unprovided attribute to fail type-checking")
+	}
+	if err := CheckExpression("account.balance > 0"); err == nil {
+		t.Error("Expected attributes of a table to be unknown at package level")
+	}
+
+	// Another table serves the same path from its own provider.
+	other := NewExtensions()
+	if err := other.RegisterAttributeProvider(staticProvider{"user.department": "finance", "account.balance": 1000}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := other.CheckExpression("account.limits.daily > 0"); err == nil {
+		t.Error("Expected providers of one table to be unknown to another")
+	}
+	if result := EvaluatePolicyWithOptions(context.Background(), call("transfer", "attr-u2", "", 600), EvalOptions{Set: set, Extensions: other}); result.PolicyID != "default:allow" {
+		t.Errorf("Expected the other table's values, got %s (%s)", result.PolicyID, result.Reason)
+	}
+}
+
+// pathProvider declares a single path and resolves nothing.
+type pathProvider string
+
+func (p pathProvider) Attributes() []string { return []string{string(p)} }
+
+func (p pathProvider) Resolve(ctx context.Context, path string, tc model.ToolCall) (interface{}, error) {
+	return nil, nil
+}
+
+// staticProvider serves fixed values.
+type staticProvider map[string]interface{}
+
+func (p staticProvider) Attributes() []string {
+	var paths []string
+	for path := range p {
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func (p staticProvider) Resolve(ctx context.Context, path string, tc model.ToolCall) (interface{}, error) {
+	return p[path], nil
+}
@@ -0,0 +1,94 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// benchmarkSet builds a set of n policies spread over n/20 tools (at least
+// one), with one in fifty written as a wildcard or pattern policy. Conditions
+// mix role, parameter and metadata checks, none of which match the benchmark
+// call. Unless indexed is set, they have no equality predicates to index, so
+// every applicable policy is evaluated.
+func benchmarkSet(n int, indexed bool) *Set {
+	set := NewSet(false)
+	tools := n / 20
+	if tools == 0 {
+		tools = 1
+	}
+	for i := 0; i < n; i++ {
+		p := Policy{
+			ID:        fmt.Sprintf("p%d", i),
+			ToolName:  fmt.Sprintf("tool_%d", i%tools),
+			Type:      PolicyReject,
+			Condition: fmt.Sprintf("user.role == 'role_%d' && params.amount > %d", i, i),
+			Priority:  i % 50,
+		}
+		switch {
+		case i%100 == 1:
+			p.ToolName = "*"
+			p.Condition = fmt.Sprintf("metadata.region == 'region_%d'", i)
+		case i%100 == 51:
+			p.ToolName = fmt.Sprintf("tool_%d*", i%tools)
+		}
+		if !indexed {
+			p.Condition = fmt.Sprintf("params.amount > %d", 1000+i)
+		}
+		set.Register(p)
+	}
+	return set
+}
+
+func benchmarkCall() model.ToolCall {
+	return model.ToolCall{
+		ID:         "call_1",
+		Name:       "tool_0",
+		Parameters: map[string]interface{}{"amount": 10, "currency": "EUR"},
+		Context: model.CallContext{
+			UserID:   "user123",
+			UserRole: "user",
+			Metadata: map[string]interface{}{"region": "eu"},
+		},
+	}
+}
+
+// BenchmarkEvaluatePolicy measures a full evaluation of a call against 1,
+// 100 and 10k policies, with and without indexable conditions. Run with
+// -benchmem to see allocations per call.
+func BenchmarkEvaluatePolicy(b *testing.B) {
+	for _, n := range []int{1, 100, 10000} {
+		for _, indexed := range []bool{true, false} {
+			b.Run(fmt.Sprintf("policies=%d/indexed=%t", n, indexed), func(b *testing.B) {
+				set := benchmarkSet(n, indexed)
+				tc := benchmarkCall()
+				opts := EvalOptions{Set: set}
+				ctx := context.Background()
+				if result := EvaluatePolicyWithOptions(ctx, tc, opts); result.PolicyID != "default:allow" {
+					b.Fatalf("Expected default:allow, got %s", result.PolicyID)
+				}
+				b.ReportAllocs()
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					EvaluatePolicyWithOptions(ctx, tc, opts)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkMatching measures looking up the policies that apply to a tool.
+func BenchmarkMatching(b *testing.B) {
+	for _, n := range []int{1, 100, 10000} {
+		b.Run(fmt.Sprintf("policies=%d", n), func(b *testing.B) {
+			set := benchmarkSet(n, true)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				set.Matching("tool_0", nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package policy
+
+import (
+	"fmt"
+
+	"gopkg.in/yaml.v3"
+)
+
+// A combining algorithm decides the outcome when several policies match a
+// call, following XACML's rule-combining algorithms:
+//
+//   - first-applicable (default): the highest-priority matching policy wins;
+//     equal priorities keep registration order.
+//   - deny-overrides: any matching REJECT wins, otherwise the most restrictive
+//     matching policy (REQUIRE_APPROVAL, then REWRITE, then LOG, RATE_LIMIT
+//     and ALLOW).
+//   - permit-overrides: any matching ALLOW, LOG or RATE_LIMIT wins, otherwise
+//     the highest-priority matching policy.
+//   - all-must-allow: like deny-overrides, but a call no policy matches is
+//     rejected instead of allowed.
+//
+// Example YAML:
+//
+//	combining:
+//	  default: deny-overrides
+//	  tools:
+//	    search: first-applicable
+//
+// or, for a single global algorithm, combining: deny-overrides.
+
+// CombiningAlgorithm names a combining algorithm.
+type CombiningAlgorithm string
+
+const (
+	FirstApplicable CombiningAlgorithm = "first-applicable"
+	DenyOverrides   CombiningAlgorithm = "deny-overrides"
+	PermitOverrides CombiningAlgorithm = "permit-overrides"
+	AllMustAllow    CombiningAlgorithm = "all-must-allow"
+)
+
+// Valid reports whether a is a known algorithm.
+func (a CombiningAlgorithm) Valid() bool {
+	switch a {
+	case FirstApplicable, DenyOverrides, PermitOverrides, AllMustAllow:
+		return true
+	}
+	return false
+}
+
+// Combining selects the combining algorithm of a set, globally and per tool.
+type Combining struct {
+	Default CombiningAlgorithm            `yaml:"default,omitempty"`
+	Tools   map[string]CombiningAlgorithm `yaml:"tools,omitempty"`
+}
+
+// UnmarshalYAML accepts either a mapping or a single algorithm name, which
+// sets Default.
+func (c *Combining) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		c.Default = CombiningAlgorithm(value.Value)
+		return nil
+	}
+	type plain Combining
+	return value.Decode((*plain)(c))
+}
+
+// validate checks every algorithm name.
+func (c Combining) validate() error {
+	if c.Default != "" && !c.Default.Valid() {
+		return fmt.Errorf("unknown combining algorithm %q", c.Default)
+	}
+	for tool, a := range c.Tools {
+		if !a.Valid() {
+			return fmt.Errorf("tool %s: unknown combining algorithm %q", tool, a)
+		}
+	}
+	return nil
+}
+
+// merge overlays other onto c.
+func (c *Combining) merge(other Combining) {
+	if other.Default != "" {
+		c.Default = other.Default
+	}
+	for tool, a := range other.Tools {
+		if c.Tools == nil {
+			c.Tools = map[string]CombiningAlgorithm{}
+		}
+		c.Tools[tool] = a
+	}
+}
+
+// Default set's combining configuration
+var combining = &Combining{}
+
+// SetCombining replaces the default set's combining configuration.
+//
+// Example:
+//
+//	err := policy.SetCombining(policy.Combining{Default: policy.DenyOverrides})
+func SetCombining(c Combining) error {
+	if err := c.validate(); err != nil {
+		return err
+	}
+	*combining = c
+	return nil
+}
+
+// Algorithm returns the combining algorithm configured for a tool, or "" if
+// neither the set nor (for inheriting sets) the default set chooses one.
+func (s *Set) Algorithm(toolName string) CombiningAlgorithm {
+	if a, ok := s.combining.Tools[toolName]; ok {
+		return a
+	}
+	if s.combining.Default != "" {
+		return s.combining.Default
+	}
+	if s.inherit {
+		return Default().Algorithm(toolName)
+	}
+	return ""
+}
+
+// algorithm returns the algorithm to evaluate a tool with.
+func (o EvalOptions) algorithm(toolName string) CombiningAlgorithm {
+	if a := o.set().Algorithm(toolName); a != "" {
+		return a
+	}
+	if o.Combining != "" {
+		return o.Combining
+	}
+	return FirstApplicable
+}
+
+// restrictiveness ranks policy outcomes for deny-overrides. RATE_LIMIT
+// policies only annotate the call, which is still allowed, so they rank with
+// LOG.
+func restrictiveness(t PolicyType) int {
+	switch t {
+	case PolicyReject, PolicyContextReject, PolicyBudget:
+		return 4
+	case PolicyRequireApproval:
+		return 3
+	case PolicyRewrite:
+		return 2
+	case PolicyLog, PolicyRateLimit:
+		return 1
+	}
+	return 0
+}
+
+func isDeny(t PolicyType) bool { return restrictiveness(t) == 4 }
+
+func isPermit(t PolicyType) bool {
+	return t == PolicyAllow || t == PolicyLog || t == PolicyRateLimit
+}
+
+// decisive reports whether a matching policy settles the outcome without
+// evaluating the remaining (lower-priority) policies.
+func (a CombiningAlgorithm) decisive(r PolicyResult) bool {
+	switch a {
+	case DenyOverrides, AllMustAllow:
+		return isDeny(r.Action)
+	case PermitOverrides:
+		return isPermit(r.Action)
+	}
+	return true
+}
+
+// combine picks the outcome from the matching policies, in priority order,
+// when none of them was decisive.
+func (a CombiningAlgorithm) combine(matched []PolicyResult) PolicyResult {
+	if len(matched) == 0 {
+		if a == AllMustAllow {
+			return PolicyResult{
+				Action:   PolicyReject,
+				Reason:   "No policy allows this call",
+				PolicyID: "default:reject",
+			}
+		}
+		return defaultAllow()
+	}
+	if a == PermitOverrides {
+		return matched[0]
+	}
+	best := matched[0]
+	for _, r := range matched[1:] {
+		if restrictiveness(r.Action) > restrictiveness(best.Action) {
+			best = r
+		}
+	}
+	return best
+}
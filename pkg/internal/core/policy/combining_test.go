@@ -0,0 +1,126 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestCombiningAlgorithms(t *testing.T) {
+	set := NewSet(false)
+	set.Register(Policy{ID: "allow-admins", ToolName: "transfer", Type: PolicyAllow, Condition: "user.role == 'admin'", Priority: 20})
+	set.Register(Policy{ID: "no-large", ToolName: "transfer", Type: PolicyReject, Condition: "params.amount > 1000", Priority: 10})
+	set.Register(Policy{ID: "review-flagged", ToolName: "transfer", Type: PolicyReject, Condition: "params.flagged == true", Priority: 30})
+	set.Register(Policy{ID: "approve-medium", ToolName: "transfer", Type: PolicyRequireApproval, Condition: "params.amount > 100", Priority: 5})
+
+	call := func(role string, amount int) model.ToolCall {
+		return model.ToolCall{
+			Name:       "transfer",
+			Parameters: map[string]interface{}{"amount": amount},
+			Context:    model.CallContext{UserRole: role},
+		}
+	}
+	flagged := call("admin", 10)
+	flagged.Parameters["flagged"] = true
+
+	tests := []struct {
+		name      string
+		algorithm CombiningAlgorithm
+		tc        model.ToolCall
+		policyID  string
+	}{
+		{"First applicable takes highest priority", FirstApplicable, call("admin", 5000), "allow-admins"},
+		{"Deny overrides a higher-priority allow", DenyOverrides, call("admin", 5000), "no-large"},
+		{"Deny overrides picks most restrictive", DenyOverrides, call("admin", 500), "approve-medium"},
+		{"Permit overrides a higher-priority deny", PermitOverrides, flagged, "allow-admins"},
+		{"First applicable keeps the higher-priority deny", FirstApplicable, flagged, "review-flagged"},
+		{"Permit overrides falls back to first match", PermitOverrides, call("user", 5000), "no-large"},
+		{"Deny overrides allows unmatched calls", DenyOverrides, call("user", 10), "default:allow"},
+		{"All must allow rejects unmatched calls", AllMustAllow, call("user", 10), "default:reject"},
+		{"All must allow accepts an allowed call", AllMustAllow, call("admin", 10), "allow-admins"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluatePolicyWithOptions(context.Background(), tt.tc, EvalOptions{Set: set, Combining: tt.algorithm})
+			if result.PolicyID != tt.policyID {
+				t.Errorf("Expected %s, got %s (%s)", tt.policyID, result.PolicyID, result.Action)
+			}
+		})
+	}
+}
+
+func TestCombiningRateLimit(t *testing.T) {
+	set := NewSet(false)
+	set.Register(Policy{ID: "throttle", ToolName: "transfer", Type: PolicyRateLimit, Priority: 10})
+	set.Register(Policy{ID: "no-large", ToolName: "transfer", Type: PolicyReject, Condition: "params.amount > 1000", Priority: 5})
+	tc := model.ToolCall{Name: "transfer", Parameters: map[string]interface{}{"amount": 5000}}
+
+	tests := []struct {
+		algorithm CombiningAlgorithm
+		policyID  string
+	}{
+		{FirstApplicable, "throttle"},
+		{DenyOverrides, "no-large"},
+		{PermitOverrides, "throttle"},
+		{AllMustAllow, "no-large"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.algorithm), func(t *testing.T) {
+			result := EvaluatePolicyWithOptions(context.Background(), tc, EvalOptions{Set: set, Combining: tt.algorithm})
+			if result.PolicyID != tt.policyID {
+				t.Errorf("Expected %s, got %s (%s)", tt.policyID, result.PolicyID, result.Action)
+			}
+		})
+	}
+
+	// The analysis simulates the same ranking.
+	outcomes := set.Analyze(nil, DenyOverrides).Tools[0].Outcomes
+	if len(outcomes) != 2 || outcomes[0].PolicyID != "throttle" || outcomes[1].PolicyID != "no-large" {
+		t.Errorf("Unexpected deny-overrides outcomes: %+v", outcomes)
+	}
+}
+
+func TestCombiningFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policies.yaml")
+	content := `
+combining:
+  default: deny-overrides
+  tools:
+    search: first-applicable
+policies:
+  - tool_name: "*"
+    type: ALLOW
+    priority: 100
+  - tool_name: search
+    type: REJECT
+  - tool_name: delete_file
+    type: REJECT
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := LoadSet(path, "", false, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if a := set.Algorithm("search"); a != FirstApplicable {
+		t.Errorf("Expected first-applicable for search, got %q", a)
+	}
+	for tool, expected := range map[string]PolicyType{"search": PolicyAllow, "delete_file": PolicyReject} {
+		result := EvaluatePolicyWithOptions(context.Background(), model.ToolCall{Name: tool}, EvalOptions{Set: set})
+		if result.Action != expected {
+			t.Errorf("%s: expected %v, got %v", tool, expected, result.Action)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("combining: most-recent\npolicies: []\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSet(path, "", false, nil); err == nil {
+		t.Error("Expected error for unknown combining algorithm")
+	}
+}
@@ -0,0 +1,306 @@
+package policy
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policy files can be composed from several documents.
+//
+//   - include: lists other policy files (or globs), resolved relative to the
+//     including file. Their definitions, policies and sequences come first.
+//     A file reached by more than one include is read once, at its first
+//     inclusion.
+//   - definitions: names reusable condition fragments. A condition refers to
+//     one as $name; definitions may refer to each other.
+//   - An environment overlay (policies.prod.yaml next to policies.yaml) is
+//     applied on top of the base: its definitions, roles and combining
+//     algorithms override the base's, its policies replace base policies
+//     with the same id (or are appended), and remove: drops base policies
+//     by id.
+//
+// Example YAML:
+//
+//	include:
+//	  - common/*.yaml
+//	definitions:
+//	  is_privileged: "user.role == 'admin' || user.role == 'manager'"
+//	policies:
+//	  - id: no-sms-for-staff
+//	    tool_name: notification
+//	    type: REJECT
+//	    condition: "params.type == 'sms' && !$is_privileged"
+
+// EnvVar names the environment variable that selects the overlay applied by
+// LoadPoliciesFromYAML.
+const EnvVar = "HGUARD_ENV"
+
+// policyFile is one policy document as written on disk.
+type policyFile struct {
+	Include     []string          `yaml:"include,omitempty"`
+	Definitions map[string]string `yaml:"definitions,omitempty"`
+	Roles       map[string]Role   `yaml:"roles,omitempty"`
+	Remove      []string          `yaml:"remove,omitempty"` // overlay only: ids of base policies to drop
+	Combining   Combining         `yaml:"combining,omitempty"`
+	Policies    []Policy          `yaml:"policies"`
+	Sequences   []SequenceRule    `yaml:"sequences"`
+}
+
+// OverlayPath returns the overlay file for env next to path, e.g.
+// "policies.prod.yaml" for "policies.yaml" and "prod".
+func OverlayPath(path, env string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + env + ext
+}
+
+// composePolicies reads path with its includes and, if env is set and an
+// overlay file exists, applies the overlay. Definitions are expanded in every
+// condition of the result.
+func composePolicies(path, env string) (policyFile, error) {
+	resolved := map[string]bool{}
+	base, err := resolveFile(path, nil, resolved)
+	if err != nil {
+		return policyFile{}, err
+	}
+	if len(base.Remove) > 0 {
+		return policyFile{}, fmt.Errorf("%s: remove is only allowed in overlay files", path)
+	}
+	if env != "" {
+		overlayPath := OverlayPath(path, env)
+		if _, err := os.Stat(overlayPath); err == nil {
+			overlay, err := resolveFile(overlayPath, nil, resolved)
+			if err != nil {
+				return policyFile{}, err
+			}
+			if base, err = applyOverlay(base, overlay); err != nil {
+				return policyFile{}, fmt.Errorf("%s: %w", overlayPath, err)
+			}
+		} else if !os.IsNotExist(err) {
+			return policyFile{}, err
+		}
+	}
+	if err := expandDefinitions(&base); err != nil {
+		return policyFile{}, err
+	}
+	return base, nil
+}
+
+// resolveFile decodes path and merges in its includes. stack holds the files
+// currently being resolved, for cycle detection, and resolved the absolute
+// paths of files already merged, which are not merged again: with A
+// including B and C, and both of them D, D's policies load once.
+func resolveFile(path string, stack []string, resolved map[string]bool) (policyFile, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return policyFile{}, err
+	}
+	for i, p := range stack {
+		if p == abs {
+			cycle := append(append([]string(nil), stack[i:]...), abs)
+			return policyFile{}, fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+	if resolved[abs] {
+		return policyFile{}, nil
+	}
+	resolved[abs] = true
+	stack = append(stack, abs)
+
+	f, err := os.Open(path)
+	if err != nil {
+		return policyFile{}, err
+	}
+	defer f.Close()
+	var doc policyFile
+	if err := yaml.NewDecoder(f).Decode(&doc); err != nil {
+		return policyFile{}, fmt.Errorf("%s: %w", path, err)
+	}
+
+	merged := policyFile{Definitions: map[string]string{}, Roles: map[string]Role{}}
+	for _, pattern := range doc.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return policyFile{}, fmt.Errorf("%s: include %q: %w", path, pattern, err)
+		}
+		if len(matches) == 0 {
+			return policyFile{}, fmt.Errorf("%s: include %q matched no files", path, pattern)
+		}
+		sort.Strings(matches)
+		for _, m := range matches {
+			inc, err := resolveFile(m, stack, resolved)
+			if err != nil {
+				return policyFile{}, err
+			}
+			for k, v := range inc.Definitions {
+				merged.Definitions[k] = v
+			}
+			for k, v := range inc.Roles {
+				merged.Roles[k] = v
+			}
+			merged.Combining.merge(inc.Combining)
+			merged.Remove = append(merged.Remove, inc.Remove...)
+			merged.Policies = append(merged.Policies, inc.Policies...)
+			merged.Sequences = append(merged.Sequences, inc.Sequences...)
+		}
+	}
+	for k, v := range doc.Definitions {
+		merged.Definitions[k] = v
+	}
+	for k, v := range doc.Roles {
+		merged.Roles[k] = v
+	}
+	merged.Combining.merge(doc.Combining)
+	merged.Remove = append(merged.Remove, doc.Remove...)
+	merged.Policies = append(merged.Policies, doc.Policies...)
+	merged.Sequences = append(merged.Sequences, doc.Sequences...)
+	return merged, nil
+}
+
+// applyOverlay patches base with overlay.
+func applyOverlay(base, overlay policyFile) (policyFile, error) {
+	index := map[string]int{}
+	for i, p := range base.Policies {
+		if p.ID != "" {
+			index[p.ID] = i
+		}
+	}
+	removed := map[string]bool{}
+	for _, id := range overlay.Remove {
+		if _, ok := index[id]; !ok {
+			return policyFile{}, fmt.Errorf("remove: no base policy with id %q", id)
+		}
+		removed[id] = true
+	}
+
+	for k, v := range overlay.Definitions {
+		base.Definitions[k] = v
+	}
+	for k, v := range overlay.Roles {
+		base.Roles[k] = v
+	}
+	base.Combining.merge(overlay.Combining)
+	for _, p := range overlay.Policies {
+		if i, ok := index[p.ID]; ok && p.ID != "" {
+			base.Policies[i] = p
+			continue
+		}
+		base.Policies = append(base.Policies, p)
+	}
+	kept := base.Policies[:0]
+	for _, p := range base.Policies {
+		if !removed[p.ID] {
+			kept = append(kept, p)
+		}
+	}
+	base.Policies = kept
+	base.Sequences = append(base.Sequences, overlay.Sequences...)
+	return base, nil
+}
+
+// expandDefinitions replaces $name references in every condition.
+func expandDefinitions(doc *policyFile) error {
+	expanded := map[string]string{}
+	var expand func(name string, stack []string) (string, error)
+	expand = func(name string, stack []string) (string, error) {
+		if v, ok := expanded[name]; ok {
+			return v, nil
+		}
+		for _, s := range stack {
+			if s == name {
+				return "", fmt.Errorf("definition cycle: $%s", strings.Join(append(stack, name), " -> $"))
+			}
+		}
+		body, ok := doc.Definitions[name]
+		if !ok {
+			return "", fmt.Errorf("undefined definition $%s", name)
+		}
+		v, err := substitute(body, func(ref string) (string, error) {
+			return expand(ref, append(stack, name))
+		})
+		if err != nil {
+			return "", err
+		}
+		expanded[name] = v
+		return v, nil
+	}
+	resolve := func(condition string) (string, error) {
+		return substitute(condition, func(ref string) (string, error) {
+			return expand(ref, nil)
+		})
+	}
+
+	var err error
+	for i := range doc.Policies {
+		if doc.Policies[i].Condition, err = resolve(doc.Policies[i].Condition); err != nil {
+			return fmt.Errorf("policy %d (%s): %w", i, doc.Policies[i].ToolName, err)
+		}
+	}
+	for i := range doc.Sequences {
+		r := &doc.Sequences[i]
+		if r.Condition, err = resolve(r.Condition); err != nil {
+			return fmt.Errorf("sequence %d (%s): %w", i, r.ToolName, err)
+		}
+		for _, steps := range [][]SequenceStep{r.Requires, r.Forbids, r.ForbidsFollowups} {
+			for j := range steps {
+				if steps[j].Where, err = resolve(steps[j].Where); err != nil {
+					return fmt.Errorf("sequence %d (%s): %w", i, r.ToolName, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// substitute replaces each $name outside string literals with the
+// parenthesised result of lookup(name).
+func substitute(s string, lookup func(name string) (string, error)) (string, error) {
+	if !strings.Contains(s, "$") {
+		return s, nil
+	}
+	var b strings.Builder
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == '\\' && i+1 < len(s) {
+				b.WriteByte(c)
+				i++
+				c = s[i]
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '$':
+			j := i + 1
+			for j < len(s) && isIdentByte(s[j], j == i+1) {
+				j++
+			}
+			if j == i+1 {
+				break
+			}
+			v, err := lookup(s[i+1 : j])
+			if err != nil {
+				return "", err
+			}
+			b.WriteString("(" + v + ")")
+			i = j - 1
+			continue
+		}
+		b.WriteByte(c)
+	}
+	return b.String(), nil
+}
+
+func isIdentByte(c byte, first bool) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (!first && c >= '0' && c <= '9')
+}
@@ -0,0 +1,130 @@
+package policy
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestComposePolicies(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"common/roles.yaml": `
+definitions:
+  is_privileged: "user.role == 'admin' || $is_manager"
+  is_manager: "user.role == 'manager'"
+`,
+		"common/tools.yaml": `
+policies:
+  - id: weather
+    tool_name: weather
+    type: ALLOW
+`,
+		"policies.yaml": `
+include: ["common/*.yaml"]
+policies:
+  - id: sms
+    tool_name: notification
+    type: REJECT
+    condition: "params.type == 'sms' && !$is_privileged && params.note != '$is_privileged'"
+  - id: debug
+    tool_name: debug
+    type: ALLOW
+`,
+		"policies.prod.yaml": `
+remove: [debug]
+policies:
+  - id: weather
+    tool_name: weather
+    type: LOG
+`,
+	})
+	path := filepath.Join(dir, "policies.yaml")
+
+	doc, err := composePolicies(path, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(doc.Policies) != 3 || doc.Policies[0].ID != "weather" {
+		t.Fatalf("Expected included policies first, got %+v", doc.Policies)
+	}
+	want := "params.type == 'sms' && !(user.role == 'admin' || (user.role == 'manager')) && params.note != '$is_privileged'"
+	if got := doc.Policies[1].Condition; got != want {
+		t.Errorf("Expected expanded condition\n%s\ngot\n%s", want, got)
+	}
+
+	doc, err = composePolicies(path, "prod")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(doc.Policies) != 2 || doc.Policies[0].Type != PolicyLog {
+		t.Errorf("Expected overlay to replace weather and remove debug, got %+v", doc.Policies)
+	}
+}
+
+func TestComposePoliciesDiamondInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"policies.yaml": "include: [b.yaml, c.yaml]\n",
+		"b.yaml":        "include: [shared/d.yaml]\npolicies:\n  - {tool_name: b, type: ALLOW}\n",
+		"c.yaml":        "include: [./shared/../shared/d.yaml]\npolicies:\n  - {tool_name: c, type: ALLOW}\n",
+		"shared/d.yaml": "policies:\n  - {tool_name: d, type: REJECT}\n",
+	})
+	doc, err := composePolicies(filepath.Join(dir, "policies.yaml"), "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var tools []string
+	for _, p := range doc.Policies {
+		tools = append(tools, p.ToolName)
+	}
+	if got := strings.Join(tools, ","); got != "d,b,c" {
+		t.Errorf("Expected d to be included once, got %s", got)
+	}
+}
+
+func TestComposePoliciesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{"Include cycle", map[string]string{
+			"policies.yaml": "include: [a.yaml]",
+			"a.yaml":        "include: [policies.yaml]",
+		}, "include cycle"},
+		{"Definition cycle", map[string]string{
+			"policies.yaml": "definitions: {a: '$b', b: '$a'}\npolicies: [{tool_name: x, type: ALLOW, condition: '$a'}]",
+		}, "definition cycle"},
+		{"Undefined reference", map[string]string{
+			"policies.yaml": "policies: [{tool_name: x, type: ALLOW, condition: '$missing'}]",
+		}, "undefined definition"},
+		{"Missing include", map[string]string{
+			"policies.yaml": "include: [nope/*.yaml]",
+		}, "matched no files"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, tt.files)
+			_, err := composePolicies(filepath.Join(dir, "policies.yaml"), "")
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
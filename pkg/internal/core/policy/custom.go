@@ -0,0 +1,344 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/expr-lang/expr/ast"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// Host applications can register their own Go functions as condition
+// helpers, e.g. account lookups or allowlist checks. The function's Go
+// signature is its declared signature: conditions calling it with the wrong
+// number or types of arguments fail to load. A leading context.Context
+// parameter receives the evaluation context, and a trailing error result
+// makes the condition fail (the policy is then skipped, as for any condition
+// error).
+//
+// Example:
+//
+//	err := policy.RegisterFunction(policy.CustomFunction{
+//		Name:    "is_frozen",
+//		Fn:      func(ctx context.Context, account string) (bool, error) { return accounts.Frozen(ctx, account) },
+//		Memoize: true,
+//		Timeout: 50 * time.Millisecond,
+//	})
+//	// condition: "is_frozen(params.account)"
+
+// CustomFunction is a host-provided condition helper.
+type CustomFunction struct {
+	Name        string
+	Fn          interface{}
+	Description string
+	// Memoize caches results by arguments for the duration of one
+	// evaluation, so a lookup used by several policies runs once per call.
+	Memoize bool
+	// Timeout bounds each invocation. Zero means no limit beyond the
+	// evaluation context.
+	Timeout time.Duration
+}
+
+// ErrFunctionTimeout is returned when a custom function exceeds its Timeout.
+var ErrFunctionTimeout = errors.New("condition function timed out")
+
+// callScopeVar is the hidden environment variable that passes the current
+// evaluation's scope to custom functions.
+const callScopeVar = "hguard_call"
+
+// customFunction is a registered CustomFunction with its reflected form.
+type customFunction struct {
+	CustomFunction
+	fn      reflect.Value
+	withCtx bool
+	withErr bool
+}
+
+var (
+	customMu        sync.RWMutex
+	customFunctions = map[string]*customFunction{}
+	identifier      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	contextType     = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType       = reflect.TypeOf((*error)(nil)).Elem()
+	callScopeType   = reflect.TypeOf((*callScope)(nil))
+)
+
+// RegisterFunction makes f callable from conditions in every policy set.
+// Registering a name again replaces the earlier function. Built-in helpers
+// cannot be replaced. Use an Extensions table for functions that only some
+// evaluations should see.
+func RegisterFunction(f CustomFunction) error {
+	cf, helper, err := newCustomFunction(f)
+	if err != nil {
+		return err
+	}
+	customMu.Lock()
+	customFunctions[f.Name] = cf
+	customMu.Unlock()
+	registerFunction(helper)
+	// Programs compiled against an earlier registration are stale.
+	registrations.Add(1)
+	return nil
+}
+
+// newCustomFunction checks f and returns it with the helper that declares it
+// to expr.
+func newCustomFunction(f CustomFunction) (*customFunction, function, error) {
+	if !identifier.MatchString(f.Name) {
+		return nil, function{}, fmt.Errorf("invalid function name %q", f.Name)
+	}
+	functionsMu.RLock()
+	existing, ok := functions[f.Name]
+	functionsMu.RUnlock()
+	if ok && !existing.custom {
+		return nil, function{}, fmt.Errorf("function %s is a built-in helper", f.Name)
+	}
+	v := reflect.ValueOf(f.Fn)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return nil, function{}, fmt.Errorf("function %s: Fn must be a non-nil func, got %T", f.Name, f.Fn)
+	}
+	t := v.Type()
+	cf := &customFunction{CustomFunction: f, fn: v}
+	var args []reflect.Type
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && t.In(0) == contextType {
+			cf.withCtx = true
+			continue
+		}
+		args = append(args, t.In(i))
+	}
+	switch {
+	case t.NumOut() == 1 && t.Out(0) != errorType:
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+		cf.withErr = true
+	default:
+		return nil, function{}, fmt.Errorf("function %s must return a value, or a value and an error", f.Name)
+	}
+
+	// The declared type seen by expr takes the hidden scope first.
+	declared := reflect.FuncOf(append([]reflect.Type{callScopeType}, args...), []reflect.Type{t.Out(0)}, t.IsVariadic())
+	name := f.Name
+	helper := function{
+		FunctionInfo: FunctionInfo{
+			Name:        name,
+			Signature:   signature(name, args, t.Out(0), t.IsVariadic()),
+			Description: f.Description,
+		},
+		fn: func(params ...interface{}) (interface{}, error) {
+			return params[0].(*callScope).call(name, params[1:])
+		},
+		types:  []interface{}{reflect.New(declared).Interface()},
+		custom: true,
+	}
+	return cf, helper, nil
+}
+
+// signature renders a custom function's signature for Functions.
+func signature(name string, args []reflect.Type, out reflect.Type, variadic bool) string {
+	parts := make([]string, len(args))
+	for i, a := range args {
+		if variadic && i == len(args)-1 {
+			parts[i] = "..." + a.Elem().String()
+			continue
+		}
+		parts[i] = a.String()
+	}
+	return fmt.Sprintf("%s(%s) %s", name, strings.Join(parts, ", "), out)
+}
+
+// callScope carries per-evaluation state to custom functions and attribute
+// providers: the context, the call being evaluated, the extensions in use
+// and the memoized results. It also counts the operations of the running
+// condition against its limit.
+type callScope struct {
+	ctx    context.Context
+	tc     model.ToolCall
+	ext    *Extensions
+	mu     sync.Mutex
+	memo   map[string]interface{}
+	attrs  map[string]interface{}
+	ops    int
+	maxOps int
+}
+
+type callScopeKey struct{}
+
+// withCallScope returns ctx carrying a new scope for tc, unless it already
+// has one.
+func withCallScope(ctx context.Context, tc model.ToolCall, ext *Extensions) context.Context {
+	if _, ok := ctx.Value(callScopeKey{}).(*callScope); ok {
+		return ctx
+	}
+	s := &callScope{tc: tc, ext: ext}
+	ctx = context.WithValue(ctx, callScopeKey{}, s)
+	s.ctx = ctx
+	return ctx
+}
+
+// scopeFrom returns the scope carried by ctx, or a fresh one for tc.
+func scopeFrom(ctx context.Context, tc model.ToolCall, ext *Extensions) *callScope {
+	if s, ok := ctx.Value(callScopeKey{}).(*callScope); ok {
+		return s
+	}
+	return &callScope{ctx: ctx, tc: tc, ext: ext}
+}
+
+// call invokes a custom function, honouring memoization, timeouts and the
+// evaluation context.
+func (s *callScope) call(name string, args []interface{}) (interface{}, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	f, ok := s.ext.function(name)
+	if !ok {
+		return nil, fmt.Errorf("function %s is not registered", name)
+	}
+	var key string
+	if f.Memoize {
+		key = fmt.Sprintf("%s%#v", name, args)
+		s.mu.Lock()
+		v, ok := s.memo[key]
+		s.mu.Unlock()
+		if ok {
+			return v, nil
+		}
+	}
+	in, err := f.arguments(s.ctx, args)
+	if err != nil {
+		return nil, err
+	}
+	result, err := f.invoke(s.ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	if f.Memoize {
+		s.mu.Lock()
+		if s.memo == nil {
+			s.memo = map[string]interface{}{}
+		}
+		s.memo[key] = result
+		s.mu.Unlock()
+	}
+	return result, nil
+}
+
+// arguments converts condition values to the function's parameter types.
+// Missing values become zero values.
+func (f *customFunction) arguments(ctx context.Context, args []interface{}) ([]reflect.Value, error) {
+	t := f.fn.Type()
+	offset := 0
+	var in []reflect.Value
+	if f.withCtx {
+		offset = 1
+		in = append(in, reflect.ValueOf(ctx))
+	}
+	for i, a := range args {
+		var want reflect.Type
+		if t.IsVariadic() && i+offset >= t.NumIn()-1 {
+			want = t.In(t.NumIn() - 1).Elem()
+		} else {
+			want = t.In(i + offset)
+		}
+		switch v := reflect.ValueOf(a); {
+		case a == nil:
+			in = append(in, reflect.Zero(want))
+		case v.Type().AssignableTo(want):
+			in = append(in, v)
+		case v.Type().ConvertibleTo(want) && v.Kind() != reflect.String && want.Kind() != reflect.String:
+			in = append(in, v.Convert(want))
+		default:
+			return nil, fmt.Errorf("%s: argument %d: cannot use %T as %s", f.Name, i+1, a, want)
+		}
+	}
+	return in, nil
+}
+
+// invoke calls the function, abandoning it after Timeout or when ctx is
+// done.
+func (f *customFunction) invoke(ctx context.Context, in []reflect.Value) (interface{}, error) {
+	parent := ctx
+	if f.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, f.Timeout)
+		defer cancel()
+		if f.withCtx {
+			in[0] = reflect.ValueOf(ctx)
+		}
+	}
+	if ctx.Done() == nil {
+		return f.results(f.fn.Call(in))
+	}
+	type outcome struct {
+		v   interface{}
+		err error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		v, err := f.results(f.fn.Call(in))
+		done <- outcome{v, err}
+	}()
+	select {
+	case o := <-done:
+		return o.v, o.err
+	case <-ctx.Done():
+		if err := parent.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%s: %w after %s", f.Name, ErrFunctionTimeout, f.Timeout)
+	}
+}
+
+func (f *customFunction) results(out []reflect.Value) (interface{}, error) {
+	if f.withErr && !out[1].IsNil() {
+		return nil, out[1].Interface().(error)
+	}
+	return out[0].Interface(), nil
+}
+
+// permissionsFunction is the hidden helper that returns the effective
+// permissions of the provider-aware user.
+const permissionsFunction = "hguard_permissions"
+
+// scopePatcher passes the hidden scope variable as the first argument of
+// every call to one of the custom functions, and turns accesses to provided
+// attributes into lookups. user.permissions is derived from the provided
+// role and permissions as has_permission does.
+type scopePatcher struct {
+	custom     map[string]bool
+	attributes map[string]bool
+}
+
+func (p scopePatcher) Visit(node *ast.Node) {
+	if _, ok := (*node).(*ast.MemberNode); ok {
+		path, ok := memberPath(*node)
+		switch {
+		case !ok:
+		case path == "user.permissions" && (p.attributes["user.role"] || p.attributes["user.permissions"]):
+			ast.Patch(node, &ast.CallNode{Callee: &ast.IdentifierNode{Value: permissionsFunction}})
+		case p.attributes[path]:
+			ast.Patch(node, attributeCall(path, *node))
+		}
+		return
+	}
+	call, ok := (*node).(*ast.CallNode)
+	if !ok {
+		return
+	}
+	callee, ok := call.Callee.(*ast.IdentifierNode)
+	if !ok || !p.custom[callee.Value] {
+		return
+	}
+	if len(call.Arguments) > 0 {
+		if first, ok := call.Arguments[0].(*ast.IdentifierNode); ok && first.Value == callScopeVar {
+			return
+		}
+	}
+	call.Arguments = append([]ast.Node{&ast.IdentifierNode{Value: callScopeVar}}, call.Arguments...)
+}
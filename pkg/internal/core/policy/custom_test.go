@@ -0,0 +1,180 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestCustomFunctions(t *testing.T) {
+	lookups := 0
+	err := RegisterFunction(CustomFunction{
+		Name: "is_frozen",
+		Fn: func(ctx context.Context, account string) (bool, error) {
+			lookups++
+			return account == "acct-9", nil
+		},
+		Memoize: true,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := RegisterFunction(CustomFunction{Name: "on_allowlist", Fn: func(list string, emails ...string) bool {
+		return list == "partners" && len(emails) > 0 && strings.HasSuffix(emails[0], "@partner.com")
+	}}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	set := NewSet(false)
+	set.Register(Policy{ID: "frozen", ToolName: "transfer", Type: PolicyReject, Condition: "is_frozen(params.from)", Priority: 20})
+	set.Register(Policy{ID: "frozen-target", ToolName: "transfer", Type: PolicyReject, Condition: "is_frozen(params.from) && params.amount > 10", Priority: 10})
+	set.Register(Policy{ID: "partners", ToolName: "send_email", Type: PolicyAllow, Condition: "on_allowlist('partners', params.to)", Priority: 10})
+	set.Register(Policy{ID: "others", ToolName: "send_email", Type: PolicyReject, Priority: 1})
+
+	tests := []struct {
+		name     string
+		tc       model.ToolCall
+		policyID string
+	}{
+		{"Frozen account", model.ToolCall{Name: "transfer", Parameters: map[string]interface{}{"from": "acct-9"}}, "frozen"},
+		{"Open account", model.ToolCall{Name: "transfer", Parameters: map[string]interface{}{"from": "acct-1", "amount": 50}}, "default:allow"},
+		{"Variadic function", model.ToolCall{Name: "send_email", Parameters: map[string]interface{}{"to": "bob@partner.com"}}, "partners"},
+		{"Missing argument is zero value", model.ToolCall{Name: "send_email"}, "others"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluatePolicyWithOptions(context.Background(), tt.tc, EvalOptions{Set: set})
+			if result.PolicyID != tt.policyID {
+				t.Errorf("Expected %s, got %s (%s)", tt.policyID, result.PolicyID, result.Reason)
+			}
+		})
+	}
+	// The open-account call evaluated is_frozen in two policies; memoization
+	// makes that a single lookup.
+	if lookups != 2 {
+		t.Errorf("Expected 2 lookups, got %d", lookups)
+	}
+
+	if err := CheckExpression("is_frozen(params.from, 1)"); err == nil {
+		t.Error("Expected error for wrong argument count")
+	}
+	if err := CheckExpression("is_frozen(42)"); err == nil {
+		t.Error("Expected error for wrong argument type")
+	}
+	if err := RegisterFunction(CustomFunction{Name: "ip_in", Fn: func() bool { return true }}); err == nil {
+		t.Error("Expected error replacing a built-in helper")
+	}
+	if err := RegisterFunction(CustomFunction{Name: "no_result", Fn: func() {}}); err == nil {
+		t.Error("Expected error for function without result")
+	}
+}
+
+func TestCustomFunctionTimeout(t *testing.T) {
+	err := RegisterFunction(CustomFunction{
+		Name: "slow_lookup",
+		Fn: func(ctx context.Context) bool {
+			<-ctx.Done()
+			return true
+		},
+		Timeout: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	_, err = evaluateCondition(context.Background(), "slow_lookup()", newEnv(context.Background(), model.ToolCall{Name: "x"}, EvalOptions{}), EvalOptions{})
+	if !errors.Is(err, ErrFunctionTimeout) {
+		t.Errorf("Expected ErrFunctionTimeout, got %v", err)
+	}
+}
+
+func TestRegisterFunctionRefreshesSetCaches(t *testing.T) {
+	if err := RegisterFunction(CustomFunction{Name: "tier", Fn: func() string { return "gold" }}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	set := NewSet(false)
+	set.Register(Policy{ID: "gold", ToolName: "upgrade", Type: PolicyReject, Condition: "tier() == 'gold'"})
+	opts := EvalOptions{Set: set}
+	tc := model.ToolCall{Name: "upgrade"}
+	if result := EvaluatePolicyWithOptions(context.Background(), tc, opts); result.Action != PolicyReject {
+		t.Fatalf("Expected REJECT, got %v (%s)", result.Action, result.Reason)
+	}
+	if _, ok := set.cache.get("tier() == 'gold'", registrations.Load()); !ok {
+		t.Fatalf("Expected the condition to be cached")
+	}
+
+	if err := RegisterFunction(CustomFunction{Name: "tier", Fn: func() int { return 1 }}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := set.cache.get("tier() == 'gold'", registrations.Load()); ok {
+		t.Errorf("Expected the set's cache to be stale after a registration")
+	}
+	// The condition no longer type-checks, so the policy is skipped.
+	if result := EvaluatePolicyWithOptions(context.Background(), tc, opts); result.Action != PolicyAllow {
+		t.Errorf("Expected ALLOW, got %v (%s)", result.Action, result.Reason)
+	}
+}
+
+func TestExtensions(t *testing.T) {
+	gold, silver := NewExtensions(), NewExtensions()
+	for ext, tier := range map[*Extensions]string{gold: "gold", silver: "silver"} {
+		tier := tier
+		if err := ext.RegisterFunction(CustomFunction{Name: "plan", Fn: func() string { return tier }}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	set := NewSet(false)
+	set.Register(Policy{ID: "gold", ToolName: "upgrade", Type: PolicyReject, Condition: "plan() == 'gold'"})
+
+	tc := model.ToolCall{Name: "upgrade"}
+	if result := EvaluatePolicyWithOptions(context.Background(), tc, EvalOptions{Set: set, Extensions: gold}); result.PolicyID != "gold" {
+		t.Errorf("Expected gold's plan to match, got %s (%s)", result.PolicyID, result.Reason)
+	}
+	if result := EvaluatePolicyWithOptions(context.Background(), tc, EvalOptions{Set: set, Extensions: silver}); result.Action != PolicyAllow {
+		t.Errorf("Expected silver's plan not to match, got %s (%s)", result.PolicyID, result.Reason)
+	}
+	if err := CheckExpression("plan() == 'gold'"); err == nil {
+		t.Error("Expected functions of a table to be unknown at package level")
+	}
+	if deps := set.Dependencies("upgrade", nil, gold); !deps.Volatile {
+		t.Errorf("Expected custom functions to make the decision volatile, got %+v", deps)
+	}
+
+	before := gold.Generation()
+	gold.RegisterFunction(CustomFunction{Name: "plan", Fn: func() string { return "silver" }})
+	if gold.Generation() == before {
+		t.Error("Expected a registration to change the generation")
+	}
+	if result := EvaluatePolicyWithOptions(context.Background(), tc, EvalOptions{Set: set, Extensions: gold}); result.Action != PolicyAllow {
+		t.Errorf("Expected the replaced function to apply, got %s (%s)", result.PolicyID, result.Reason)
+	}
+}
+
+func TestCustomFunctionsStopWithContext(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ext := NewExtensions()
+	calls := 0
+	ext.RegisterFunction(CustomFunction{Name: "wait", Fn: func(fnCtx context.Context) bool {
+		calls++
+		cancel()
+		<-fnCtx.Done()
+		return true
+	}})
+	set := NewSet(false)
+	set.RegisterSequence(SequenceRule{ToolName: "send", Forbids: []SequenceStep{{Tool: "draft", Where: "wait()"}}})
+	set.Register(Policy{ToolName: "send", Type: PolicyReject, Condition: "wait()"})
+
+	history := []model.SessionCall{{ToolName: "draft"}, {ToolName: "draft"}, {ToolName: "draft"}}
+	tc := model.ToolCall{Name: "send", Context: model.CallContext{History: history}}
+	result := EvaluatePolicyWithOptions(ctx, tc, EvalOptions{Set: set, Extensions: ext})
+	if result.Action != PolicyTimeout {
+		t.Errorf("Expected PolicyTimeout, got %v (%s)", result.Action, result.Reason)
+	}
+	if calls != 1 {
+		t.Errorf("Expected the history scan to stop after the first call, got %d calls", calls)
+	}
+}
@@ -0,0 +1,238 @@
+package policy
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// A tool's decision depends on its name, its parameters and whatever parts of
+// the call context its conditions read. Dependencies lists those parts so
+// callers can recognise calls that must get the same decision, e.g. to cache
+// decisions for retried calls.
+//
+// Example:
+//
+//	deps := set.Dependencies("transfer", tags, ext) // conditions read user.role and metadata.region
+//	key, ok := deps.Key(tc)                  // equal for calls that differ only elsewhere
+
+// Dependencies describes the call context a tool's decision depends on.
+type Dependencies struct {
+	// Paths are the context paths read by the tool's conditions, such as
+	// "user.role" or "metadata.region", sorted. A bare root ("metadata")
+	// means all of it.
+	Paths []string
+	// Volatile is set when the decision can change between identical calls:
+	// conditions read the clock, custom functions or provider attributes,
+	// sequence rules have time windows, or BUDGET policies apply.
+	Volatile bool
+}
+
+// contextPaths are the environment paths taken from the call context. Other
+// roots (params, tool) are fixed by the tool name and parameters.
+var contextPaths = map[string]bool{
+	"user.id": true, "user.role": true, "user.permissions": true,
+	"session.id": true, "session.conversation_id": true, "session.previous_calls": true, "session.history": true,
+	"time.hour": true, "request.ip": true,
+}
+
+// Dependencies returns what the decision for toolName, carrying the given
+// schema tags, depends on besides the tool name and parameters, when it is
+// evaluated with ext. The result is stale once ext.Generation changes.
+func (s *Set) Dependencies(toolName string, tags []string, ext *Extensions) Dependencies {
+	refs := &references{paths: map[string]bool{}, provided: ext.attributePaths(), ext: ext}
+	for _, p := range s.applicable(toolName, tags) {
+		if p.Type == PolicyBudget {
+			refs.volatile = true
+		}
+		refs.add(p.Condition)
+	}
+	for _, rule := range s.Sequences(toolName) {
+		refs.paths["session.history"] = true
+		refs.add(rule.Condition)
+		for _, step := range append(rule.Requires, rule.Forbids...) {
+			if step.Within > 0 {
+				refs.volatile = true
+			}
+			refs.add(step.Where)
+		}
+	}
+	deps := Dependencies{Volatile: refs.volatile}
+	for path := range refs.paths {
+		deps.Paths = append(deps.Paths, path)
+	}
+	sort.Strings(deps.Paths)
+	return deps
+}
+
+// Key returns a canonical hash of tc's tenant, tool name, parameters and the
+// context values the dependencies name. It returns false for volatile
+// dependencies, whose decisions must not be reused.
+func (d Dependencies) Key(tc model.ToolCall) (string, bool) {
+	if d.Volatile {
+		return "", false
+	}
+	values := make(map[string]interface{}, len(d.Paths))
+	for _, path := range d.Paths {
+		values[path] = contextValue(tc.Context, path)
+	}
+	// encoding/json sorts map keys, which makes the encoding canonical.
+	b, err := json.Marshal(struct {
+		Tenant  string                 `json:"tenant"`
+		Tool    string                 `json:"tool"`
+		Params  map[string]interface{} `json:"params"`
+		Context map[string]interface{} `json:"context"`
+	}{tc.Context.TenantID, tc.Name, tc.Parameters, values})
+	if err != nil {
+		return "", false
+	}
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// contextValue returns the part of cc a dependency path names.
+func contextValue(cc model.CallContext, path string) interface{} {
+	switch path {
+	case "user":
+		return []interface{}{cc.UserID, cc.UserRole, cc.UserPermissions}
+	case "user.id":
+		return cc.UserID
+	case "user.role":
+		return cc.UserRole
+	case "user.permissions":
+		// Effective permissions include those granted by the role.
+		return []interface{}{cc.UserRole, cc.UserPermissions}
+	case "session":
+		return []interface{}{cc.SessionID, cc.ConversationID, cc.History}
+	case "session.id":
+		return cc.SessionID
+	case "session.conversation_id":
+		return cc.ConversationID
+	case "session.previous_calls":
+		return cc.PreviousCalls
+	case "session.history":
+		return cc.History
+	case "time.hour":
+		return cc.TimeOfDay
+	case "request", "request.ip":
+		return cc.IPAddress
+	case "metadata":
+		return cc.Metadata
+	}
+	if key, ok := strings.CutPrefix(path, "metadata."); ok {
+		return cc.Metadata[key]
+	}
+	return nil
+}
+
+// references collects the context paths read by expressions.
+type references struct {
+	paths    map[string]bool
+	volatile bool
+	provided map[string]bool
+	ext      *Extensions
+}
+
+// add records the references of one expression. Expressions that do not
+// parse are treated as volatile.
+func (r *references) add(expression string) {
+	if expression == "" {
+		return
+	}
+	tree, err := parser.Parse(expression)
+	if err != nil {
+		r.volatile = true
+		return
+	}
+	v := &referenceVisitor{refs: r, bases: map[ast.Node]bool{}}
+	ast.Walk(&tree.Node, v)
+	for _, id := range v.roots {
+		if !v.bases[id] {
+			r.root(id.Value)
+		}
+	}
+}
+
+func (r *references) root(name string) {
+	switch name {
+	case "user", "session", "request", "metadata":
+		r.paths[name] = true
+	case "params", "tool", callScopeVar:
+	default:
+		// time, provided attribute roots and unknown names.
+		r.volatile = true
+	}
+}
+
+func (r *references) path(path string) {
+	root, rest, _ := strings.Cut(path, ".")
+	field, _, _ := strings.Cut(rest, ".")
+	switch {
+	case r.provided[root+"."+field]:
+		r.volatile = true
+	case root+"."+field == "user.permissions" && r.provided["user.role"]:
+		// Effective permissions include those of the provided role.
+		r.volatile = true
+	case root == "params" || root == "tool":
+	case root == "metadata":
+		r.paths[root+"."+field] = true
+	case contextPaths[root+"."+field]:
+		r.paths[root+"."+field] = true
+	default:
+		r.root(root)
+	}
+}
+
+// referenceVisitor finds member accesses, bare roots and calls.
+type referenceVisitor struct {
+	refs  *references
+	roots []*ast.IdentifierNode
+	bases map[ast.Node]bool // identifiers used as the base of a member access
+}
+
+func (v *referenceVisitor) Visit(node *ast.Node) {
+	switch n := (*node).(type) {
+	case *ast.IdentifierNode:
+		v.roots = append(v.roots, n)
+	case *ast.MemberNode:
+		if path, ok := memberPath(n); ok {
+			v.refs.path(path)
+			v.markBase(n)
+		}
+	case *ast.CallNode:
+		callee, ok := n.Callee.(*ast.IdentifierNode)
+		if !ok {
+			return
+		}
+		v.bases[callee] = true
+		switch callee.Value {
+		case "has_role":
+			v.refs.path("user.role")
+		case "has_permission":
+			v.refs.path("user.permissions")
+		case "now":
+			v.refs.volatile = true
+		default:
+			if v.refs.ext.isCustom(callee.Value) {
+				v.refs.volatile = true
+			}
+		}
+	}
+}
+
+// markBase marks the identifier at the bottom of a member chain.
+func (v *referenceVisitor) markBase(n *ast.MemberNode) {
+	switch base := n.Node.(type) {
+	case *ast.IdentifierNode:
+		v.bases[base] = true
+	case *ast.MemberNode:
+		v.markBase(base)
+	}
+}
@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestDependencies(t *testing.T) {
+	set := NewSet(false)
+	set.Register(Policy{ToolName: "transfer", Type: PolicyReject, Condition: "user.id == 'guest' && params.amount > 100"})
+	set.Register(Policy{ToolName: "transfer", Type: PolicyReject, Condition: "metadata.region in ['eu', 'us'] || has_permission('audit')"})
+	set.Register(Policy{ToolName: "*", Type: PolicyAllow, Condition: "tool.name != '' && request.ip != ''"})
+	set.Register(Policy{ToolName: "report", Type: PolicyReject, Condition: "time.hour < 9 || metadata['plan'] == 'free'"})
+	set.Register(Policy{ToolName: "backup", Type: PolicyReject, Condition: "time.weekday == 'sunday'"})
+	set.Register(Policy{ToolName: "export", Type: PolicyReject, Condition: "len(filter(session.previous_calls, # == 'export')) > 3"})
+	set.Register(Policy{ToolName: "pay", Type: PolicyBudget, Limit: 10})
+	set.RegisterSequence(SequenceRule{ToolName: "send", Requires: []SequenceStep{{Tool: "draft", Within: time.Hour}}})
+	set.RegisterSequence(SequenceRule{ToolName: "publish", Requires: []SequenceStep{{Tool: "review", Where: "params.ok"}}})
+
+	tests := []struct {
+		tool     string
+		paths    []string
+		volatile bool
+	}{
+		{"transfer", []string{"metadata.region", "request.ip", "user.id", "user.permissions"}, false},
+		{"report", []string{"metadata.plan", "request.ip", "time.hour"}, false},
+		{"backup", []string{"request.ip"}, true},
+		{"export", []string{"request.ip", "session.previous_calls"}, false},
+		{"pay", []string{"request.ip"}, true},
+		{"send", []string{"request.ip", "session.history"}, true},
+		{"publish", []string{"request.ip", "session.history"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tool, func(t *testing.T) {
+			deps := set.Dependencies(tt.tool, nil, nil)
+			if !reflect.DeepEqual(deps.Paths, tt.paths) || deps.Volatile != tt.volatile {
+				t.Errorf("Expected %v (volatile %v), got %v (volatile %v)", tt.paths, tt.volatile, deps.Paths, deps.Volatile)
+			}
+		})
+	}
+
+	deps := set.Dependencies("transfer", nil, nil)
+	call := func(user, session string, amount int, metadata map[string]interface{}) model.ToolCall {
+		return model.ToolCall{
+			Name:       "transfer",
+			Parameters: map[string]interface{}{"amount": amount},
+			Context:    model.CallContext{UserID: user, SessionID: session, Metadata: metadata},
+		}
+	}
+	key := func(tc model.ToolCall) string {
+		k, ok := deps.Key(tc)
+		if !ok {
+			t.Fatal("Expected a key")
+		}
+		return k
+	}
+	base := key(call("guest", "s1", 10, map[string]interface{}{"region": "eu", "trace": "a"}))
+	if base != key(call("guest", "s2", 10, map[string]interface{}{"trace": "b", "region": "eu"})) {
+		t.Error("Expected calls differing in unread fields to share a key")
+	}
+	for name, tc := range map[string]model.ToolCall{
+		"user":   call("admin", "s1", 10, map[string]interface{}{"region": "eu"}),
+		"params": call("guest", "s1", 11, map[string]interface{}{"region": "eu"}),
+		"region": call("guest", "s1", 10, map[string]interface{}{"region": "us"}),
+	} {
+		if key(tc) == base {
+			t.Errorf("Expected a different key when %s changes", name)
+		}
+	}
+	if _, ok := set.Dependencies("backup", nil, nil).Key(call("u1", "", 0, nil)); ok {
+		t.Error("Expected no key for volatile dependencies")
+	}
+}
@@ -1,16 +1,19 @@
 package policy
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
-	"sort"
 	"sync"
-	"time"
 
 	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/logging"
+	"github.com/SafellmHub/hguard-go/pkg/internal/trace"
 	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/file"
 	"github.com/expr-lang/expr/vm"
-	"gopkg.in/yaml.v3"
 )
 
 // Package policy provides policy definitions, registration, and enforcement for LLM tool calls.
@@ -31,35 +34,61 @@ import (
 //	result := policy.EvaluatePolicy(tc)
 //	action := result.Action
 //
-// PolicyType defines the type of policy action (REJECT, REWRITE, LOG, ALLOW, etc.).
+// PolicyType defines the type of policy action (REJECT, REWRITE, LOG, ALLOW, REQUIRE_APPROVAL, etc.).
 type PolicyType string
 
 // PolicyAction is a string representing the result of a policy application.
 type PolicyAction string
 
 const (
-	PolicyReject        PolicyType = "REJECT"
-	PolicyRewrite       PolicyType = "REWRITE"
-	PolicyLog           PolicyType = "LOG"
-	PolicyAllow         PolicyType = "ALLOW"
-	PolicyContextReject PolicyType = "CONTEXT_REJECT"
-	PolicyRateLimit     PolicyType = "RATE_LIMIT"
+	PolicyReject          PolicyType = "REJECT"
+	PolicyRewrite         PolicyType = "REWRITE"
+	PolicyLog             PolicyType = "LOG"
+	PolicyAllow           PolicyType = "ALLOW"
+	PolicyContextReject   PolicyType = "CONTEXT_REJECT"
+	PolicyRateLimit       PolicyType = "RATE_LIMIT"
+	PolicyTimeout         PolicyType = "TIMEOUT" // Result only: evaluation was cancelled or ran out of budget
+	PolicyRequireApproval PolicyType = "REQUIRE_APPROVAL"
+	PolicyBudget          PolicyType = "BUDGET"
 
 	ActionNone      PolicyAction = "none"
 	ActionRejected  PolicyAction = "rejected"
 	ActionRewritten PolicyAction = "rewritten"
 	ActionLogged    PolicyAction = "logged"
 	ActionApproved  PolicyAction = "approved"
+	ActionPending   PolicyAction = "pending"
 )
 
+// ErrBudgetExceeded is returned when a condition exceeds
+// EvalOptions.MaxConditionMemory or EvalOptions.MaxConditionOperations.
+var ErrBudgetExceeded = errors.New("evaluation budget exceeded")
+
+// errOperationsExceeded stops a condition that exceeds
+// EvalOptions.MaxConditionOperations.
+var errOperationsExceeded = errors.New("operation budget exceeded")
+
+// exprMemoryBudgetExceeded is the message expr reports when a program
+// exceeds its memory budget, as of expr v1.17.5. It is matched exactly;
+// TestEvaluationTimeout fails if expr changes it.
+const exprMemoryBudgetExceeded = "memory budget exceeded"
+
 // Policy defines a guardrail policy for a tool.
 type Policy struct {
-	ToolName  string     `yaml:"tool_name"`
+	ID        string     `yaml:"id,omitempty"`         // Optional stable identifier, used as PolicyID and by overlays
+	ToolName  string     `yaml:"tool_name"`            // Exact name, "*", glob ("admin_*") or /regex/
+	ToolNames []string   `yaml:"tool_names,omitempty"` // Further names or patterns; YAML also accepts a list under tool_name
+	Tags      []string   `yaml:"tags,omitempty"`       // Applies to tools whose schema declares any of these tags
 	Type      PolicyType `yaml:"type"`
 	Condition string     `yaml:"condition,omitempty"` // Conditional expression to evaluate
 	Reason    string     `yaml:"reason,omitempty"`    // Custom reason for rejection/rewrite
 	Priority  int        `yaml:"priority,omitempty"`  // Priority for multiple matching policies (higher = more specific)
 	Target    string     `yaml:"target,omitempty"`    // Target tool name for REWRITE policies
+
+	// BUDGET policies reject calls whose cost would take usage over Limit
+	// within Period ("daily" or "monthly") for Scope ("user" or "tenant").
+	Limit  float64 `yaml:"limit,omitempty"`
+	Period string  `yaml:"period,omitempty"`
+	Scope  string  `yaml:"scope,omitempty"`
 }
 
 // PolicyResult represents the result of policy evaluation
@@ -69,22 +98,22 @@ type PolicyResult struct {
 	Target   string
 	Matched  bool
 	PolicyID string
+	Origin   string // model.OriginBudget for results of BUDGET policies
 }
 
 // In-memory policy registry
 var policies = map[string][]Policy{}
 
+// Policies targeting globs, regexes, lists or tags, in registration order
+var patterns = &patternList{}
+
 // RegisterPolicy adds a policy to the registry.
 //
 // Example:
 //
 //	policy.RegisterPolicy(Policy{ToolName: "weather", Type: policy.PolicyAllow})
 func RegisterPolicy(p Policy) {
-	policies[p.ToolName] = append(policies[p.ToolName], p)
-	// Sort policies by priority (higher first)
-	sort.Slice(policies[p.ToolName], func(i, j int) bool {
-		return policies[p.ToolName][i].Priority > policies[p.ToolName][j].Priority
-	})
+	Default().Register(p)
 }
 
 // GetPolicy retrieves policies for a tool name.
@@ -97,68 +126,196 @@ func GetPolicy(toolName string) ([]Policy, bool) {
 	return p, ok
 }
 
-// GetAllPolicies returns all policies for a tool name (including wildcards)
+// GetAllPolicies returns all policies for a tool name (including wildcards,
+// globs and regexes)
 func GetAllPolicies(toolName string) []Policy {
-	var allPolicies []Policy
+	return Default().AllPolicies(toolName)
+}
 
-	// Add specific tool policies
-	if toolPolicies, ok := policies[toolName]; ok {
-		allPolicies = append(allPolicies, toolPolicies...)
-	}
+// Observer receives events from policy evaluation. Implementations must be
+// safe for concurrent use.
+type Observer interface {
+	// ExprCacheLookup is called each time a condition is looked up in the
+	// compiled expression cache.
+	ExprCacheLookup(hit bool)
+	// ConditionError is called when a policy condition fails to compile or run.
+	ConditionError(p Policy, err error)
+}
 
-	// Add wildcard policies
-	if wildcardPolicies, ok := policies["*"]; ok {
-		allPolicies = append(allPolicies, wildcardPolicies...)
+// id identifies the policy in results, traces and logs.
+func (p Policy) id() string {
+	if p.ID != "" {
+		return p.ID
 	}
+	return fmt.Sprintf("%s:%s", p.Selector(), p.Type)
+}
 
-	// Sort by priority (higher first)
-	sort.Slice(allPolicies, func(i, j int) bool {
-		return allPolicies[i].Priority > allPolicies[j].Priority
-	})
+// BudgetChecker decides whether a call fits within a BUDGET policy.
+type BudgetChecker interface {
+	// CheckBudget returns a non-empty reason if the call would exceed p.
+	CheckBudget(ctx context.Context, p Policy, tc model.ToolCall) (exceeded string, err error)
+}
 
-	return allPolicies
+// EvalOptions configures a single policy evaluation.
+type EvalOptions struct {
+	Observer Observer
+	// Tracer receives a span for the evaluation and a child span for each
+	// condition evaluated. Nil disables tracing.
+	Tracer trace.Tracer
+	// Logger receives the warnings logged for conditions that fail to
+	// evaluate. Nil uses the package-level logger.
+	Logger *logging.Logger
+	// MaxConditionMemory is expr's memory budget for a single condition: the
+	// number of allocation units it may use, which grows with every element
+	// produced by ranges, maps, filters and repeat. It does not count
+	// operations; a condition over small values can run long within it.
+	// Zero uses expr's default.
+	MaxConditionMemory uint
+	// MaxConditionOperations bounds the operations of a single condition,
+	// counted as the expression nodes evaluated by predicates: each
+	// iteration of all, any, filter, map and the other builtins taking a
+	// predicate counts the nodes of its predicate. The rest of a condition
+	// runs at most once per evaluation and is not counted. Zero means no
+	// limit.
+	MaxConditionOperations int
+	// Budget enforces BUDGET policies. Nil skips them.
+	Budget BudgetChecker
+	// Set is the policy set to evaluate. Nil uses the default set.
+	Set *Set
+	// ToolTags are the tags declared on the called tool's schema, matched
+	// against policies' tags.
+	ToolTags []string
+	// Combining is the combining algorithm for tools the set does not
+	// configure. Empty means FirstApplicable.
+	Combining CombiningAlgorithm
+	// Extensions adds condition functions and attribute providers to the
+	// package-level ones. Nil uses only those.
+	Extensions *Extensions
 }
 
 // EvaluatePolicy evaluates all applicable policies for a tool call and returns the result
 func EvaluatePolicy(tc model.ToolCall) PolicyResult {
-	allPolicies := GetAllPolicies(tc.Name)
+	return EvaluatePolicyWithOptions(context.Background(), tc, EvalOptions{})
+}
+
+// EvaluatePolicyWithOptions evaluates all applicable policies for a tool call,
+// reporting evaluation events to opts.Observer and spans to opts.Tracer.
+// If ctx is done before a decision is reached, or a condition exceeds
+// opts.MaxConditionMemory or opts.MaxConditionOperations, the result has
+// Action PolicyTimeout. ctx is checked between conditions, between sequence
+// history entries, by custom functions and on each iteration of a
+// predicate.
+func EvaluatePolicyWithOptions(ctx context.Context, tc model.ToolCall, opts EvalOptions) PolicyResult {
+	ctx, span := trace.Start(ctx, opts.Tracer, trace.SpanEvaluatePolicy)
+	defer span.End()
+	ctx = withCallScope(ctx, tc, opts.Extensions)
+	// Resolve the set once; the default set is a view built on each call.
+	opts.Set = opts.set()
+	env := newEnv(ctx, tc, opts)
+	defer releaseEnv(env)
+
+	result, violated := evaluateSequences(ctx, tc, env, opts)
+	if !violated {
+		result = evaluatePolicies(ctx, tc, env, opts)
+	}
+	if opts.Tracer != nil {
+		span.SetAttribute(trace.AttrPolicyID, result.PolicyID)
+		span.SetAttribute(trace.AttrDecisionAction, string(result.Action))
+	}
+	return result
+}
+
+// evaluatePolicies evaluates the policies that may match the call, as
+// selected by the set's index.
+func evaluatePolicies(ctx context.Context, tc model.ToolCall, env *Env, opts EvalOptions) PolicyResult {
+	idx := opts.set().index(tc.Name, opts.ToolTags, opts.Extensions)
+	positions := positionsPool.Get().(*[]int)
+	defer positionsPool.Put(positions)
+	return evaluateCandidates(ctx, tc, env, opts, idx.policies, idx.candidates(env, positions))
+}
 
-	for _, policy := range allPolicies {
-		if policy.Condition == "" {
+// evaluateCandidates evaluates the policies at the given positions of a
+// priority-ordered list.
+func evaluateCandidates(ctx context.Context, tc model.ToolCall, env *Env, opts EvalOptions, list []Policy, positions []int) PolicyResult {
+	algorithm := opts.algorithm(tc.Name)
+
+	matched := matchedPool.Get().(*[]PolicyResult)
+	defer func() {
+		*matched = (*matched)[:0]
+		matchedPool.Put(matched)
+	}()
+	for _, i := range positions {
+		policy := list[i]
+		if err := ctx.Err(); err != nil {
+			return timeoutResult(err)
+		}
+		var result PolicyResult
+		switch {
+		case policy.Type == PolicyBudget:
+			var exceeded bool
+			if result, exceeded = checkBudget(ctx, policy, tc, env, opts); !exceeded {
+				continue
+			}
+		case policy.Condition == "":
 			// No condition, policy always applies
-			return PolicyResult{
+			result = PolicyResult{
 				Action:   policy.Type,
 				Reason:   policy.Reason,
 				Target:   policy.Target,
 				Matched:  true,
-				PolicyID: fmt.Sprintf("%s:%s", policy.ToolName, policy.Type),
+				PolicyID: policy.id(),
+			}
+		default:
+			// Evaluate condition
+			match, err := traceCondition(ctx, policy, env, opts)
+			if errors.Is(err, ErrBudgetExceeded) {
+				return timeoutResult(err)
+			}
+			if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+				return timeoutResult(ctxErr)
+			}
+			if err != nil {
+				if opts.Observer != nil {
+					opts.Observer.ConditionError(policy, err)
+				}
+				// Log error and continue to next policy
+				opts.Logger.Warn("Error evaluating condition for policy %s: %v", policy.Selector(), err)
+				continue
+			}
+			if !match {
+				continue
 			}
-		}
-
-		// Evaluate condition
-		match, err := evaluateCondition(policy.Condition, tc)
-		if err != nil {
-			// Log error and continue to next policy
-			fmt.Printf("Error evaluating condition for policy %s: %v\n", policy.ToolName, err)
-			continue
-		}
-
-		if match {
 			reason := policy.Reason
 			if reason == "" {
 				reason = fmt.Sprintf("Policy %s matched for tool %s", policy.Type, tc.Name)
 			}
-			return PolicyResult{
+			result = PolicyResult{
 				Action:   policy.Type,
 				Reason:   reason,
 				Target:   policy.Target,
 				Matched:  true,
-				PolicyID: fmt.Sprintf("%s:%s", policy.ToolName, policy.Type),
+				PolicyID: policy.id(),
 			}
 		}
+		if algorithm.decisive(result) {
+			return result
+		}
+		*matched = append(*matched, result)
 	}
+	return algorithm.combine(*matched)
+}
+
+// vmPool recycles expression VMs, whose stacks are reused across runs.
+var vmPool = sync.Pool{New: func() interface{} { return new(vm.VM) }}
+
+// matchedPool recycles the per-evaluation lists of matched results.
+var matchedPool = sync.Pool{New: func() interface{} { return new([]PolicyResult) }}
+
+// positionsPool recycles the per-evaluation lists of candidate positions.
+var positionsPool = sync.Pool{New: func() interface{} { return new([]int) }}
 
-	// No matching policies, default to allow
+// defaultAllow is the result when no policy matches.
+func defaultAllow() PolicyResult {
 	return PolicyResult{
 		Action:   PolicyAllow,
 		Reason:   "No matching policies found",
@@ -168,91 +325,263 @@ func EvaluatePolicy(tc model.ToolCall) PolicyResult {
 	}
 }
 
-// Expression compilation cache for performance
-var (
-	exprCache = make(map[string]*vm.Program)
-	cacheMu   sync.RWMutex
-)
+// checkBudget applies a BUDGET policy. Evaluation continues past budgets that
+// do not apply or are not exceeded; a failing budget check rejects the call.
+// Budgets fail closed: a condition that runs out of time or budget stops
+// evaluation, and one that fails otherwise rejects the call, so an expensive
+// or broken condition cannot lift a spending limit.
+func checkBudget(ctx context.Context, policy Policy, tc model.ToolCall, env *Env, opts EvalOptions) (PolicyResult, bool) {
+	if opts.Budget == nil {
+		return PolicyResult{}, false
+	}
+	id := policy.id()
+	if policy.Condition != "" {
+		match, err := traceCondition(ctx, policy, env, opts)
+		if errors.Is(err, ErrBudgetExceeded) {
+			return timeoutResult(err), true
+		}
+		if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+			return timeoutResult(ctxErr), true
+		}
+		if err != nil {
+			if opts.Observer != nil {
+				opts.Observer.ConditionError(policy, err)
+			}
+			opts.Logger.Warn("Error evaluating condition for policy %s: %v", policy.Selector(), err)
+			return PolicyResult{
+				Action:   PolicyReject,
+				Reason:   fmt.Sprintf("Budget condition failed: %v", err),
+				Matched:  true,
+				PolicyID: id,
+				Origin:   model.OriginBudget,
+			}, true
+		}
+		if !match {
+			return PolicyResult{}, false
+		}
+	}
+	exceeded, err := opts.Budget.CheckBudget(ctx, policy, tc)
+	if errors.Is(err, ErrBudgetExceeded) {
+		return timeoutResult(err), true
+	}
+	if ctxErr := ctx.Err(); err != nil && ctxErr != nil {
+		return timeoutResult(ctxErr), true
+	}
+	if err != nil {
+		return PolicyResult{
+			Action:   PolicyReject,
+			Reason:   fmt.Sprintf("Budget check failed: %v", err),
+			Matched:  true,
+			PolicyID: id,
+			Origin:   model.OriginBudget,
+		}, true
+	}
+	if exceeded == "" {
+		return PolicyResult{}, false
+	}
+	reason := policy.Reason
+	if reason == "" {
+		reason = exceeded
+	}
+	return PolicyResult{Action: PolicyBudget, Reason: reason, Matched: true, PolicyID: id, Origin: model.OriginBudget}, true
+}
+
+// CheckBudgets applies only the BUDGET policies that match a call, for calls
+// released outside EvaluatePolicyWithOptions such as approved ones. It
+// reports the first exceeded budget.
+//
+// Example:
+//
+//	if result, exceeded := policy.CheckBudgets(ctx, tc, opts); exceeded { /* result.Reason */ }
+func CheckBudgets(ctx context.Context, tc model.ToolCall, opts EvalOptions) (PolicyResult, bool) {
+	if opts.Budget == nil {
+		return PolicyResult{}, false
+	}
+	ctx = withCallScope(ctx, tc, opts.Extensions)
+	opts.Set = opts.set()
+	env := newEnv(ctx, tc, opts)
+	defer releaseEnv(env)
+	for _, p := range opts.Set.applicable(tc.Name, opts.ToolTags) {
+		if p.Type != PolicyBudget {
+			continue
+		}
+		if result, exceeded := checkBudget(ctx, p, tc, env, opts); exceeded {
+			return result, true
+		}
+	}
+	return PolicyResult{}, false
+}
+
+// timeoutResult is returned when evaluation stops before reaching a decision.
+func timeoutResult(err error) PolicyResult {
+	return PolicyResult{
+		Action:   PolicyTimeout,
+		Reason:   fmt.Sprintf("Policy evaluation stopped: %v", err),
+		Matched:  false,
+		PolicyID: "timeout",
+	}
+}
+
+// traceCondition evaluates a policy condition inside its own span.
+func traceCondition(ctx context.Context, policy Policy, env *Env, opts EvalOptions) (bool, error) {
+	if opts.Tracer == nil {
+		return evaluateCondition(ctx, policy.Condition, env, opts)
+	}
+	_, span := trace.Start(ctx, opts.Tracer, trace.SpanCondition)
+	defer span.End()
+	span.SetAttribute(trace.AttrPolicyID, policy.id())
+	span.SetAttribute(trace.AttrPolicyPriority, policy.Priority)
+	span.SetAttribute(trace.AttrCondition, policy.Condition)
+
+	match, err := evaluateCondition(ctx, policy.Condition, env, opts)
+	if err != nil {
+		span.RecordError(err)
+		return false, err
+	}
+	span.SetAttribute(trace.AttrConditionResult, match)
+	return match, nil
+}
+
+// Expression compilation cache of the default set
+var exprCache = newProgramCache()
 
 // evaluateCondition evaluates a conditional expression using the tool call context
-func evaluateCondition(condition string, tc model.ToolCall) (bool, error) {
-	// Create evaluation environment
-	env := map[string]interface{}{
-		"user": map[string]interface{}{
-			"id":          tc.Context.UserID,
-			"role":        tc.Context.UserRole,
-			"permissions": tc.Context.UserPermissions,
-		},
-		"session": map[string]interface{}{
-			"id":              tc.Context.SessionID,
-			"conversation_id": tc.Context.ConversationID,
-			"previous_calls":  tc.Context.PreviousCalls,
-		},
-		"params": tc.Parameters,
-		"tool": map[string]interface{}{
-			"name": tc.Name,
-		},
-		"time": map[string]interface{}{
-			"hour": tc.Context.TimeOfDay,
-		},
-		"request": map[string]interface{}{
-			"ip": tc.Context.IPAddress,
-		},
-		"metadata": tc.Context.Metadata,
-		// Add helper functions directly to environment
-		"len": func(arr []string) int {
-			return len(arr)
-		},
-		"contains": func(arr []string, item string) bool {
-			for _, a := range arr {
-				if a == item {
-					return true
-				}
-			}
-			return false
-		},
-		"now": func() time.Time {
-			return time.Now()
-		},
+func evaluateCondition(ctx context.Context, condition string, env *Env, opts EvalOptions) (bool, error) {
+	result, err := evaluateExpression(ctx, condition, env, opts)
+	if err != nil {
+		return false, err
 	}
+	boolResult, ok := result.(bool)
+	if !ok {
+		return false, fmt.Errorf("condition did not evaluate to boolean: %T", result)
+	}
+	return boolResult, nil
+}
+
+// EvaluateNumber evaluates a numeric expression such as a tool cost
+// ("params.amount * 0.01") in the same environment as policy conditions.
+// Like conditions, it stops when ctx is done or the expression exceeds the
+// budget in opts.
+//
+// Example:
+//
+//	cost, err := policy.EvaluateNumber(ctx, "params.amount * 0.01", tc, policy.EvalOptions{})
+func EvaluateNumber(ctx context.Context, expression string, tc model.ToolCall, opts EvalOptions) (float64, error) {
+	env := newEnv(ctx, tc, opts)
+	defer releaseEnv(env)
+	result, err := evaluateExpression(ctx, expression, env, opts)
+	if err != nil {
+		return 0, err
+	}
+	switch n := result.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	default:
+		return 0, fmt.Errorf("expression did not evaluate to a number: %T", result)
+	}
+}
+
+// evaluateExpression compiles (with caching) and runs an expression against
+// a tool call's environment.
+func evaluateExpression(ctx context.Context, condition string, env *Env, opts EvalOptions) (interface{}, error) {
+	cache, generation := opts.Extensions.programs(opts.set())
 
 	// Check cache for compiled expression
-	cacheMu.RLock()
-	program, exists := exprCache[condition]
-	cacheMu.RUnlock()
+	program, exists := cache.get(condition, generation)
+	if opts.Observer != nil {
+		opts.Observer.ExprCacheLookup(exists)
+	}
 
 	if !exists {
 		// Compile and cache the expression
 		var err error
-		program, err = expr.Compile(condition, expr.Env(env))
+		program, err = expr.Compile(condition, opts.Extensions.compileOptions()...)
 		if err != nil {
-			return false, fmt.Errorf("failed to compile condition: %w", err)
+			return nil, fmt.Errorf("failed to compile condition: %w", err)
 		}
 
 		// Cache the compiled program
-		cacheMu.Lock()
-		exprCache[condition] = program
-		cacheMu.Unlock()
+		cache.put(condition, generation, program)
 	}
 
-	result, err := expr.Run(program, env)
+	env.Call.ops, env.Call.maxOps = 0, opts.MaxConditionOperations
+	machine := vmPool.Get().(*vm.VM)
+	machine.MemoryBudget = opts.MaxConditionMemory
+	result, err := machine.Run(program, env)
+	clear(machine.Stack[:cap(machine.Stack)])
+	vmPool.Put(machine)
 	if err != nil {
-		return false, fmt.Errorf("failed to evaluate condition: %w", err)
+		if overBudget(err) || errors.Is(err, errOperationsExceeded) {
+			return nil, fmt.Errorf("%w: %v", ErrBudgetExceeded, err)
+		}
+		return nil, fmt.Errorf("failed to evaluate condition: %w", err)
 	}
+	return result, nil
+}
 
-	boolResult, ok := result.(bool)
-	if !ok {
-		return false, fmt.Errorf("condition did not evaluate to boolean: %T", result)
+// overBudget reports whether err is expr's memory budget error. expr panics
+// with a plain message, which it reports as a *file.Error.
+func overBudget(err error) bool {
+	var fileErr *file.Error
+	if !errors.As(err, &fileErr) {
+		return false
+	}
+	return fileErr.Message == exprMemoryBudgetExceeded
+}
+
+// opsFunction is the hidden helper that counts the operations of each
+// iteration of a predicate.
+const opsFunction = "hguard_ops"
+
+// opsOption declares the hidden helper to expr. It returns the predicate's
+// value after charging its operations, and stops the condition when the
+// limit is exceeded or the evaluation's context is done.
+var opsOption = expr.Function(opsFunction, func(params ...interface{}) (interface{}, error) {
+	s := params[0].(*callScope)
+	s.ops += params[1].(int)
+	if s.maxOps > 0 && s.ops > s.maxOps {
+		return nil, errOperationsExceeded
 	}
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+	return params[2], nil
+}, new(func(*callScope, int, interface{}) interface{}))
 
-	return boolResult, nil
+// opsPatcher wraps the body of every predicate in a call to the hidden
+// helper, charging the nodes of the body to each iteration.
+type opsPatcher struct{}
+
+func (opsPatcher) Visit(node *ast.Node) {
+	predicate, ok := (*node).(*ast.PredicateNode)
+	if !ok {
+		return
+	}
+	if call, ok := predicate.Node.(*ast.CallNode); ok {
+		if callee, ok := call.Callee.(*ast.IdentifierNode); ok && callee.Value == opsFunction {
+			return
+		}
+	}
+	var size nodeCounter
+	ast.Walk(&predicate.Node, &size)
+	predicate.Node = &ast.CallNode{
+		Callee:    &ast.IdentifierNode{Value: opsFunction},
+		Arguments: []ast.Node{&ast.IdentifierNode{Value: callScopeVar}, &ast.IntegerNode{Value: int(size)}, predicate.Node},
+	}
 }
 
+// nodeCounter counts the nodes of an expression.
+type nodeCounter int
+
+func (c *nodeCounter) Visit(*ast.Node) { *c++ }
+
 // ClearExpressionCache clears the compiled expression cache (useful for testing)
 func ClearExpressionCache() {
-	cacheMu.Lock()
-	defer cacheMu.Unlock()
-	exprCache = make(map[string]*vm.Program)
+	exprCache.clear()
 }
 
 // ApplyPolicy applies the policy to a tool call and returns the policy type (legacy function for backward compatibility).
@@ -265,30 +594,43 @@ func ApplyPolicy(tc model.ToolCall) PolicyType {
 	return result.Action
 }
 
-// LoadPoliciesFromYAML loads policies from a YAML file and registers them.
+// LoadPoliciesFromYAML loads policies from a YAML file, resolving its
+// includes, definitions and the overlay selected by the HGUARD_ENV
+// environment variable, and registers them in place of any loaded before.
 //
 // Example:
 //
 //	err := policy.LoadPoliciesFromYAML("policies.yaml")
 func LoadPoliciesFromYAML(path string) error {
-	f, err := os.Open(path)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
+	return LoadPoliciesForEnv(path, os.Getenv(EnvVar))
+}
 
-	var data struct {
-		Policies []Policy `yaml:"policies"`
-	}
-	if err := yaml.NewDecoder(f).Decode(&data); err != nil {
+// LoadPoliciesForEnv is LoadPoliciesFromYAML with an explicit environment.
+// An empty env applies no overlay.
+//
+// Example:
+//
+//	err := policy.LoadPoliciesForEnv("policies.yaml", "prod") // also applies policies.prod.yaml
+func LoadPoliciesForEnv(path, env string) error {
+	return LoadPoliciesWithExtensions(path, env, nil)
+}
+
+// LoadPoliciesWithExtensions is LoadPoliciesForEnv for policies evaluated
+// with ext, whose functions and providers their conditions may use.
+//
+// Example:
+//
+//	err := policy.LoadPoliciesWithExtensions("policies.yaml", "", ext)
+func LoadPoliciesWithExtensions(path, env string, ext *Extensions) error {
+	set, err := LoadSet(path, env, false, ext)
+	if err != nil {
 		return err
 	}
 
-	// Clear existing policies
-	policies = make(map[string][]Policy)
-
-	for _, p := range data.Policies {
-		RegisterPolicy(p)
-	}
+	// Replace existing policies
+	policies, patterns, combining = set.policies, set.patterns, set.combining
+	defaultVersion.Add(1)
+	sequences = set.sequences
+	roles, roleClosure = set.roles, set.roleClosure
 	return nil
 }
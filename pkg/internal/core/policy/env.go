@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// Env is the environment policy conditions, sequence rules and cost
+// expressions are evaluated in. Conditions are type-checked against it when
+// they are compiled, so a misspelled field such as user.rol fails the policy
+// load instead of silently never matching. params and metadata are free-form.
+type Env struct {
+	User     UserEnv                `expr:"user"`
+	Session  SessionEnv             `expr:"session"`
+	Params   map[string]interface{} `expr:"params"`
+	Tool     ToolEnv                `expr:"tool"`
+	Time     TimeEnv                `expr:"time"`
+	Request  RequestEnv             `expr:"request"`
+	Metadata map[string]interface{} `expr:"metadata"`
+
+	// Helpers that depend on the call; stateless helpers are registered in
+	// functions.go.
+	HasRole       func(role string) bool `expr:"has_role"`
+	HasPermission func(perm string) bool `expr:"has_permission"`
+	// Permissions backs user.permissions when providers serve the user's
+	// role or permissions, so it agrees with has_permission.
+	Permissions func() []string `expr:"hguard_permissions"`
+	// Call passes the evaluation's scope to custom functions and attribute
+	// lookups.
+	Call *callScope `expr:"hguard_call"`
+}
+
+// UserEnv describes the caller.
+type UserEnv struct {
+	ID          string   `expr:"id"`
+	Role        string   `expr:"role"`
+	Permissions []string `expr:"permissions"` // Explicit and role-granted permissions
+}
+
+// SessionEnv describes the conversation the call belongs to.
+type SessionEnv struct {
+	ID             string        `expr:"id"`
+	ConversationID string        `expr:"conversation_id"`
+	PreviousCalls  []string      `expr:"previous_calls"`
+	History        []HistoryCall `expr:"history"`
+}
+
+// HistoryCall is an earlier call recorded in the session.
+type HistoryCall struct {
+	ID        string                 `expr:"id"`
+	Tool      string                 `expr:"tool"`
+	Params    map[string]interface{} `expr:"params"`
+	Timestamp time.Time              `expr:"timestamp"`
+}
+
+// ToolEnv describes the called tool.
+type ToolEnv struct {
+	Name string   `expr:"name"`
+	Tags []string `expr:"tags"` // Tags declared on the tool's schema
+}
+
+// TimeEnv describes when the call is made.
+type TimeEnv struct {
+	Hour    int       `expr:"hour"`    // Caller-supplied hour of day (CallContext.TimeOfDay)
+	Now     time.Time `expr:"now"`     // Call timestamp, or the current time if unset
+	Weekday string    `expr:"weekday"` // Lowercase weekday of Now in UTC, e.g. "monday"
+}
+
+// RequestEnv describes the transport request behind the call.
+type RequestEnv struct {
+	IP string `expr:"ip"`
+}
+
+// envPool recycles environments between evaluations.
+var envPool = sync.Pool{New: func() interface{} { return new(Env) }}
+
+// newEnv builds the environment for a tool call. It is shared by every
+// condition of one evaluation; release it with releaseEnv when done.
+func newEnv(ctx context.Context, tc model.ToolCall, opts EvalOptions) *Env {
+	set := opts.set()
+	now := tc.Timestamp
+	if now.IsZero() {
+		now = time.Now()
+	}
+	now = now.UTC()
+	scope := scopeFrom(ctx, tc, opts.Extensions)
+	env := envPool.Get().(*Env)
+	*env = Env{
+		User: UserEnv{
+			ID:          tc.Context.UserID,
+			Role:        tc.Context.UserRole,
+			Permissions: set.EffectivePermissions(tc.Context),
+		},
+		Session: SessionEnv{
+			ID:             tc.Context.SessionID,
+			ConversationID: tc.Context.ConversationID,
+			PreviousCalls:  tc.Context.PreviousCalls,
+			History:        sessionHistory(tc.Context.History),
+		},
+		Params:   tc.Parameters,
+		Tool:     ToolEnv{Name: tc.Name, Tags: opts.ToolTags},
+		Time:     TimeEnv{Hour: tc.Context.TimeOfDay, Now: now, Weekday: strings.ToLower(now.Weekday().String())},
+		Request:  RequestEnv{IP: tc.Context.IPAddress},
+		Metadata: tc.Context.Metadata,
+		// The closures read the context through the scope so that tc
+		// stays on the stack.
+		HasRole: func(role string) bool {
+			return set.HasRole(scope.userContext(scope.tc.Context).UserRole, role)
+		},
+		HasPermission: func(perm string) bool {
+			return set.hasPermission(scope.userContext(scope.tc.Context), perm)
+		},
+		Permissions: func() []string {
+			return set.EffectivePermissions(scope.userContext(scope.tc.Context))
+		},
+		Call: scope,
+	}
+	return env
+}
+
+// releaseEnv returns env to the pool. env must not be used afterwards.
+func releaseEnv(env *Env) {
+	*env = Env{}
+	envPool.Put(env)
+}
+
+// sessionHistory exposes recorded session calls to conditions.
+func sessionHistory(calls []model.SessionCall) []HistoryCall {
+	history := make([]HistoryCall, len(calls))
+	for i, c := range calls {
+		history[i] = HistoryCall{
+			ID:        c.ToolCallID,
+			Tool:      c.ToolName,
+			Params:    c.Parameters,
+			Timestamp: c.Timestamp,
+		}
+	}
+	return history
+}
@@ -0,0 +1,267 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/expr-lang/expr"
+)
+
+// Condition functions and attribute providers registered at package level
+// (RegisterFunction, RegisterAttributeProvider) are seen by every evaluation
+// in the process. An Extensions table adds functions and providers for the
+// evaluations that pass it in EvalOptions, such as those of one Guard, so
+// two evaluators can give the same name or path different meanings. Entries
+// in the table take precedence over package-level ones.
+//
+// Example:
+//
+//	ext := policy.NewExtensions()
+//	err := ext.RegisterFunction(policy.CustomFunction{Name: "is_frozen", Fn: accounts.Frozen})
+//	set, err := policy.LoadSet("policies.yaml", "", false, ext)
+//	result := policy.EvaluatePolicyWithOptions(ctx, tc, policy.EvalOptions{Set: set, Extensions: ext})
+
+// Extensions is a table of condition functions and attribute providers. A
+// nil table has none of its own and sees only the package-level ones. It is
+// safe for concurrent use.
+type Extensions struct {
+	id        uint64
+	mu        sync.RWMutex
+	custom    map[string]*customFunction
+	functions map[string]function
+	providers map[string]AttributeProvider
+	// changes counts registrations in the table.
+	changes atomic.Uint64
+	// cache holds the conditions compiled against the table.
+	cache *programCache
+}
+
+// extensionIDs numbers tables, to key the indexes built for them.
+var extensionIDs atomic.Uint64
+
+// NewExtensions creates an empty table.
+func NewExtensions() *Extensions {
+	return &Extensions{
+		id:        extensionIDs.Add(1),
+		custom:    map[string]*customFunction{},
+		functions: map[string]function{},
+		providers: map[string]AttributeProvider{},
+		cache:     newProgramCache(),
+	}
+}
+
+// RegisterFunction makes f callable from conditions evaluated with the
+// table. Registering a name again replaces the earlier function. Built-in
+// helpers cannot be replaced.
+func (e *Extensions) RegisterFunction(f CustomFunction) error {
+	cf, helper, err := newCustomFunction(f)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	e.custom[f.Name] = cf
+	e.functions[f.Name] = helper
+	e.mu.Unlock()
+	e.changes.Add(1)
+	return nil
+}
+
+// RegisterAttributeProvider makes the provider's attributes available to
+// conditions evaluated with the table. A path registered again is served by
+// the later provider.
+func (e *Extensions) RegisterAttributeProvider(p AttributeProvider) error {
+	paths := p.Attributes()
+	for _, path := range paths {
+		if err := validAttributePath(path); err != nil {
+			return err
+		}
+	}
+	e.mu.Lock()
+	for _, path := range paths {
+		e.providers[path] = p
+	}
+	e.mu.Unlock()
+	e.changes.Add(1)
+	return nil
+}
+
+// Generation changes whenever a function or provider visible through the
+// table is registered, in the table or at package level. Anything derived
+// from conditions, such as Dependencies, is stale once it changes.
+func (e *Extensions) Generation() uint64 {
+	if e == nil {
+		return registrations.Load()
+	}
+	return registrations.Load() + e.changes.Load()
+}
+
+// Functions returns the helpers available to conditions evaluated with the
+// table, sorted by name.
+func (e *Extensions) Functions() []FunctionInfo {
+	infos := Functions()
+	if e == nil {
+		return infos
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	all := make([]FunctionInfo, 0, len(infos)+len(e.functions))
+	for _, f := range infos {
+		if _, ok := e.functions[f.Name]; !ok {
+			all = append(all, f)
+		}
+	}
+	for _, f := range e.functions {
+		all = append(all, f.FunctionInfo)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
+// Attributes returns the attribute paths served to conditions evaluated
+// with the table, sorted.
+func (e *Extensions) Attributes() []string {
+	provided := e.attributePaths()
+	paths := make([]string, 0, len(provided))
+	for path := range provided {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// CheckExpression is the package-level CheckExpression for conditions
+// evaluated with the table.
+func (e *Extensions) CheckExpression(expression string) error {
+	if _, err := expr.Compile(expression, e.compileOptions()...); err != nil {
+		return fmt.Errorf("invalid condition %q: %w", expression, err)
+	}
+	return nil
+}
+
+// function returns the custom function called name.
+func (e *Extensions) function(name string) (*customFunction, bool) {
+	if e != nil {
+		e.mu.RLock()
+		f, ok := e.custom[name]
+		e.mu.RUnlock()
+		if ok {
+			return f, true
+		}
+	}
+	customMu.RLock()
+	defer customMu.RUnlock()
+	f, ok := customFunctions[name]
+	return f, ok
+}
+
+// isCustom reports whether name is a custom function.
+func (e *Extensions) isCustom(name string) bool {
+	_, ok := e.function(name)
+	return ok
+}
+
+// provider returns the provider serving path.
+func (e *Extensions) provider(path string) (AttributeProvider, bool) {
+	if e != nil {
+		e.mu.RLock()
+		p, ok := e.providers[path]
+		e.mu.RUnlock()
+		if ok {
+			return p, true
+		}
+	}
+	attributesMu.RLock()
+	defer attributesMu.RUnlock()
+	p, ok := attributeProviders[path]
+	return p, ok
+}
+
+// attributePaths returns a snapshot of the provided paths.
+func (e *Extensions) attributePaths() map[string]bool {
+	paths := attributePaths()
+	if e == nil {
+		return paths
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.providers) == 0 {
+		return paths
+	}
+	if paths == nil {
+		paths = make(map[string]bool, len(e.providers))
+	}
+	for path := range e.providers {
+		paths[path] = true
+	}
+	return paths
+}
+
+// empty reports whether the table adds nothing to the package-level
+// registrations, so conditions compile as they do without it.
+func (e *Extensions) empty() bool {
+	if e == nil {
+		return true
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.functions) == 0 && len(e.providers) == 0
+}
+
+// programs returns the cache for conditions compiled with the table and the
+// generation to look them up at. Tables that add nothing share the set's
+// cache.
+func (e *Extensions) programs(set *Set) (*programCache, uint64) {
+	if e.empty() {
+		return set.cache, registrations.Load()
+	}
+	return e.cache, e.Generation()
+}
+
+// indexKey distinguishes the policy indexes built for the table, which skip
+// the paths its providers serve.
+func (e *Extensions) indexKey() string {
+	if e == nil {
+		return ""
+	}
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if len(e.providers) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("\x01%d:%d", e.id, e.changes.Load())
+}
+
+// compileOptions returns the expr options conditions are compiled with.
+func (e *Extensions) compileOptions() []expr.Option {
+	helpers := map[string]expr.Option{}
+	patcher := scopePatcher{custom: map[string]bool{}, attributes: e.attributePaths()}
+	functionsMu.RLock()
+	for name, opt := range exprFunctions {
+		helpers[name] = opt
+		if functions[name].custom {
+			patcher.custom[name] = true
+		}
+	}
+	functionsMu.RUnlock()
+	if e != nil {
+		e.mu.RLock()
+		for name, f := range e.functions {
+			helpers[name] = expr.Function(name, f.fn, f.types...)
+			patcher.custom[name] = true
+		}
+		e.mu.RUnlock()
+	}
+	opts := []expr.Option{expr.Env(Env{}), opsOption, expr.Patch(opsPatcher{})}
+	for _, opt := range helpers {
+		opts = append(opts, opt)
+	}
+	if len(patcher.attributes) > 0 {
+		opts = append(opts, attributeOption)
+	}
+	if len(patcher.custom) > 0 || len(patcher.attributes) > 0 {
+		opts = append(opts, expr.Patch(patcher))
+	}
+	return opts
+}
@@ -0,0 +1,488 @@
+package policy
+
+import (
+	"fmt"
+	"net"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/detect"
+	"github.com/expr-lang/expr"
+)
+
+// Helper functions available to policy conditions. Every helper is declared
+// in builtinFunctions with its Go signature, which expr uses to type-check
+// calls when a condition is compiled. expr's own builtins (len, now, abs,
+// upper, date, duration, ...) remain available.
+//
+// Example conditions:
+//
+//	ip_in(request.ip, '10.0.0.0/8', '192.168.0.0/16')
+//	weekday(in_timezone(time.now, 'Europe/Berlin')) in ['saturday', 'sunday']
+//	days_between(time.now, parse_date(params.due_date)) > 30
+//	email_domain(params.to) != 'example.com'
+//	between(params.amount, 1, 10000)
+
+// FunctionInfo documents a condition helper.
+type FunctionInfo struct {
+	Name        string `json:"name"`
+	Signature   string `json:"signature"`
+	Description string `json:"description"`
+}
+
+// function is a registered condition helper.
+type function struct {
+	FunctionInfo
+	fn     func(params ...interface{}) (interface{}, error)
+	types  []interface{}
+	custom bool // registered with RegisterFunction or in an Extensions table
+}
+
+// builtinFunctions returns the stateless helpers, plus documentation for the
+// call-dependent helpers that live on Env.
+func builtinFunctions() []function {
+	return []function{
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "has_role",
+				Signature:   "has_role(role string) bool",
+				Description: "Whether the caller's role is role or inherits from it",
+			},
+			types: []interface{}{new(func(string) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "has_permission",
+				Signature:   "has_permission(perm string) bool",
+				Description: "Whether the caller holds perm explicitly or through its role",
+			},
+			types: []interface{}{new(func(string) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "ip_in",
+				Signature:   "ip_in(ip string, ranges ...string) bool",
+				Description: "Whether ip is inside any of the CIDR ranges (or equals a plain address)",
+			},
+			fn:    ipIn,
+			types: []interface{}{new(func(string, ...string) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "in_timezone",
+				Signature:   "in_timezone(t time, zone string) time",
+				Description: "t converted to an IANA time zone such as 'America/New_York'",
+			},
+			fn:    inTimezone,
+			types: []interface{}{new(func(time.Time, string) time.Time)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "weekday",
+				Signature:   "weekday(t time) string",
+				Description: "Lowercase weekday name of t, e.g. 'monday'",
+			},
+			fn: func(params ...interface{}) (interface{}, error) {
+				return strings.ToLower(params[0].(time.Time).Weekday().String()), nil
+			},
+			types: []interface{}{new(func(time.Time) string)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "is_weekend",
+				Signature:   "is_weekend(t time) bool",
+				Description: "Whether t falls on a Saturday or Sunday",
+			},
+			fn: func(params ...interface{}) (interface{}, error) {
+				d := params[0].(time.Time).Weekday()
+				return d == time.Saturday || d == time.Sunday, nil
+			},
+			types: []interface{}{new(func(time.Time) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "parse_date",
+				Signature:   "parse_date(v any) time",
+				Description: "Parses an RFC 3339 timestamp, a YYYY-MM-DD date (UTC) or Unix seconds",
+			},
+			fn:    parseDate,
+			types: []interface{}{new(func(interface{}) time.Time)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "days_between",
+				Signature:   "days_between(a, b time) float",
+				Description: "Days from a to b, negative if b is earlier",
+			},
+			fn: func(params ...interface{}) (interface{}, error) {
+				return params[1].(time.Time).Sub(params[0].(time.Time)).Hours() / 24, nil
+			},
+			types: []interface{}{new(func(time.Time, time.Time) float64)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "regex_match",
+				Signature:   "regex_match(s any, pattern string) bool",
+				Description: "Whether s contains a match of the regular expression",
+			},
+			fn: func(params ...interface{}) (interface{}, error) {
+				re, err := compileRegex(params[1].(string))
+				if err != nil {
+					return nil, err
+				}
+				return re.MatchString(text(params[0])), nil
+			},
+			types: []interface{}{new(func(interface{}, string) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "regex_find",
+				Signature:   "regex_find(s any, pattern string) string",
+				Description: "The first match of the regular expression in s, or ''",
+			},
+			fn: func(params ...interface{}) (interface{}, error) {
+				re, err := compileRegex(params[1].(string))
+				if err != nil {
+					return nil, err
+				}
+				return re.FindString(text(params[0])), nil
+			},
+			types: []interface{}{new(func(interface{}, string) string)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "email_domain",
+				Signature:   "email_domain(address any) string",
+				Description: "Lowercase domain of an email address, or ''",
+			},
+			fn: func(params ...interface{}) (interface{}, error) {
+				return emailDomain(text(params[0])), nil
+			},
+			types: []interface{}{new(func(interface{}) string)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "url_domain",
+				Signature:   "url_domain(url any) string",
+				Description: "Lowercase host of a URL, or ''",
+			},
+			fn: func(params ...interface{}) (interface{}, error) {
+				return urlDomain(text(params[0])), nil
+			},
+			types: []interface{}{new(func(interface{}) string)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "domain_in",
+				Signature:   "domain_in(domain string, domains ...string) bool",
+				Description: "Whether domain equals, or is a subdomain of, any of domains",
+			},
+			fn:    domainIn,
+			types: []interface{}{new(func(string, ...string) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "to_number",
+				Signature:   "to_number(v any) float",
+				Description: "v as a number, parsing numeric strings",
+			},
+			fn: func(params ...interface{}) (interface{}, error) {
+				return toNumber(params[0])
+			},
+			types: []interface{}{new(func(interface{}) float64)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "between",
+				Signature:   "between(x any, lo, hi float) bool",
+				Description: "Whether lo <= x <= hi; false if x is missing",
+			},
+			fn:    between,
+			types: []interface{}{new(func(interface{}, float64, float64) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "looks_like_sql_injection",
+				Signature:   "looks_like_sql_injection(v any) bool",
+				Description: "Whether the sql_injection detector fires on v",
+			},
+			fn:    detectorFunc(detect.SQLInjection),
+			types: []interface{}{new(func(interface{}) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "has_url",
+				Signature:   "has_url(v any) bool",
+				Description: "Whether the url detector fires on v",
+			},
+			fn:    detectorFunc(detect.URL),
+			types: []interface{}{new(func(interface{}) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "has_shell_metachars",
+				Signature:   "has_shell_metachars(v any) bool",
+				Description: "Whether the shell_metachars detector fires on v",
+			},
+			fn:    detectorFunc(detect.ShellMetachars),
+			types: []interface{}{new(func(interface{}) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "contains_instruction_override",
+				Signature:   "contains_instruction_override(v any) bool",
+				Description: "Whether the instruction_override detector fires on v",
+			},
+			fn:    detectorFunc(detect.InstructionOverride),
+			types: []interface{}{new(func(interface{}) bool)},
+		},
+		{
+			FunctionInfo: FunctionInfo{
+				Name:        "detector_score",
+				Signature:   "detector_score(name string, v any) float",
+				Description: "Score from 0 to 1 of the named detector on v",
+			},
+			fn: func(params ...interface{}) (interface{}, error) {
+				return detect.Score(params[0].(string), text(params[1]))
+			},
+			types: []interface{}{new(func(string, interface{}) float64)},
+		},
+	}
+}
+
+var (
+	functionsMu   sync.RWMutex
+	functions     = map[string]function{}
+	exprFunctions = map[string]expr.Option{}
+)
+
+func init() {
+	for _, f := range builtinFunctions() {
+		registerFunction(f)
+	}
+}
+
+// registerFunction adds a helper to the registry and, unless it lives on
+// Env, to the options conditions are compiled with.
+func registerFunction(f function) {
+	if f.Signature == "" {
+		f.Signature = f.Name + strings.TrimPrefix(reflect.TypeOf(f.types[0]).Elem().String(), "func")
+	}
+	functionsMu.Lock()
+	defer functionsMu.Unlock()
+	functions[f.Name] = f
+	if f.fn != nil {
+		exprFunctions[f.Name] = expr.Function(f.Name, f.fn, f.types...)
+	}
+}
+
+// Functions returns the helpers available to conditions, sorted by name.
+//
+// Example:
+//
+//	for _, f := range policy.Functions() { fmt.Println(f.Signature) }
+func Functions() []FunctionInfo {
+	functionsMu.RLock()
+	infos := make([]FunctionInfo, 0, len(functions))
+	for _, f := range functions {
+		infos = append(infos, f.FunctionInfo)
+	}
+	functionsMu.RUnlock()
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// CheckExpression compiles an expression against Env without running it,
+// reporting unknown fields, unknown functions and type errors.
+//
+// Example:
+//
+//	err := policy.CheckExpression("user.rol == 'admin'") // type policy.UserEnv has no field rol
+func CheckExpression(expression string) error {
+	var ext *Extensions
+	return ext.CheckExpression(expression)
+}
+
+func ipIn(params ...interface{}) (interface{}, error) {
+	ip := net.ParseIP(strings.TrimSpace(params[0].(string)))
+	if ip == nil {
+		return false, nil
+	}
+	for _, r := range params[1:] {
+		r := r.(string)
+		if !strings.Contains(r, "/") {
+			if other := net.ParseIP(r); other != nil && other.Equal(ip) {
+				return true, nil
+			}
+			continue
+		}
+		_, network, err := net.ParseCIDR(r)
+		if err != nil {
+			return nil, fmt.Errorf("ip_in: %w", err)
+		}
+		if network.Contains(ip) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+var locations sync.Map // time zone name -> *time.Location
+
+func inTimezone(params ...interface{}) (interface{}, error) {
+	name := params[1].(string)
+	loc, ok := locations.Load(name)
+	if !ok {
+		l, err := time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("in_timezone: %w", err)
+		}
+		loc, _ = locations.LoadOrStore(name, l)
+	}
+	return params[0].(time.Time).In(loc.(*time.Location)), nil
+}
+
+// dateLayouts are tried in order by parse_date.
+var dateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+
+func parseDate(params ...interface{}) (interface{}, error) {
+	switch v := params[0].(type) {
+	case time.Time:
+		return v, nil
+	case string:
+		for _, layout := range dateLayouts {
+			if t, err := time.Parse(layout, strings.TrimSpace(v)); err == nil {
+				return t, nil
+			}
+		}
+		return nil, fmt.Errorf("parse_date: unrecognized date %q", v)
+	case nil:
+		return nil, fmt.Errorf("parse_date: missing value")
+	default:
+		secs, err := toNumber(v)
+		if err != nil {
+			return nil, fmt.Errorf("parse_date: %w", err)
+		}
+		return time.Unix(int64(secs), 0).UTC(), nil
+	}
+}
+
+var regexps sync.Map // pattern -> *regexp.Regexp
+
+func compileRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := regexps.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	regexps.Store(pattern, re)
+	return re, nil
+}
+
+func emailDomain(s string) string {
+	if addr, err := mail.ParseAddress(s); err == nil {
+		s = addr.Address
+	}
+	i := strings.LastIndex(s, "@")
+	if i < 0 {
+		return ""
+	}
+	return strings.ToLower(strings.TrimSpace(s[i+1:]))
+}
+
+func urlDomain(s string) string {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "://") {
+		s = "//" + s
+	}
+	u, err := url.Parse(s)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+func domainIn(params ...interface{}) (interface{}, error) {
+	domain := strings.ToLower(strings.TrimSuffix(params[0].(string), "."))
+	if domain == "" {
+		return false, nil
+	}
+	for _, d := range params[1:] {
+		d := strings.ToLower(strings.TrimSuffix(d.(string), "."))
+		if domain == d || strings.HasSuffix(domain, "."+d) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// toNumber converts numeric values and numeric strings to float64.
+func toNumber(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case float32:
+		return float64(n), nil
+	case int:
+		return float64(n), nil
+	case int64:
+		return float64(n), nil
+	case int32:
+		return float64(n), nil
+	case uint:
+		return float64(n), nil
+	case uint64:
+		return float64(n), nil
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(n), 64)
+		if err != nil {
+			return 0, fmt.Errorf("not a number: %q", n)
+		}
+		return f, nil
+	default:
+		return 0, fmt.Errorf("not a number: %T", v)
+	}
+}
+
+func between(params ...interface{}) (interface{}, error) {
+	if params[0] == nil {
+		return false, nil
+	}
+	x, err := toNumber(params[0])
+	if err != nil {
+		return nil, fmt.Errorf("between: %w", err)
+	}
+	lo, _ := toNumber(params[1])
+	hi, _ := toNumber(params[2])
+	return x >= lo && x <= hi, nil
+}
+
+// detectorFunc returns a helper reporting whether the named detector fires
+// on its argument.
+func detectorFunc(name string) func(params ...interface{}) (interface{}, error) {
+	return func(params ...interface{}) (interface{}, error) {
+		return detect.Fires(name, text(params[0])), nil
+	}
+}
+
+// text converts a condition argument to the string scanned by detectors and
+// regexes. Missing parameters scan as empty.
+func text(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	default:
+		return fmt.Sprint(val)
+	}
+}
@@ -0,0 +1,83 @@
+package policy
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestCheckExpression(t *testing.T) {
+	tests := []struct {
+		condition string
+		want      string
+	}{
+		{"user.role == 'admin'", ""},
+		{"params.anything > 3 && metadata.tenant_id == 'acme'", ""},
+		{"any(session.history, .tool == 'search')", ""},
+		{"user.rol == 'admin'", "no field rol"},
+		{"usr.role == 'admin'", "unknown name usr"},
+		{"ip_in(request.ip, 10)", "cannot use int"},
+		{"is_blocked(params.account)", "unknown name is_blocked"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			err := CheckExpression(tt.condition)
+			if tt.want == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.want != "" && (err == nil || !strings.Contains(err.Error(), tt.want)) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestHelperFunctions(t *testing.T) {
+	tc := model.ToolCall{
+		Name: "send_email",
+		Parameters: map[string]interface{}{
+			"to":     "Jane Doe <Jane@Mail.Example.com>",
+			"link":   "https://docs.example.org/a?b=c",
+			"due":    "2026-03-20",
+			"amount": "250.5",
+			"code":   "INV-2041",
+		},
+		Context: model.CallContext{IPAddress: "10.1.2.3"},
+		// A Saturday, 23:30 UTC
+		Timestamp: time.Date(2026, 3, 14, 23, 30, 0, 0, time.UTC),
+	}
+	tests := []struct {
+		condition string
+		expected  bool
+	}{
+		{"ip_in(request.ip, '10.0.0.0/8')", true},
+		{"ip_in(request.ip, '192.168.0.0/16', '10.1.2.4')", false},
+		{"time.weekday == 'saturday' && is_weekend(time.now)", true},
+		{"weekday(in_timezone(time.now, 'Asia/Tokyo')) == 'sunday'", true},
+		{"in_timezone(time.now, 'America/New_York').Hour() == 19", true},
+		{"days_between(time.now, parse_date(params.due)) > 5", true},
+		{"parse_date(1700000000).Year() == 2023", true},
+		{"regex_match(params.code, '^INV-\\\\d+$')", true},
+		{"regex_find(params.code, '\\\\d+') == '2041'", true},
+		{"email_domain(params.to) == 'mail.example.com'", true},
+		{"domain_in(email_domain(params.to), 'example.com')", true},
+		{"domain_in(url_domain(params.link), 'example.com')", false},
+		{"between(params.amount, 100, 300)", true},
+		{"between(params.missing, 0, 10)", false},
+		{"to_number(params.amount) > 250", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			match, err := evaluateCondition(context.Background(), tt.condition, newEnv(context.Background(), tc, EvalOptions{}), EvalOptions{})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if match != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, match)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package policy
+
+import (
+	"reflect"
+	"slices"
+
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/parser"
+)
+
+// Large policy sets mostly hold policies that concern a few callers or
+// operations, such as "user.role == 'auditor' && params.amount > 100". Each
+// merged policy list is indexed by the equality predicates its conditions
+// require, so an evaluation only runs the conditions that can still match.
+//
+// A policy is indexed under one top-level && term comparing an indexable
+// path with a string literal. A call with a different value can only make
+// the condition false, and the engine skips false conditions like conditions
+// that fail. Skipping the policy up front therefore never changes the
+// decision. Skipped conditions are not reported to the Observer. Policies
+// without such a term are evaluated for every call.
+//
+// Indexable paths are user.id, user.role, session.id,
+// session.conversation_id, tool.name, request.ip, params.<name> and
+// metadata.<name>, unless an attribute provider serves them.
+//
+// Example:
+//
+//	condition: "user.role == 'auditor' && params.amount > 100" // only for auditors
+//	condition: "params.operation == 'refund'"                  // only for refunds
+//	condition: "params.amount > 100"                           // for every call
+
+// policyIndex is a merged policy list with its predicate index.
+type policyIndex struct {
+	policies []Policy
+	// always lists the positions of policies evaluated for every call.
+	always []int
+	keys   []indexKey
+}
+
+// indexKey maps the values of one path to the positions of the policies
+// that require them.
+type indexKey struct {
+	root, name string
+	values     map[string][]int
+}
+
+// indexablePaths are the fixed paths with string values. Any params and
+// metadata entry may be indexed too.
+var indexablePaths = map[string]bool{
+	"user.id": true, "user.role": true, "session.id": true, "session.conversation_id": true,
+	"tool.name": true, "request.ip": true,
+}
+
+// newPolicyIndex indexes a merged, priority-ordered policy list. Provided
+// paths are not indexed.
+func newPolicyIndex(list []Policy, provided map[string]bool) *policyIndex {
+	idx := &policyIndex{policies: list}
+	keys := map[string]int{}
+	for i, p := range list {
+		root, name, value, ok := equalityPredicate(p.Condition, provided)
+		if !ok {
+			idx.always = append(idx.always, i)
+			continue
+		}
+		path := root + "." + name
+		k, seen := keys[path]
+		if !seen {
+			k = len(idx.keys)
+			keys[path] = k
+			idx.keys = append(idx.keys, indexKey{root: root, name: name, values: map[string][]int{}})
+		}
+		idx.keys[k].values[value] = append(idx.keys[k].values[value], i)
+	}
+	return idx
+}
+
+// candidates returns the positions of the policies that may match the call
+// env describes, in priority order. The result may be buf, grown, or a list
+// owned by the index; callers must not modify it.
+func (idx *policyIndex) candidates(env *Env, buf *[]int) []int {
+	if len(idx.keys) == 0 {
+		return idx.always
+	}
+	positions := (*buf)[:0]
+	for _, k := range idx.keys {
+		if value, ok := k.lookup(env); ok {
+			positions = append(positions, k.values[value]...)
+		}
+	}
+	if len(positions) == 0 {
+		return idx.always
+	}
+	positions = append(positions, idx.always...)
+	slices.Sort(positions)
+	*buf = positions
+	return positions
+}
+
+// lookup returns the call's value for the key's path, or false if it is not
+// a string, which no string literal equals.
+func (k indexKey) lookup(env *Env) (string, bool) {
+	switch k.root {
+	case "user":
+		if k.name == "id" {
+			return env.User.ID, true
+		}
+		return env.User.Role, true
+	case "session":
+		if k.name == "id" {
+			return env.Session.ID, true
+		}
+		return env.Session.ConversationID, true
+	case "tool":
+		return env.Tool.Name, true
+	case "request":
+		return env.Request.IP, true
+	case "params":
+		return stringValue(env.Params[k.name])
+	case "metadata":
+		return stringValue(env.Metadata[k.name])
+	}
+	return "", false
+}
+
+// stringValue returns v as a string the way expr compares it, following
+// pointers. Named string types do not equal string literals in expr.
+func stringValue(v interface{}) (string, bool) {
+	if s, ok := v.(string); ok {
+		return s, true
+	}
+	if v == nil {
+		return "", false
+	}
+	rv := reflect.ValueOf(v)
+	for rv.Kind() == reflect.Ptr || rv.Kind() == reflect.Interface {
+		if rv.IsNil() {
+			return "", false
+		}
+		rv = rv.Elem()
+	}
+	s, ok := rv.Interface().(string)
+	return s, ok
+}
+
+// equalityPredicate finds the first top-level && term of a condition that
+// compares an indexable path with a string literal.
+func equalityPredicate(condition string, provided map[string]bool) (root, name, value string, ok bool) {
+	if condition == "" {
+		return "", "", "", false
+	}
+	tree, err := parser.Parse(condition)
+	if err != nil {
+		return "", "", "", false
+	}
+	for _, term := range conjunction(tree.Node, nil) {
+		b, isBinary := term.(*ast.BinaryNode)
+		if !isBinary || b.Operator != "==" {
+			continue
+		}
+		for _, sides := range [2][2]ast.Node{{b.Left, b.Right}, {b.Right, b.Left}} {
+			lit, isString := sides[1].(*ast.StringNode)
+			if !isString {
+				continue
+			}
+			if root, name, ok := indexablePath(sides[0], provided); ok {
+				return root, name, lit.Value, true
+			}
+		}
+	}
+	return "", "", "", false
+}
+
+// conjunction appends the terms of a chain of && (or "and") to terms.
+func conjunction(node ast.Node, terms []ast.Node) []ast.Node {
+	if b, ok := node.(*ast.BinaryNode); ok && (b.Operator == "&&" || b.Operator == "and") {
+		terms = conjunction(b.Left, terms)
+		return conjunction(b.Right, terms)
+	}
+	return append(terms, node)
+}
+
+// indexablePath matches a plain root.name access to an indexable path.
+func indexablePath(node ast.Node, provided map[string]bool) (root, name string, ok bool) {
+	m, isMember := node.(*ast.MemberNode)
+	if !isMember || m.Optional || m.Method {
+		return "", "", false
+	}
+	base, isIdent := m.Node.(*ast.IdentifierNode)
+	prop, isString := m.Property.(*ast.StringNode)
+	if !isIdent || !isString {
+		return "", "", false
+	}
+	root, name = base.Value, prop.Value
+	path := root + "." + name
+	if provided[path] || !(indexablePaths[path] || root == "params" || root == "metadata") {
+		return "", "", false
+	}
+	return root, name, true
+}
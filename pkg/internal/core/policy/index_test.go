@@ -0,0 +1,199 @@
+package policy
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestEqualityPredicate(t *testing.T) {
+	tests := []struct {
+		condition string
+		provided  map[string]bool
+		path      string
+		value     string
+	}{
+		{"user.role == 'admin'", nil, "user.role", "admin"},
+		{"'refund' == params.operation", nil, "params.operation", "refund"},
+		{"params.amount > 10 and metadata.tier == 'gold' && user.id == 'u1'", nil, "metadata.tier", "gold"},
+		{"params['operation'] == 'refund'", nil, "params.operation", "refund"},
+		{"tool.name == 'transfer'", nil, "tool.name", "transfer"},
+		{"user.role == 'admin' || params.amount > 10", nil, "", ""},
+		{"!(user.role == 'admin')", nil, "", ""},
+		{"user.role != 'admin'", nil, "", ""},
+		{"params.amount == 10", nil, "", ""},
+		{"params.order.id == 'o1'", nil, "", ""},
+		{"params?.operation == 'refund'", nil, "", ""},
+		{"user.role == 'admin'", map[string]bool{"user.role": true}, "", ""},
+		{"", nil, "", ""},
+		{"user.role ==", nil, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.condition, func(t *testing.T) {
+			root, name, value, ok := equalityPredicate(tt.condition, tt.provided)
+			path := ""
+			if ok {
+				path = root + "." + name
+			}
+			if path != tt.path || value != tt.value {
+				t.Errorf("Expected %q = %q, got %q = %q", tt.path, tt.value, path, value)
+			}
+		})
+	}
+}
+
+func TestIndexCandidates(t *testing.T) {
+	set := NewSet(false)
+	for i := 0; i < 1000; i++ {
+		set.Register(Policy{ToolName: "transfer", Type: PolicyReject, Condition: fmt.Sprintf("params.operation == 'op_%d' && params.amount > %d", i, i), Priority: i % 7})
+	}
+	set.Register(Policy{ID: "large", ToolName: "*", Type: PolicyRequireApproval, Condition: "params.amount > 500"})
+
+	tc := model.ToolCall{Name: "transfer", Parameters: map[string]interface{}{"amount": 600, "operation": "op_5"}}
+	opts := EvalOptions{Set: set}
+	env := newEnv(context.Background(), tc, opts)
+	defer releaseEnv(env)
+	if n := len(set.index("transfer", nil, nil).candidates(env, new([]int))); n != 2 {
+		t.Errorf("Expected 2 candidates, got %d", n)
+	}
+	if result := EvaluatePolicyWithOptions(context.Background(), tc, opts); result.Action != PolicyReject {
+		t.Errorf("Expected the op_5 policy to reject, got %s", result.PolicyID)
+	}
+	tc.Parameters["operation"] = "other"
+	if result := EvaluatePolicyWithOptions(context.Background(), tc, opts); result.PolicyID != "large" {
+		t.Errorf("Expected large, got %s", result.PolicyID)
+	}
+}
+
+func TestIndexSurvivesUnrelatedChanges(t *testing.T) {
+	acme, other := NewSet(false), NewSet(true)
+	acme.Register(Policy{ToolName: "transfer", Type: PolicyReject, Condition: "params.amount > 10"})
+	idx := acme.index("transfer", nil, nil)
+
+	other.Register(Policy{ToolName: "transfer", Type: PolicyAllow})
+	if acme.index("transfer", nil, nil) != idx {
+		t.Error("Expected a change to another set to keep the index")
+	}
+	acme.Register(Policy{ToolName: "transfer", Type: PolicyAllow})
+	if acme.index("transfer", nil, nil) == idx {
+		t.Error("Expected a change to the set to rebuild the index")
+	}
+
+	idx = other.index("transfer", nil, nil)
+	defer resetPolicies()
+	RegisterPolicy(Policy{ToolName: "transfer", Type: PolicyReject})
+	if other.index("transfer", nil, nil) == idx {
+		t.Error("Expected a change to the default set to rebuild a layered set's index")
+	}
+}
+
+// TestIndexMatchesLinear checks on random policy sets and calls that the
+// indexed engine decides exactly like evaluating every applicable policy.
+func TestIndexMatchesLinear(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	pick := func(options ...string) string { return options[rng.Intn(len(options))] }
+	term := func() string {
+		switch rng.Intn(10) {
+		case 0:
+			return fmt.Sprintf("user.role == '%s'", pick("admin", "user", "auditor"))
+		case 1:
+			return fmt.Sprintf("'%s' == user.role", pick("admin", "user"))
+		case 2:
+			return fmt.Sprintf("params.operation == '%s'", pick("refund", "charge"))
+		case 3:
+			return fmt.Sprintf("metadata.tier == '%s'", pick("gold", "silver"))
+		case 4:
+			return fmt.Sprintf("tool.name == '%s'", pick("transfer", "refund"))
+		case 5:
+			return fmt.Sprintf("user.id == '%s'", pick("u1", "u2"))
+		case 6:
+			return fmt.Sprintf("params.amount > %d", rng.Intn(10))
+		case 7:
+			return "has_role('admin')"
+		case 8:
+			return "params.operation != 'refund'"
+		default:
+			return fmt.Sprintf("params.amount == %d", rng.Intn(10))
+		}
+	}
+	condition := func() string {
+		if rng.Intn(7) == 0 {
+			return ""
+		}
+		terms := []string{term()}
+		for rng.Intn(2) == 0 {
+			terms = append(terms, pick("&&", "and", "||"), term())
+		}
+		c := strings.Join(terms, " ")
+		if rng.Intn(8) == 0 {
+			c = "!(" + c + ")"
+		}
+		return c
+	}
+	type label string
+	refund := "refund"
+	call := func() model.ToolCall {
+		params := map[string]interface{}{"amount": rng.Intn(10)}
+		switch rng.Intn(6) {
+		case 0:
+			params["operation"] = "refund"
+		case 1:
+			params["operation"] = "charge"
+		case 2:
+			params["operation"] = &refund
+		case 3:
+			params["operation"] = label("refund")
+		case 4:
+			params["operation"] = 7
+		}
+		return model.ToolCall{
+			Name:       pick("transfer", "refund", "report"),
+			Parameters: params,
+			Context: model.CallContext{
+				UserID:   pick("u1", "u2", ""),
+				UserRole: pick("admin", "user", "auditor", ""),
+				Metadata: map[string]interface{}{"tier": pick("gold", "silver", "")},
+			},
+		}
+	}
+	types := []PolicyType{PolicyReject, PolicyAllow, PolicyLog, PolicyRequireApproval, PolicyRewrite}
+	algorithms := []CombiningAlgorithm{FirstApplicable, DenyOverrides, PermitOverrides, AllMustAllow}
+
+	for round := 0; round < 200; round++ {
+		set := NewSet(false)
+		for i, n := 0, 1+rng.Intn(30); i < n; i++ {
+			set.Register(Policy{
+				ID:        fmt.Sprintf("p%d", i),
+				ToolName:  pick("transfer", "refund", "*", "trans*"),
+				Type:      types[rng.Intn(len(types))],
+				Condition: condition(),
+				Priority:  rng.Intn(4),
+			})
+		}
+		opts := EvalOptions{Set: set, Combining: algorithms[rng.Intn(len(algorithms))]}
+		for i := 0; i < 30; i++ {
+			tc := call()
+			indexed := EvaluatePolicyWithOptions(context.Background(), tc, opts)
+			if linear := evaluateLinear(tc, opts); indexed != linear {
+				t.Fatalf("Round %d, call %+v: indexed %+v, linear %+v\npolicies: %+v", round, tc, indexed, linear, set.AllPolicies(tc.Name))
+			}
+		}
+	}
+}
+
+// evaluateLinear evaluates every applicable policy, without the index.
+func evaluateLinear(tc model.ToolCall, opts EvalOptions) PolicyResult {
+	ctx := withCallScope(context.Background(), tc, nil)
+	env := newEnv(ctx, tc, opts)
+	defer releaseEnv(env)
+	list := opts.Set.applicable(tc.Name, opts.ToolTags)
+	all := make([]int, len(list))
+	for i := range all {
+		all[i] = i
+	}
+	return evaluateCandidates(ctx, tc, env, opts, list, all)
+}
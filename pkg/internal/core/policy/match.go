@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Policies can target more than one tool. tool_name accepts an exact name,
+// a glob (admin_*), a regular expression between slashes (/^db_.*_write$/)
+// or a list of any of these, and tags selects tools by the tags declared on
+// their schemas. A policy applies when any of its selectors matches.
+//
+// Example YAML:
+//
+//	policies:
+//	  - tool_name: "admin_*"
+//	    type: REJECT
+//	    condition: "!has_role('admin')"
+//	  - tool_name: [delete_file, "/^db_.*_write$/"]
+//	    type: REQUIRE_APPROVAL
+//	  - tags: destructive
+//	    type: REJECT
+//	    condition: "user.role == 'guest'"
+
+// UnmarshalYAML accepts tool_name as a string or a list, and tags as a
+// single tag or a list.
+func (p *Policy) UnmarshalYAML(value *yaml.Node) error {
+	type plain Policy
+	node := *value
+	var names []string
+	if value.Kind == yaml.MappingNode {
+		node.Content = make([]*yaml.Node, 0, len(value.Content))
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			k, v := value.Content[i], value.Content[i+1]
+			switch {
+			case k.Value == "tool_name" && v.Kind == yaml.SequenceNode:
+				if err := v.Decode(&names); err != nil {
+					return err
+				}
+				continue
+			case k.Value == "tags" && v.Kind == yaml.ScalarNode:
+				v = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Content: []*yaml.Node{v}}
+			}
+			node.Content = append(node.Content, k, v)
+		}
+	}
+	if err := node.Decode((*plain)(p)); err != nil {
+		return err
+	}
+	p.ToolNames = append(p.ToolNames, names...)
+	return nil
+}
+
+// isPattern reports whether the policy needs a matcher rather than an exact
+// tool name lookup.
+func (p Policy) isPattern() bool {
+	return len(p.ToolNames) > 0 || len(p.Tags) > 0 || isToolPattern(p.ToolName)
+}
+
+// Selector describes the tools a policy targets, for IDs, log messages and
+// metrics: its tool name, tool_names and tags joined by commas, such as
+// "send_*,tag=payments".
+func (p Policy) Selector() string {
+	var parts []string
+	if p.ToolName != "" {
+		parts = append(parts, p.ToolName)
+	}
+	parts = append(parts, p.ToolNames...)
+	for _, tag := range p.Tags {
+		parts = append(parts, "tag="+tag)
+	}
+	return strings.Join(parts, ",")
+}
+
+// isToolPattern reports whether name is a glob or a regex rather than a
+// literal tool name. The lone "*" is handled as a literal wildcard key.
+func isToolPattern(name string) bool {
+	if name == "*" {
+		return false
+	}
+	return isRegexPattern(name) || strings.ContainsAny(name, "*?[")
+}
+
+func isRegexPattern(name string) bool {
+	return len(name) > 2 && strings.HasPrefix(name, "/") && strings.HasSuffix(name, "/")
+}
+
+// toolMatcher matches tool names and tags against a policy's selectors.
+type toolMatcher struct {
+	names   map[string]bool
+	globs   []string
+	regexps []*regexp.Regexp
+	tags    map[string]bool
+}
+
+// compileMatcher builds the matcher for a policy, failing on invalid globs
+// and regular expressions.
+func compileMatcher(p Policy) (*toolMatcher, error) {
+	m := &toolMatcher{names: map[string]bool{}, tags: map[string]bool{}}
+	var selectors []string
+	if p.ToolName != "" {
+		selectors = append(selectors, p.ToolName)
+	}
+	for _, name := range append(selectors, p.ToolNames...) {
+		switch {
+		case isRegexPattern(name):
+			re, err := regexp.Compile(name[1 : len(name)-1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid tool_name regex %s: %w", name, err)
+			}
+			m.regexps = append(m.regexps, re)
+		case name == "*" || isToolPattern(name):
+			if _, err := path.Match(name, ""); err != nil {
+				return nil, fmt.Errorf("invalid tool_name glob %q: %w", name, err)
+			}
+			m.globs = append(m.globs, name)
+		default:
+			m.names[name] = true
+		}
+	}
+	for _, tag := range p.Tags {
+		m.tags[tag] = true
+	}
+	return m, nil
+}
+
+// matches reports whether a tool with the given name and tags is selected.
+func (m *toolMatcher) matches(toolName string, tags []string) bool {
+	if m.names[toolName] {
+		return true
+	}
+	for _, g := range m.globs {
+		if ok, _ := path.Match(g, toolName); ok {
+			return true
+		}
+	}
+	for _, re := range m.regexps {
+		if re.MatchString(toolName) {
+			return true
+		}
+	}
+	for _, tag := range tags {
+		if m.tags[tag] {
+			return true
+		}
+	}
+	return false
+}
+
+// patternPolicy is a policy registered with a compiled matcher.
+type patternPolicy struct {
+	policy  Policy
+	matcher *toolMatcher
+}
+
+// patternList holds a set's pattern policies in registration order. It is
+// shared by pointer so the default set's views all see new registrations.
+type patternList struct {
+	items []patternPolicy
+}
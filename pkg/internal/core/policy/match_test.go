@@ -0,0 +1,77 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestToolNamePatterns(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policies.yaml")
+	content := `
+policies:
+  - tool_name: "admin_*"
+    type: REJECT
+    condition: "user.role != 'admin'"
+  - tool_name: [delete_file, "/^db_.*_write$/"]
+    type: REQUIRE_APPROVAL
+  - tags: destructive
+    type: REJECT
+    condition: "user.role == 'guest'"
+    priority: 10
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	set, err := LoadSet(path, "", false, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		tool     string
+		tags     []string
+		role     string
+		expected PolicyType
+	}{
+		{"Glob matches", "admin_reset", nil, "user", PolicyReject},
+		{"Glob does not match", "reset_admin", nil, "user", PolicyAllow},
+		{"List entry matches", "delete_file", nil, "user", PolicyRequireApproval},
+		{"Regex in list matches", "db_orders_write", nil, "user", PolicyRequireApproval},
+		{"Anchored regex does not match", "db_orders_write_log", nil, "user", PolicyAllow},
+		{"Tag matches", "wipe_disk", []string{"destructive"}, "guest", PolicyReject},
+		{"Tag outranks list by priority", "delete_file", []string{"destructive"}, "guest", PolicyReject},
+		{"Other tags ignored", "wipe_disk", []string{"financial"}, "guest", PolicyAllow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tc := model.ToolCall{Name: tt.tool, Context: model.CallContext{UserRole: tt.role}}
+			result := EvaluatePolicyWithOptions(context.Background(), tc, EvalOptions{Set: set, ToolTags: tt.tags})
+			if result.Action != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result.Action)
+			}
+		})
+	}
+}
+
+func TestInvalidToolNamePattern(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policies.yaml")
+	content := `
+policies:
+  - tool_name: "/db_(/"
+    type: REJECT
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadSet(path, "", false, nil); err == nil || !strings.Contains(err.Error(), "invalid tool_name regex") {
+		t.Errorf("Expected invalid regex error, got %v", err)
+	}
+}
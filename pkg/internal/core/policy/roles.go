@@ -0,0 +1,164 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// Roles form a hierarchy: a role holds every role it inherits from,
+// transitively, along with their permission grants. Conditions query it with
+// has_role and has_permission, and user.permissions includes the grants of
+// the caller's role.
+//
+// Example YAML:
+//
+//	roles:
+//	  user:
+//	    permissions: [email_send]
+//	  manager:
+//	    inherits: [user]
+//	    permissions: [financial_access]
+//	  admin:
+//	    inherits: [manager]
+//	    permissions: [user_management]
+
+// Role defines what a role inherits and grants.
+type Role struct {
+	Inherits    []string `yaml:"inherits,omitempty"`    // Roles this role includes
+	Permissions []string `yaml:"permissions,omitempty"` // Permissions granted directly
+}
+
+// roleSet is a role's transitive closure.
+type roleSet struct {
+	roles       map[string]bool
+	permissions []string // sorted, deduplicated
+}
+
+// Role registry: definitions as loaded and their closures
+var (
+	roles       = map[string]Role{}
+	roleClosure = map[string]*roleSet{}
+)
+
+// SetRoles replaces the role hierarchy. It fails, leaving the current
+// hierarchy in place, if a role inherits from an undefined role or the
+// inheritance graph has a cycle.
+//
+// Example:
+//
+//	err := policy.SetRoles(map[string]Role{"admin": {Inherits: []string{"user"}}, "user": {}})
+func SetRoles(defs map[string]Role) error {
+	closure, err := compileRoles(defs)
+	if err != nil {
+		return err
+	}
+	if defs == nil {
+		defs = map[string]Role{}
+	}
+	roles = defs
+	roleClosure = closure
+	return nil
+}
+
+// GetRoles returns the loaded role definitions.
+func GetRoles() map[string]Role {
+	return roles
+}
+
+func compileRoles(defs map[string]Role) (map[string]*roleSet, error) {
+	closure := make(map[string]*roleSet, len(defs))
+	var visit func(name string, stack []string) (*roleSet, error)
+	visit = func(name string, stack []string) (*roleSet, error) {
+		if set, ok := closure[name]; ok {
+			return set, nil
+		}
+		for _, s := range stack {
+			if s == name {
+				return nil, fmt.Errorf("role inheritance cycle: %s", strings.Join(append(stack, name), " -> "))
+			}
+		}
+		def, ok := defs[name]
+		if !ok {
+			return nil, fmt.Errorf("role %s inherits from undefined role %s", stack[len(stack)-1], name)
+		}
+		set := &roleSet{roles: map[string]bool{name: true}}
+		perms := map[string]bool{}
+		for _, p := range def.Permissions {
+			perms[p] = true
+		}
+		for _, parent := range def.Inherits {
+			inherited, err := visit(parent, append(stack, name))
+			if err != nil {
+				return nil, err
+			}
+			for r := range inherited.roles {
+				set.roles[r] = true
+			}
+			for _, p := range inherited.permissions {
+				perms[p] = true
+			}
+		}
+		for p := range perms {
+			set.permissions = append(set.permissions, p)
+		}
+		sort.Strings(set.permissions)
+		closure[name] = set
+		return set, nil
+	}
+	for name := range defs {
+		if _, err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return closure, nil
+}
+
+// HasRole reports whether userRole is role or inherits from it. Without a
+// role hierarchy, roles only match themselves.
+//
+// Example:
+//
+//	policy.HasRole("admin", "manager") // true when admin inherits manager
+func HasRole(userRole, role string) bool {
+	return Default().HasRole(userRole, role)
+}
+
+// RolePermissions returns the permissions role grants, including inherited
+// ones, in sorted order.
+//
+// Example:
+//
+//	perms := policy.RolePermissions("manager")
+func RolePermissions(role string) []string {
+	return Default().RolePermissions(role)
+}
+
+// EffectivePermissions merges the caller-supplied permissions with the grants
+// of the caller's role. These are the permissions conditions see in
+// user.permissions. The result may share memory with cc and the set, so
+// callers must not modify it.
+func (s *Set) EffectivePermissions(cc model.CallContext) []string {
+	granted := s.RolePermissions(cc.UserRole)
+	if len(granted) == 0 {
+		return cc.UserPermissions
+	}
+	if len(cc.UserPermissions) == 0 {
+		return granted
+	}
+	return append(append([]string(nil), cc.UserPermissions...), granted...)
+}
+
+// hasPermission reports whether perm is among the effective permissions.
+func (s *Set) hasPermission(cc model.CallContext, perm string) bool {
+	for _, p := range cc.UserPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	granted := s.RolePermissions(cc.UserRole)
+	i := sort.SearchStrings(granted, perm)
+	return i < len(granted) && granted[i] == perm
+}
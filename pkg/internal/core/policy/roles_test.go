@@ -0,0 +1,68 @@
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestRoleHierarchy(t *testing.T) {
+	err := SetRoles(map[string]Role{
+		"user":      {Permissions: []string{"email_send"}},
+		"developer": {Inherits: []string{"user"}, Permissions: []string{"file_operations"}},
+		"manager":   {Inherits: []string{"user"}, Permissions: []string{"financial_access"}},
+		"admin":     {Inherits: []string{"manager", "developer"}, Permissions: []string{"user_management"}},
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	defer SetRoles(nil)
+
+	resetPolicies()
+	RegisterPolicy(Policy{ToolName: "quote", Type: PolicyReject, Condition: "!has_role('manager')", Priority: 10})
+	RegisterPolicy(Policy{ToolName: "send_email", Type: PolicyReject, Condition: "!has_permission('email_send')", Priority: 10})
+	RegisterPolicy(Policy{ToolName: "delete_file", Type: PolicyReject, Condition: "!('file_operations' in user.permissions)", Priority: 10})
+
+	tests := []struct {
+		name     string
+		tool     string
+		context  model.CallContext
+		expected PolicyType
+	}{
+		{"Admin inherits manager", "quote", model.CallContext{UserRole: "admin"}, PolicyAllow},
+		{"Developer is not a manager", "quote", model.CallContext{UserRole: "developer"}, PolicyReject},
+		{"Inherited grant", "send_email", model.CallContext{UserRole: "admin"}, PolicyAllow},
+		{"Unknown role has no grants", "send_email", model.CallContext{UserRole: "guest"}, PolicyReject},
+		{"Explicit grant", "send_email", model.CallContext{UserRole: "guest", UserPermissions: []string{"email_send"}}, PolicyAllow},
+		{"Grants appear in user.permissions", "delete_file", model.CallContext{UserRole: "admin"}, PolicyAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluatePolicy(model.ToolCall{Name: tt.tool, Context: tt.context})
+			if result.Action != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result.Action)
+			}
+		})
+	}
+}
+
+func TestRoleErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		defs map[string]Role
+		want string
+	}{
+		{"Cycle", map[string]Role{"a": {Inherits: []string{"b"}}, "b": {Inherits: []string{"a"}}}, "cycle"},
+		{"Undefined parent", map[string]Role{"a": {Inherits: []string{"ghost"}}}, "undefined role ghost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetRoles(tt.defs); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
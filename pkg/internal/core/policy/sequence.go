@@ -0,0 +1,263 @@
+package policy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"gopkg.in/yaml.v3"
+)
+
+// Sequence rules constrain the order of calls within a session. They are
+// evaluated against the session history before the regular policies; a
+// violated rule rejects the call, a satisfied rule lets evaluation continue.
+//
+// Example YAML:
+//
+//	sequences:
+//	  - id: email-after-docgen
+//	    tool_name: send_email
+//	    requires:
+//	      - tool: document_gen
+//	        within: 1h
+//	    reason: "Generate the document before emailing it"
+//	  - tool_name: user_management
+//	    condition: "params.action == 'delete'"
+//	    forbids_followups:
+//	      - tool: file_operations
+//	        where: "params.operation == 'delete'"
+//	        within: 5m
+
+// Unlike policies, sequence rules and their steps name a single tool, or
+// "*" for any tool. Globs, regular expressions, lists and tags fail the load
+// rather than never matching.
+
+// SequenceStep matches calls in the session history.
+type SequenceStep struct {
+	Tool   string        `yaml:"tool"`             // Tool name of the earlier (or later) call
+	Where  string        `yaml:"where,omitempty"`  // Condition over that call's params
+	Within time.Duration `yaml:"within,omitempty"` // Only calls within this window count; zero means any time in the session
+}
+
+// SequenceRule constrains when ToolName may be called relative to other calls
+// in the same session.
+type SequenceRule struct {
+	// ID, when set, is reported as the PolicyID of the rule's rejections,
+	// including those of its follow-up rules. Without it the PolicyID is
+	// "sequence:<tool>:<n>", n being the rule's position among the merged
+	// rules for the called tool, which shifts as files are added.
+	ID        string `yaml:"id,omitempty"`
+	ToolName  string `yaml:"tool_name"`
+	Condition string `yaml:"condition,omitempty"` // Rule applies only when this matches the current call
+	Reason    string `yaml:"reason,omitempty"`
+	// Requires lists calls that must all appear earlier in the session.
+	Requires []SequenceStep `yaml:"requires,omitempty"`
+	// Forbids lists earlier calls that block this one.
+	Forbids []SequenceStep `yaml:"forbids,omitempty"`
+	// ForbidsFollowups lists later calls that this one blocks. They are
+	// registered as Forbids rules on the follow-up tools.
+	ForbidsFollowups []SequenceStep `yaml:"forbids_followups,omitempty"`
+}
+
+// UnmarshalYAML rejects the tool lists and tags that policies accept, which
+// sequence rules do not support.
+func (r *SequenceRule) UnmarshalYAML(value *yaml.Node) error {
+	type plain SequenceRule
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			k, v := value.Content[i], value.Content[i+1]
+			switch {
+			case k.Value == "tool_name" && v.Kind == yaml.SequenceNode:
+				return fmt.Errorf("line %d: sequence rules take a single tool_name, not a list", k.Line)
+			case k.Value == "tags":
+				return fmt.Errorf("line %d: sequence rules do not support tags", k.Line)
+			}
+		}
+	}
+	return value.Decode((*plain)(r))
+}
+
+// In-memory sequence rule registry, keyed by the tool being called
+var sequences = map[string][]SequenceRule{}
+
+// RegisterSequence adds a sequence rule to the registry. Rules with
+// ForbidsFollowups are rewritten into Forbids rules on each follow-up tool.
+//
+// Example:
+//
+//	policy.RegisterSequence(SequenceRule{ToolName: "transfer", Requires: []SequenceStep{{Tool: "quote"}}})
+func RegisterSequence(r SequenceRule) {
+	Default().RegisterSequence(r)
+}
+
+// RegisterSequence adds a sequence rule to the set.
+func (s *Set) RegisterSequence(r SequenceRule) {
+	for _, f := range r.ForbidsFollowups {
+		reason := r.Reason
+		if reason == "" {
+			reason = fmt.Sprintf("%s is not allowed after %s", f.Tool, r.ToolName)
+		}
+		followup := SequenceRule{
+			ID:        r.ID,
+			ToolName:  f.Tool,
+			Condition: f.Where,
+			Reason:    reason,
+			Forbids:   []SequenceStep{{Tool: r.ToolName, Where: r.Condition, Within: f.Within}},
+		}
+		s.sequences[followup.ToolName] = append(s.sequences[followup.ToolName], followup)
+	}
+	if len(r.Requires) == 0 && len(r.Forbids) == 0 {
+		return
+	}
+	r.ForbidsFollowups = nil
+	s.sequences[r.ToolName] = append(s.sequences[r.ToolName], r)
+}
+
+// GetSequences returns the sequence rules that apply to a tool name
+// (including wildcards).
+func GetSequences(toolName string) []SequenceRule {
+	return Default().Sequences(toolName)
+}
+
+// evaluateSequences checks the sequence rules for tc against its session
+// history. It returns a REJECT result and true for the first violated rule.
+func evaluateSequences(ctx context.Context, tc model.ToolCall, env *Env, opts EvalOptions) (PolicyResult, bool) {
+	for i, rule := range opts.set().Sequences(tc.Name) {
+		if err := ctx.Err(); err != nil {
+			return timeoutResult(err), true
+		}
+		id := rule.ID
+		if id == "" {
+			id = fmt.Sprintf("sequence:%s:%d", rule.ToolName, i)
+		}
+		if rule.Condition != "" {
+			match, err := evaluateCondition(ctx, rule.Condition, env, opts)
+			if errors.Is(err, ErrBudgetExceeded) {
+				return timeoutResult(err), true
+			}
+			if err != nil {
+				if opts.Observer != nil {
+					opts.Observer.ConditionError(Policy{ToolName: rule.ToolName, Condition: rule.Condition}, err)
+				}
+				if ctx.Err() != nil {
+					return timeoutResult(ctx.Err()), true
+				}
+				opts.Logger.Warn("Error evaluating condition for sequence rule %s: %v", id, err)
+				// Rules fail closed and are enforced as if the condition
+				// matched
+				match = true
+			}
+			if !match {
+				continue
+			}
+		}
+		violation, err := rule.violation(ctx, tc, opts)
+		if err != nil {
+			return timeoutResult(err), true
+		}
+		if violation != "" {
+			reason := rule.Reason
+			if reason == "" {
+				reason = violation
+			}
+			return PolicyResult{
+				Action:   PolicyReject,
+				Reason:   reason,
+				Matched:  true,
+				PolicyID: id,
+			}, true
+		}
+	}
+	return PolicyResult{}, false
+}
+
+// violation returns a description of how tc breaks the rule, or "". It
+// fails if ctx is done before the history has been checked.
+func (r SequenceRule) violation(ctx context.Context, tc model.ToolCall, opts EvalOptions) (string, error) {
+	var missing []string
+	for _, step := range r.Requires {
+		seen, err := step.seen(ctx, tc, opts, false)
+		if err != nil {
+			return "", err
+		}
+		if !seen {
+			missing = append(missing, step.describe())
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Sprintf("%s requires a prior %s in this session", tc.Name, strings.Join(missing, " and ")), nil
+	}
+	for _, step := range r.Forbids {
+		seen, err := step.seen(ctx, tc, opts, true)
+		if err != nil {
+			return "", err
+		}
+		if seen {
+			return fmt.Sprintf("%s is not allowed after %s", tc.Name, step.describe()), nil
+		}
+	}
+	return "", nil
+}
+
+// seen reports whether a call matching the step exists in tc's history. A
+// call whose Where condition fails to evaluate matches only if forbidden is
+// set, so errors never satisfy a required step nor clear a forbidden one. It
+// fails if ctx is done or a Where condition exceeds its budget.
+func (s SequenceStep) seen(ctx context.Context, tc model.ToolCall, opts EvalOptions, forbidden bool) (bool, error) {
+	for i := len(tc.Context.History) - 1; i >= 0; i-- {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		call := tc.Context.History[i]
+		if s.Tool != "*" && call.ToolName != s.Tool {
+			continue
+		}
+		if s.Within > 0 && !tc.Timestamp.IsZero() && tc.Timestamp.Sub(call.Timestamp) > s.Within {
+			continue
+		}
+		if s.Where != "" {
+			previous := model.ToolCall{
+				ID:         call.ToolCallID,
+				Name:       call.ToolName,
+				Parameters: call.Parameters,
+				Context:    tc.Context,
+				Timestamp:  call.Timestamp,
+			}
+			env := newEnv(ctx, previous, opts)
+			match, err := evaluateCondition(ctx, s.Where, env, opts)
+			releaseEnv(env)
+			if errors.Is(err, ErrBudgetExceeded) {
+				return false, err
+			}
+			if err != nil {
+				if ctx.Err() != nil {
+					return false, ctx.Err()
+				}
+				opts.Logger.Warn("Error evaluating where for sequence step %s: %v", s.describe(), err)
+				if forbidden {
+					return true, nil
+				}
+				continue
+			}
+			if !match {
+				continue
+			}
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+func (s SequenceStep) describe() string {
+	d := s.Tool
+	if s.Where != "" {
+		d += fmt.Sprintf(" (%s)", s.Where)
+	}
+	if s.Within > 0 {
+		d += fmt.Sprintf(" within %s", s.Within)
+	}
+	return d
+}
@@ -0,0 +1,124 @@
+package policy
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestSequenceRules(t *testing.T) {
+	// Clear policies and add test rules
+	resetPolicies()
+	sequences = make(map[string][]SequenceRule)
+
+	RegisterSequence(SequenceRule{
+		ToolName: "send_email",
+		Requires: []SequenceStep{{Tool: "document_gen"}},
+	})
+	RegisterSequence(SequenceRule{
+		ID:        "cooling-off",
+		ToolName:  "user_management",
+		Condition: "params.action == 'delete'",
+		ForbidsFollowups: []SequenceStep{{
+			Tool:   "file_operations",
+			Where:  "params.operation == 'delete'",
+			Within: 5 * time.Minute,
+		}},
+		Reason: "Cooling-off period after deleting a user",
+	})
+	RegisterSequence(SequenceRule{
+		ToolName: "transfer",
+		Requires: []SequenceStep{{Tool: "quote", Within: time.Hour}},
+	})
+	RegisterSequence(SequenceRule{
+		ToolName:  "payout",
+		Condition: "params.amount > 100",
+		Requires:  []SequenceStep{{Tool: "quote", Where: "params.total > 0"}},
+	})
+	RegisterSequence(SequenceRule{
+		ID:       "no-refund-after-chargeback",
+		ToolName: "refund",
+		Forbids:  []SequenceStep{{Tool: "chargeback", Where: "params.amount > 0"}},
+	})
+	RegisterSequence(SequenceRule{
+		ToolName:         "chargeback",
+		ForbidsFollowups: []SequenceStep{{Tool: "payout", Where: "params.total > 0"}},
+	})
+
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	userDelete := model.SessionCall{ToolName: "user_management", Parameters: map[string]interface{}{"action": "delete"}, Timestamp: now.Add(-2 * time.Minute)}
+	userList := model.SessionCall{ToolName: "user_management", Parameters: map[string]interface{}{"action": "list"}, Timestamp: now.Add(-2 * time.Minute)}
+
+	tests := []struct {
+		name     string
+		tool     string
+		params   map[string]interface{}
+		history  []model.SessionCall
+		expected PolicyType
+	}{
+		{"Missing predecessor", "send_email", nil, nil, PolicyReject},
+		{"Predecessor present", "send_email", nil, []model.SessionCall{{ToolName: "document_gen", Timestamp: now}}, PolicyAllow},
+		{"Follow-up inside window", "file_operations", map[string]interface{}{"operation": "delete"}, []model.SessionCall{userDelete}, PolicyReject},
+		{"Follow-up with other operation", "file_operations", map[string]interface{}{"operation": "read"}, []model.SessionCall{userDelete}, PolicyAllow},
+		{"Predecessor with other action", "file_operations", map[string]interface{}{"operation": "delete"}, []model.SessionCall{userList}, PolicyAllow},
+		{"Follow-up after window", "file_operations", map[string]interface{}{"operation": "delete"}, []model.SessionCall{{ToolName: "user_management", Parameters: map[string]interface{}{"action": "delete"}, Timestamp: now.Add(-10 * time.Minute)}}, PolicyAllow},
+		{"Predecessor too old", "transfer", nil, []model.SessionCall{{ToolName: "quote", Timestamp: now.Add(-2 * time.Hour)}}, PolicyReject},
+		{"Predecessor recent", "transfer", nil, []model.SessionCall{{ToolName: "quote", Timestamp: now.Add(-time.Minute)}}, PolicyAllow},
+		{"Erroring condition enforces requires", "payout", map[string]interface{}{"amount": "lots"}, nil, PolicyReject},
+		{"Condition not matched", "payout", map[string]interface{}{"amount": 50}, nil, PolicyAllow},
+		{"Erroring where does not satisfy", "payout", map[string]interface{}{"amount": 500}, []model.SessionCall{{ToolName: "quote", Parameters: map[string]interface{}{"total": "x"}, Timestamp: now}}, PolicyReject},
+		{"Erroring where matches forbids", "refund", nil, []model.SessionCall{{ToolName: "chargeback", Parameters: map[string]interface{}{"amount": "x"}, Timestamp: now}}, PolicyReject},
+		{"Forbidden where not matched", "refund", nil, []model.SessionCall{{ToolName: "chargeback", Parameters: map[string]interface{}{"amount": 0}, Timestamp: now}}, PolicyAllow},
+		{"Erroring follow-up where enforces forbids", "payout", map[string]interface{}{"amount": 5, "total": "x"}, []model.SessionCall{{ToolName: "chargeback", Parameters: map[string]interface{}{"amount": 5}, Timestamp: now}}, PolicyReject},
+		{"Where satisfied", "payout", map[string]interface{}{"amount": 500}, []model.SessionCall{{ToolName: "quote", Parameters: map[string]interface{}{"total": 5}, Timestamp: now}}, PolicyAllow},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluatePolicy(model.ToolCall{
+				Name:       tt.tool,
+				Parameters: tt.params,
+				Context:    model.CallContext{History: tt.history},
+				Timestamp:  now,
+			})
+			if result.Action != tt.expected {
+				t.Errorf("Expected %v, got %v (%s)", tt.expected, result.Action, result.Reason)
+			}
+		})
+	}
+
+	// Rejections report the rule's id, or its position when it has none.
+	ids := map[string]model.ToolCall{
+		"cooling-off":                {Name: "file_operations", Parameters: map[string]interface{}{"operation": "delete"}, Context: model.CallContext{History: []model.SessionCall{userDelete}}, Timestamp: now},
+		"no-refund-after-chargeback": {Name: "refund", Context: model.CallContext{History: []model.SessionCall{{ToolName: "chargeback", Parameters: map[string]interface{}{"amount": 5}, Timestamp: now}}}, Timestamp: now},
+		"sequence:send_email:0":      {Name: "send_email", Timestamp: now},
+	}
+	for id, tc := range ids {
+		if result := EvaluatePolicy(tc); result.PolicyID != id {
+			t.Errorf("Expected PolicyID %q, got %q (%s)", id, result.PolicyID, result.Reason)
+		}
+	}
+}
+
+func TestSequenceRulesRejectPatterns(t *testing.T) {
+	tests := []struct {
+		name, rule, want string
+	}{
+		{"Glob tool", "tool_name: send_*\n    requires: [{tool: document_gen}]", "not a pattern"},
+		{"Regex step", "tool_name: send_email\n    requires: [{tool: /^doc/}]", "not a pattern"},
+		{"Tool list", "tool_name: [send_email, send_sms]\n    requires: [{tool: document_gen}]", "not a list"},
+		{"Tags", "tags: [outbound]\n    requires: [{tool: document_gen}]", "do not support tags"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeFiles(t, map[string]string{"policies.yaml": "sequences:\n  - " + tt.rule + "\n"})
+			_, err := LoadSet(filepath.Join(dir, "policies.yaml"), "", false, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected an error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
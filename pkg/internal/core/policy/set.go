@@ -0,0 +1,421 @@
+package policy
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/logging"
+	"github.com/SafellmHub/hguard-go/pkg/internal/quota"
+	"github.com/expr-lang/expr/vm"
+)
+
+// Set is a bundle of policies, sequence rules and roles with its own compiled
+// expression cache. The package-level functions operate on the default set
+// (see Default); tenants get their own sets layered over it.
+//
+// Example:
+//
+//	set, err := policy.LoadSet("tenants/acme/policies.yaml", "", true, nil)
+//	result := policy.EvaluatePolicyWithOptions(ctx, tc, policy.EvalOptions{Set: set})
+type Set struct {
+	policies    map[string][]Policy
+	patterns    *patternList
+	combining   *Combining
+	sequences   map[string][]SequenceRule
+	roles       map[string]Role
+	roleClosure map[string]*roleSet
+	cache       *programCache
+	matches     *matchCache
+	// version counts changes to the set's policies. The default set's is
+	// shared by every view Default returns.
+	version *atomic.Uint64
+	// inherit layers the set over the default set: its policies and
+	// sequence rules are evaluated together with the default ones.
+	inherit bool
+}
+
+// NewSet creates an empty set. If inherit is true, the default set's
+// policies, sequence rules and roles also apply.
+func NewSet(inherit bool) *Set {
+	return &Set{
+		policies:    map[string][]Policy{},
+		patterns:    &patternList{},
+		combining:   &Combining{},
+		sequences:   map[string][]SequenceRule{},
+		roles:       map[string]Role{},
+		roleClosure: map[string]*roleSet{},
+		cache:       newProgramCache(),
+		matches:     newMatchCache(),
+		version:     &atomic.Uint64{},
+		inherit:     inherit,
+	}
+}
+
+// Default returns the default set, backed by the package-level registry.
+func Default() *Set {
+	return &Set{
+		policies:    policies,
+		patterns:    patterns,
+		combining:   combining,
+		sequences:   sequences,
+		roles:       roles,
+		roleClosure: roleClosure,
+		cache:       exprCache,
+		matches:     defaultMatches,
+		version:     &defaultVersion,
+	}
+}
+
+// set returns the set to evaluate against.
+func (o EvalOptions) set() *Set {
+	if o.Set != nil {
+		return o.Set
+	}
+	return Default()
+}
+
+// LoadSet reads a policy file (with includes, definitions and the overlay
+// for env) into a new set. If inherit is true, the set is layered over the
+// default set and its roles may inherit from the default set's roles.
+// Conditions are checked against ext's functions and providers as well as
+// the package-level ones; ext may be nil.
+//
+// Example:
+//
+//	set, err := policy.LoadSet("tenants/acme/policies.yaml", "prod", true, nil)
+func LoadSet(path, env string, inherit bool, ext *Extensions) (*Set, error) {
+	data, err := composePolicies(path, env)
+	if err != nil {
+		return nil, err
+	}
+	for i, p := range data.Policies {
+		if err := validatePolicy(p, ext); err != nil {
+			return nil, fmt.Errorf("policy %d (%s): %w", i, p.Selector(), err)
+		}
+	}
+	for i, r := range data.Sequences {
+		if err := validateSequence(r, ext); err != nil {
+			return nil, fmt.Errorf("sequence %d (%s): %w", i, r.ToolName, err)
+		}
+	}
+
+	if err := data.Combining.validate(); err != nil {
+		return nil, err
+	}
+
+	set := NewSet(inherit)
+	*set.combining = data.Combining
+	roleDefs := data.Roles
+	if inherit {
+		roleDefs = make(map[string]Role, len(roles)+len(data.Roles))
+		for k, v := range roles {
+			roleDefs[k] = v
+		}
+		for k, v := range data.Roles {
+			roleDefs[k] = v
+		}
+	}
+	if set.roleClosure, err = compileRoles(roleDefs); err != nil {
+		return nil, err
+	}
+	if data.Roles != nil {
+		set.roles = data.Roles
+	}
+	for _, p := range data.Policies {
+		set.Register(p)
+	}
+	for _, r := range data.Sequences {
+		set.RegisterSequence(r)
+	}
+	return set, nil
+}
+
+// validatePolicy type-checks the condition and checks type-specific fields.
+func validatePolicy(p Policy, ext *Extensions) error {
+	if p.Condition != "" {
+		if err := ext.CheckExpression(p.Condition); err != nil {
+			return err
+		}
+	}
+	if p.isPattern() {
+		if _, err := compileMatcher(p); err != nil {
+			return err
+		}
+	}
+	if p.Type != PolicyBudget {
+		return nil
+	}
+	if p.Limit <= 0 {
+		return fmt.Errorf("BUDGET requires a positive limit")
+	}
+	if p.Period != "" && !quota.ValidPeriod(p.Period) {
+		return fmt.Errorf("unknown budget period %q", p.Period)
+	}
+	if p.Scope != "" && p.Scope != quota.ScopeUser && p.Scope != quota.ScopeTenant {
+		return fmt.Errorf("unknown budget scope %q", p.Scope)
+	}
+	return nil
+}
+
+// validateSequence type-checks a sequence rule's conditions and rejects
+// tool patterns, which sequence rules do not support.
+func validateSequence(r SequenceRule, ext *Extensions) error {
+	conditions := []string{r.Condition}
+	tools := []string{r.ToolName}
+	for _, steps := range [][]SequenceStep{r.Requires, r.Forbids, r.ForbidsFollowups} {
+		for _, step := range steps {
+			conditions = append(conditions, step.Where)
+			tools = append(tools, step.Tool)
+		}
+	}
+	for _, t := range tools {
+		if isToolPattern(t) {
+			return fmt.Errorf("tool %q: sequence rules match an exact tool name or \"*\", not a pattern", t)
+		}
+	}
+	for _, c := range conditions {
+		if c == "" {
+			continue
+		}
+		if err := ext.CheckExpression(c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Register adds a policy to the set. Policies with an invalid tool_name
+// pattern are logged and skipped; LoadSet rejects them instead.
+func (s *Set) Register(p Policy) {
+	defer s.version.Add(1)
+	if p.isPattern() {
+		m, err := compileMatcher(p)
+		if err != nil {
+			logging.Warn("Skipping policy %s: %v", p.id(), err)
+			return
+		}
+		s.patterns.items = append(s.patterns.items, patternPolicy{policy: p, matcher: m})
+		return
+	}
+	list := append(s.policies[p.ToolName], p)
+	// Sort policies by priority (higher first)
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Priority > list[j].Priority
+	})
+	s.policies[p.ToolName] = list
+}
+
+// AllPolicies returns the policies that apply to a tool name (including
+// wildcards and, for inheriting sets, the default set's), highest priority
+// first. On equal priority the set's own policies come first.
+func (s *Set) AllPolicies(toolName string) []Policy {
+	return s.Matching(toolName, nil)
+}
+
+// Matching is AllPolicies for a tool carrying the given schema tags. On
+// equal priority, exact-name policies come before patterns and tags, which
+// come before "*".
+func (s *Set) Matching(toolName string, tags []string) []Policy {
+	return append([]Policy(nil), s.applicable(toolName, tags)...)
+}
+
+// applicable is Matching without the copy; callers must not modify the
+// list.
+func (s *Set) applicable(toolName string, tags []string) []Policy {
+	return s.index(toolName, tags, nil).policies
+}
+
+// index returns the indexed policy list of a tool, for evaluations with ext.
+// Lists are merged, sorted and indexed once per tool, tags and the providers
+// of ext, and rebuilt after any policy change.
+func (s *Set) index(toolName string, tags []string, ext *Extensions) *policyIndex {
+	key := toolName
+	if len(tags) > 0 {
+		key += "\x00" + strings.Join(tags, "\x00")
+	}
+	key += ext.indexKey()
+	st := s.stamp()
+	if idx, ok := s.matches.get(key, st); ok {
+		return idx
+	}
+	idx := newPolicyIndex(s.merge(toolName, tags), ext.attributePaths())
+	s.matches.put(key, st, idx)
+	return idx
+}
+
+// stamp returns the versions the set's merged lists depend on.
+func (s *Set) stamp() matchStamp {
+	st := matchStamp{own: s.version.Load(), registrations: registrations.Load()}
+	if s.inherit {
+		st.base = defaultVersion.Load()
+	}
+	return st
+}
+
+// merge collects and orders the policies that apply to a tool.
+func (s *Set) merge(toolName string, tags []string) []Policy {
+	var all []Policy
+	all = append(all, s.policies[toolName]...)
+	for _, pp := range s.patterns.items {
+		if pp.matcher.matches(toolName, tags) {
+			all = append(all, pp.policy)
+		}
+	}
+	if toolName != "*" {
+		all = append(all, s.policies["*"]...)
+	}
+	if s.inherit {
+		all = append(all, Default().applicable(toolName, tags)...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Priority > all[j].Priority
+	})
+	return all
+}
+
+// Sequences returns the sequence rules that apply to a tool name.
+func (s *Set) Sequences(toolName string) []SequenceRule {
+	var rules []SequenceRule
+	rules = append(rules, s.sequences[toolName]...)
+	if toolName != "*" {
+		rules = append(rules, s.sequences["*"]...)
+	}
+	if s.inherit {
+		rules = append(rules, Default().Sequences(toolName)...)
+	}
+	return rules
+}
+
+// HasRole reports whether userRole is role or inherits from it.
+func (s *Set) HasRole(userRole, role string) bool {
+	if userRole == role {
+		return true
+	}
+	if set, ok := s.roleClosure[userRole]; ok {
+		return set.roles[role]
+	}
+	return s.inherit && Default().HasRole(userRole, role)
+}
+
+// RolePermissions returns the permissions role grants, including inherited
+// ones, in sorted order.
+func (s *Set) RolePermissions(role string) []string {
+	if set, ok := s.roleClosure[role]; ok {
+		return set.permissions
+	}
+	if s.inherit {
+		return Default().RolePermissions(role)
+	}
+	return nil
+}
+
+// ClearCache drops the set's compiled expressions.
+func (s *Set) ClearCache() {
+	s.cache.clear()
+}
+
+// defaultVersion counts policy changes in the default set.
+var defaultVersion atomic.Uint64
+
+// matchStamp identifies what a merged policy list was built from: the set's
+// own policies, the default set's for sets layered over it, and the
+// package-level attribute providers, which change what can be indexed. A
+// change to one set leaves the lists of unrelated sets intact.
+type matchStamp struct {
+	own, base, registrations uint64
+}
+
+// Merged policy lists of the default set
+var defaultMatches = newMatchCache()
+
+// maxMatchLists bounds the merged lists kept per set, since tool names come
+// from model output.
+const maxMatchLists = 4096
+
+// matchCache holds a set's indexed policy lists keyed by tool and tags.
+type matchCache struct {
+	mu    sync.RWMutex
+	stamp matchStamp
+	lists map[string]*policyIndex
+}
+
+func newMatchCache() *matchCache {
+	return &matchCache{lists: map[string]*policyIndex{}}
+}
+
+// get returns the list stored for key, if it was built at st.
+func (c *matchCache) get(key string, st matchStamp) (*policyIndex, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.stamp != st {
+		return nil, false
+	}
+	idx, ok := c.lists[key]
+	return idx, ok
+}
+
+// put stores a list built at st, dropping lists built at other stamps.
+func (c *matchCache) put(key string, st matchStamp, idx *policyIndex) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stamp != st {
+		c.lists = map[string]*policyIndex{}
+		c.stamp = st
+	}
+	if len(c.lists) < maxMatchLists {
+		c.lists[key] = idx
+	}
+}
+
+// registrations counts package-level condition function and attribute
+// provider registrations, which change how conditions compile. Compiled
+// programs record the generation they were compiled at (see
+// Extensions.Generation), so a registration refreshes the program cache of
+// every set.
+var registrations atomic.Uint64
+
+// programCache holds compiled expressions keyed by source.
+type programCache struct {
+	mu         sync.RWMutex
+	generation uint64
+	programs   map[string]*vm.Program
+}
+
+func newProgramCache() *programCache {
+	return &programCache{programs: map[string]*vm.Program{}}
+}
+
+// get returns the program for source compiled at generation g.
+func (c *programCache) get(source string, g uint64) (*vm.Program, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.generation != g {
+		return nil, false
+	}
+	p, ok := c.programs[source]
+	return p, ok
+}
+
+// put stores a program compiled at generation g. Generations only grow, so
+// programs from an older one are dropped.
+func (c *programCache) put(source string, g uint64, p *vm.Program) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if g < c.generation {
+		return
+	}
+	if c.generation != g {
+		c.programs = map[string]*vm.Program{}
+		c.generation = g
+	}
+	c.programs[source] = p
+}
+
+func (c *programCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.programs = map[string]*vm.Program{}
+}
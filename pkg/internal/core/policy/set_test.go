@@ -0,0 +1,65 @@
+package policy
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestTenantSets(t *testing.T) {
+	resetPolicies()
+	sequences = make(map[string][]SequenceRule)
+	defer resetPolicies()
+	RegisterPolicy(Policy{ToolName: "delete_file", Type: PolicyReject, Priority: 10})
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policies.yaml")
+	content := `
+policies:
+  - tool_name: send_email
+    type: REJECT
+    condition: "params.to endsWith '@competitor.com'"
+    priority: 10
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	acme, err := LoadSet(path, "", true, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	isolated, err := LoadSet(path, "", false, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	email := model.ToolCall{Name: "send_email", Parameters: map[string]interface{}{"to": "bob@competitor.com"}}
+	deletion := model.ToolCall{Name: "delete_file"}
+	tests := []struct {
+		name     string
+		set      *Set
+		tc       model.ToolCall
+		expected PolicyType
+	}{
+		{"Tenant policy applies to tenant", acme, email, PolicyReject},
+		{"Tenant policy does not leak to default", nil, email, PolicyAllow},
+		{"Default policy is inherited", acme, deletion, PolicyReject},
+		{"Non-inheriting set ignores default", isolated, deletion, PolicyAllow},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := EvaluatePolicyWithOptions(context.Background(), tt.tc, EvalOptions{Set: tt.set})
+			if result.Action != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, result.Action)
+			}
+		})
+	}
+
+	acme.ClearCache()
+	if _, ok := acme.cache.get("params.to endsWith '@competitor.com'", registrations.Load()); ok {
+		t.Error("Expected tenant cache to be cleared")
+	}
+}
@@ -97,6 +97,16 @@ func ValidateToolCall(tc model.ToolCall) model.ValidationResult {
 			ExecutionAllowed: false,
 			PolicyAction:     string(policy.ActionRejected),
 		}
+	case policy.PolicyTimeout:
+		// A condition ran out of budget; this path has no fail-open option.
+		return model.ValidationResult{
+			ToolCallID:       tc.ID,
+			Status:           "timeout",
+			Confidence:       0.0,
+			Reason:           policyResult.Reason,
+			ExecutionAllowed: false,
+			PolicyAction:     string(policy.ActionRejected),
+		}
 	case policy.PolicyContextReject:
 		return model.ValidationResult{
 			ToolCallID:       tc.ID,
@@ -127,6 +137,15 @@ func ValidateToolCall(tc model.ToolCall) model.ValidationResult {
 				Timestamp:  tc.Timestamp,
 			},
 		}
+	case policy.PolicyRequireApproval:
+		return model.ValidationResult{
+			ToolCallID:       tc.ID,
+			Status:           "pending",
+			Confidence:       1.0,
+			Reason:           policyResult.Reason,
+			ExecutionAllowed: false,
+			PolicyAction:     string(policy.ActionPending),
+		}
 	case policy.PolicyLog:
 		return model.ValidationResult{
 			ToolCallID:       tc.ID,
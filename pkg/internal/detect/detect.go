@@ -0,0 +1,156 @@
+package detect
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Package detect scores strings for prompt-injection and exfiltration
+// patterns: SQL injection, embedded URLs, shell metacharacters and attempts to
+// override the model's instructions.
+//
+// Each detector is a set of weighted heuristics. A string's score is the
+// noisy-or of the weights of every heuristic it matches, so it lies in [0, 1]
+// and grows with the amount of evidence. Scores at or above DefaultThreshold
+// are treated as a detection.
+//
+// Example usage:
+//
+//	score, err := detect.Score(detect.SQLInjection, "1' OR '1'='1")
+//	if score >= detect.DefaultThreshold { /* reject */ }
+//
+// Built-in detector names.
+const (
+	SQLInjection        = "sql_injection"
+	URL                 = "url"
+	ShellMetachars      = "shell_metachars"
+	InstructionOverride = "instruction_override"
+)
+
+// DefaultThreshold is the score at which a detector fires.
+const DefaultThreshold = 0.5
+
+// heuristic is one weighted pattern.
+type heuristic struct {
+	pattern matcher
+	weight  float64
+}
+
+// matcher is a *regexp.Regexp or a matchFunc, for checks a regular
+// expression cannot express.
+type matcher interface {
+	MatchString(s string) bool
+}
+
+type matchFunc func(s string) bool
+
+func (f matchFunc) MatchString(s string) bool { return f(s) }
+
+// comparison finds "OR x = y" and "AND 'x'='y'" terms.
+var comparison = regexp.MustCompile(`(?i)\b(or|and)\s+('?)(\w+)'?\s*=\s*('?)(\w+)`)
+
+// tautology reports whether s compares a literal with itself, as in
+// "' OR 1=1" or "' OR 'a'='a". Ordinary filters such as
+// "name = 'bob' AND id = 5" compare different operands.
+func tautology(s string) bool {
+	for _, m := range comparison.FindAllStringSubmatch(s, -1) {
+		if strings.EqualFold(m[3], m[5]) && (m[2] != "" || m[4] != "" || isNumber(m[3])) {
+			return true
+		}
+	}
+	return false
+}
+
+func isNumber(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+var detectors = map[string][]heuristic{
+	SQLInjection: {
+		{matchFunc(tautology), 0.9}, // ' OR 1=1 / ' OR 'a'='a
+		{regexp.MustCompile(`(?i);\s*(drop|delete|truncate|alter|insert|update|create|exec|grant)\b`), 0.9},
+		{regexp.MustCompile(`(?i)\bunion\s+(all\s+)?select\b`), 0.7},
+		{regexp.MustCompile(`(?i)\b(sleep|benchmark|pg_sleep)\s*\(|\bwaitfor\s+delay\b`), 0.7},
+		{regexp.MustCompile(`(?i)\b(xp_cmdshell|information_schema|sqlite_master|pg_catalog)\b`), 0.6},
+		{regexp.MustCompile(`(?i)\bdrop\s+(table|database)\b`), 0.6},
+		{regexp.MustCompile(`'\s*(--|#)|/\*.*?\*/`), 0.4}, // quote followed by a comment, inline comments
+	},
+	URL: {
+		{regexp.MustCompile(`(?i)\b(https?|ftp|file|gopher|data|javascript):(//)?\S+`), 1.0},
+		{regexp.MustCompile(`(?i)\bwww\.[a-z0-9\-]+\.[a-z]{2,}\b`), 0.8},
+		{regexp.MustCompile(`(?i)\b[a-z0-9\-]+\.(com|net|org|io|ru|cn|xyz|top|ly|co)(/\S*)?\b`), 0.3}, // bare domains alone (e.g. in email addresses) do not fire
+	},
+	ShellMetachars: {
+		{regexp.MustCompile("`|\\$\\("), 0.9}, // command substitution
+		{regexp.MustCompile(`(;|&&|\|\|?)\s*(rm|curl|wget|sh|bash|nc|cat|chmod|python|perl)\b`), 0.8}, // chaining into a command
+		// Single separators are weak evidence: "apples; oranges" is prose.
+		{regexp.MustCompile(`&&|\|\|`), 0.4},
+		{regexp.MustCompile(`;`), 0.3},
+		{regexp.MustCompile(`\|`), 0.4},
+		{regexp.MustCompile(`[<>]`), 0.4},
+		{regexp.MustCompile(`\$\{?[A-Za-z_]`), 0.4},
+		{regexp.MustCompile(`[\r\n]`), 0.3},
+	},
+	InstructionOverride: {
+		{regexp.MustCompile(`(?i)\b(ignore|disregard|override|bypass)\s+(all\s+|any\s+)?(of\s+)?(the\s+|your\s+|my\s+)?(previous|prior|above|earlier|system|original)\s+(instructions?|prompts?|messages?|rules|directions)`), 0.95},
+		{regexp.MustCompile(`(?i)\bforget\s+(everything|all|your)\b.*\b(instructions?|rules|told)\b`), 0.8},
+		{regexp.MustCompile(`(?i)\b(reveal|print|show|repeat|output)\s+(me\s+)?(your|the)\s+(system\s+prompt|instructions|initial\s+prompt)`), 0.8},
+		{regexp.MustCompile(`(?i)<\|im_start\|>|\[/?INST\]|<\|system\|>|^\s*#{2,}\s*(system|instructions?)\b`), 0.7},
+		{regexp.MustCompile(`(?i)\bnew\s+instructions?\s*:`), 0.6},
+		{regexp.MustCompile(`(?i)\bdo\s+not\s+(tell|inform|alert)\s+the\s+user\b`), 0.6},
+		{regexp.MustCompile(`(?i)\byou\s+are\s+now\b`), 0.4},
+		{regexp.MustCompile(`(?i)\bsystem\s+prompt\b`), 0.3},
+	},
+}
+
+// Names returns the built-in detector names in sorted order.
+func Names() []string {
+	names := make([]string, 0, len(detectors))
+	for name := range detectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Known reports whether name is a built-in detector.
+func Known(name string) bool {
+	_, ok := detectors[name]
+	return ok
+}
+
+// Score returns the named detector's score for s in [0, 1].
+//
+// Example:
+//
+//	score, err := detect.Score(detect.URL, "see https://evil.example/x")
+func Score(name, s string) (float64, error) {
+	heuristics, ok := detectors[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown detector %q", name)
+	}
+	if s == "" {
+		return 0, nil
+	}
+	miss := 1.0
+	for _, h := range heuristics {
+		if h.pattern.MatchString(s) {
+			miss *= 1 - h.weight
+		}
+	}
+	return 1 - miss, nil
+}
+
+// Fires reports whether the named detector scores s at or above
+// DefaultThreshold. Unknown detectors never fire.
+func Fires(name, s string) bool {
+	score, err := Score(name, s)
+	return err == nil && score >= DefaultThreshold
+}
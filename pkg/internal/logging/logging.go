@@ -3,8 +3,10 @@ package logging
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"sync"
 )
 
 type LogEntry struct {
@@ -16,7 +18,86 @@ type LogEntry struct {
 
 var logger = log.New(os.Stdout, "", log.LstdFlags)
 
+var (
+	redactorMu sync.RWMutex
+	redactor   func(interface{}) interface{}
+)
+
+// SetRedactor installs a function applied to every message and field value
+// before it is written. Pass nil to disable redaction.
+func SetRedactor(fn func(interface{}) interface{}) {
+	redactorMu.Lock()
+	defer redactorMu.Unlock()
+	redactor = fn
+}
+
+// SetOutput sets the destination of every logger. The default is stdout.
+func SetOutput(w io.Writer) {
+	logger.SetOutput(w)
+}
+
+// defaultRedactor returns the function installed by SetRedactor.
+func defaultRedactor() func(interface{}) interface{} {
+	redactorMu.RLock()
+	defer redactorMu.RUnlock()
+	return redactor
+}
+
+// Logger writes entries like the package-level functions, but masks them
+// with its own redactor instead of the one installed by SetRedactor. A nil
+// *Logger logs through the package-level functions.
+type Logger struct {
+	redact func(interface{}) interface{}
+}
+
+// New returns a Logger that applies redact to every message and field value
+// before it is written.
+//
+// Example:
+//
+//	logger := logging.New(redactor.Value)
+//	logger.Warn("lookup failed for %s: %v", id, err)
+func New(redact func(interface{}) interface{}) *Logger {
+	return &Logger{redact: redact}
+}
+
+func (l *Logger) Info(msg string, args ...interface{}) {
+	l.log("info", format(msg, args...))
+}
+
+func (l *Logger) Warn(msg string, args ...interface{}) {
+	l.log("warn", format(msg, args...))
+}
+
+func (l *Logger) Error(msg string, args ...interface{}) {
+	l.log("error", format(msg, args...))
+}
+
+func (l *Logger) log(level, msg string) {
+	if l == nil {
+		logStructured(level, msg, "", nil)
+		return
+	}
+	write(l.redact, level, msg, "", nil)
+}
+
 func logStructured(level, msg, requestID string, fields map[string]interface{}) {
+	write(defaultRedactor(), level, msg, requestID, fields)
+}
+
+func write(fn func(interface{}) interface{}, level, msg, requestID string, fields map[string]interface{}) {
+	if fn != nil {
+		if s, ok := fn(msg).(string); ok {
+			msg = s
+		}
+		if fields != nil {
+			redacted := make(map[string]interface{}, len(fields))
+			for k, v := range fields {
+				redacted[k] = fn(v)
+			}
+			fields = redacted
+		}
+	}
 	entry := LogEntry{
 		Level:     level,
 		Message:   msg,
@@ -0,0 +1,257 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Package metrics provides a minimal in-process metrics registry that renders
+// the Prometheus text exposition format without depending on the Prometheus
+// client library.
+//
+// Example usage:
+//
+//	reg := metrics.NewRegistry()
+//	calls := reg.Counter("hguard_decisions_total", "Decisions made.", "tool", "status")
+//	calls.Inc("weather", "approved")
+//	err := reg.WriteText(os.Stdout)
+//
+// DefaultBuckets are latency buckets in seconds suited to in-process policy
+// evaluation (10µs to 1s).
+var DefaultBuckets = []float64{0.00001, 0.00005, 0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+const (
+	typeCounter   = "counter"
+	typeHistogram = "histogram"
+)
+
+// Registry holds metric families. It is safe for concurrent use.
+type Registry struct {
+	mu       sync.Mutex
+	families []*family
+	byName   map[string]*family
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{byName: map[string]*family{}}
+}
+
+type family struct {
+	mu      sync.Mutex
+	name    string
+	help    string
+	typ     string
+	labels  []string
+	buckets []float64
+	series  map[string]*series
+}
+
+type series struct {
+	labelValues []string
+	value       float64  // counter value
+	counts      []uint64 // histogram bucket counts (non-cumulative)
+	sum         float64  // histogram sum
+	count       uint64   // histogram observation count
+}
+
+// CounterVec is a counter partitioned by label values.
+type CounterVec struct{ f *family }
+
+// HistogramVec is a histogram partitioned by label values.
+type HistogramVec struct{ f *family }
+
+// Counter registers (or returns the existing) counter family.
+func (r *Registry) Counter(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{f: r.register(name, help, typeCounter, nil, labels)}
+}
+
+// Histogram registers (or returns the existing) histogram family. Nil buckets
+// use DefaultBuckets.
+func (r *Registry) Histogram(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	b := append([]float64(nil), buckets...)
+	sort.Float64s(b)
+	return &HistogramVec{f: r.register(name, help, typeHistogram, b, labels)}
+}
+
+func (r *Registry) register(name, help, typ string, buckets []float64, labels []string) *family {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.byName[name]; ok {
+		if f.typ != typ {
+			panic(fmt.Sprintf("metrics: %s already registered as %s", name, f.typ))
+		}
+		return f
+	}
+	f := &family{
+		name:    name,
+		help:    help,
+		typ:     typ,
+		labels:  labels,
+		buckets: buckets,
+		series:  map[string]*series{},
+	}
+	r.families = append(r.families, f)
+	r.byName[name] = f
+	return f
+}
+
+// get returns the series for the label values, creating it if needed. The
+// caller must hold f.mu.
+func (f *family) get(labelValues []string) *series {
+	if len(labelValues) != len(f.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", f.name, len(f.labels), len(labelValues)))
+	}
+	key := strings.Join(labelValues, "\xff")
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labelValues: append([]string(nil), labelValues...)}
+		if f.typ == typeHistogram {
+			s.counts = make([]uint64, len(f.buckets))
+		}
+		f.series[key] = s
+	}
+	return s
+}
+
+// Inc adds one to the counter for the label values.
+func (c *CounterVec) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+// Add adds v (which must be non-negative) to the counter for the label values.
+func (c *CounterVec) Add(v float64, labelValues ...string) {
+	if v < 0 {
+		return
+	}
+	c.f.mu.Lock()
+	c.f.get(labelValues).value += v
+	c.f.mu.Unlock()
+}
+
+// Value returns the current counter value for the label values.
+func (c *CounterVec) Value(labelValues ...string) float64 {
+	c.f.mu.Lock()
+	defer c.f.mu.Unlock()
+	return c.f.get(labelValues).value
+}
+
+// Observe records v in the histogram for the label values.
+func (h *HistogramVec) Observe(v float64, labelValues ...string) {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	s := h.f.get(labelValues)
+	for i, upper := range h.f.buckets {
+		if v <= upper {
+			s.counts[i]++
+			break
+		}
+	}
+	s.sum += v
+	s.count++
+}
+
+// Count returns the number of observations for the label values.
+func (h *HistogramVec) Count(labelValues ...string) uint64 {
+	h.f.mu.Lock()
+	defer h.f.mu.Unlock()
+	return h.f.get(labelValues).count
+}
+
+// WriteText renders every family in the Prometheus text exposition format
+// (version 0.0.4). Families appear in registration order and series are sorted
+// by label values so output is stable.
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	families := append([]*family(nil), r.families...)
+	r.mu.Unlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw)
+	}
+	return bw.Flush()
+}
+
+func (f *family) write(w *bufio.Writer) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	fmt.Fprintf(w, "# HELP %s %s\n", f.name, escapeHelp(f.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", f.name, f.typ)
+
+	keys := make([]string, 0, len(f.series))
+	for k := range f.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		s := f.series[k]
+		switch f.typ {
+		case typeCounter:
+			fmt.Fprintf(w, "%s%s %s\n", f.name, f.labelString(s.labelValues, "", ""), formatFloat(s.value))
+		case typeHistogram:
+			var cumulative uint64
+			for i, upper := range f.buckets {
+				cumulative += s.counts[i]
+				fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelString(s.labelValues, "le", formatFloat(upper)), cumulative)
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d\n", f.name, f.labelString(s.labelValues, "le", "+Inf"), s.count)
+			fmt.Fprintf(w, "%s_sum%s %s\n", f.name, f.labelString(s.labelValues, "", ""), formatFloat(s.sum))
+			fmt.Fprintf(w, "%s_count%s %d\n", f.name, f.labelString(s.labelValues, "", ""), s.count)
+		}
+	}
+}
+
+func (f *family) labelString(values []string, extraName, extraValue string) string {
+	if len(f.labels) == 0 && extraName == "" {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i, name := range f.labels {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", name, escapeLabel(values[i]))
+	}
+	if extraName != "" {
+		if len(f.labels) > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, "%s=\"%s\"", extraName, extraValue)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var (
+	labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+	helpEscaper  = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+)
+
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
+
+func escapeHelp(s string) string { return helpEscaper.Replace(s) }
@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestWriteText(t *testing.T) {
+	reg := NewRegistry()
+	decisions := reg.Counter("hguard_decisions_total", "Decisions made.", "tool", "status")
+	latency := reg.Histogram("hguard_latency_seconds", "Latency.", []float64{0.1, 1}, "tool")
+	hits := reg.Counter("hguard_hits_total", "Cache hits.")
+
+	decisions.Inc("weather", "approved")
+	decisions.Inc("weather", "approved")
+	decisions.Inc("transfer", "rejected")
+	latency.Observe(0.05, "weather")
+	latency.Observe(0.5, "weather")
+	latency.Observe(5, "weather")
+	hits.Add(3)
+
+	var buf bytes.Buffer
+	if err := reg.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText failed: %v", err)
+	}
+	out := buf.String()
+
+	expected := []string{
+		"# HELP hguard_decisions_total Decisions made.",
+		"# TYPE hguard_decisions_total counter",
+		`hguard_decisions_total{tool="transfer",status="rejected"} 1`,
+		`hguard_decisions_total{tool="weather",status="approved"} 2`,
+		"# TYPE hguard_latency_seconds histogram",
+		`hguard_latency_seconds_bucket{tool="weather",le="0.1"} 1`,
+		`hguard_latency_seconds_bucket{tool="weather",le="1"} 2`,
+		`hguard_latency_seconds_bucket{tool="weather",le="+Inf"} 3`,
+		`hguard_latency_seconds_sum{tool="weather"} 5.55`,
+		`hguard_latency_seconds_count{tool="weather"} 3`,
+		"hguard_hits_total 3",
+	}
+	for _, line := range expected {
+		if !strings.Contains(out, line+"\n") {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, out)
+		}
+	}
+}
+
+func TestLabelEscaping(t *testing.T) {
+	reg := NewRegistry()
+	reg.Counter("c_total", "Counter.", "tool").Inc("bad\"name\n")
+
+	var buf bytes.Buffer
+	reg.WriteText(&buf)
+	if !strings.Contains(buf.String(), `c_total{tool="bad\"name\n"} 1`) {
+		t.Errorf("Expected escaped label value, got:\n%s", buf.String())
+	}
+}
@@ -0,0 +1,134 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Package quota tracks cost consumed per user or tenant over daily and
+// monthly periods.
+//
+// Usage is stored as counters keyed by scope, subject and period bucket (see
+// Key), so any key/value store with atomic increments and expiry can back a
+// Store.
+//
+// Example usage:
+//
+//	store := quota.NewMemoryStore()
+//	now := time.Now()
+//	key := quota.Key(quota.ScopeUser, "alice", quota.Daily, now)
+//	total, err := store.Add(ctx, key, 2.5, quota.PeriodEnd(quota.Daily, now))
+//	used, err := store.Get(ctx, key)
+//
+// Budget periods. Periods are calendar days and months in UTC.
+const (
+	Daily   = "daily"
+	Monthly = "monthly"
+)
+
+// Budget scopes.
+const (
+	ScopeUser   = "user"
+	ScopeTenant = "tenant"
+)
+
+// Store holds usage counters. Implementations must be safe for concurrent use
+// and may drop a counter once its expiry has passed.
+type Store interface {
+	// Get returns the current value of a counter, or zero if it does not exist.
+	Get(ctx context.Context, key string) (float64, error)
+	// Add increments a counter, creating it with the given expiry if needed,
+	// and returns the new value.
+	Add(ctx context.Context, key string, amount float64, expiresAt time.Time) (float64, error)
+}
+
+// Key returns the counter key for a subject's usage in the period containing t.
+//
+// Example:
+//
+//	quota.Key(quota.ScopeUser, "alice", quota.Daily, t) // "user:alice:daily:2024-05-01"
+func Key(scope, id, period string, t time.Time) string {
+	t = t.UTC()
+	bucket := t.Format("2006-01-02")
+	if period == Monthly {
+		bucket = t.Format("2006-01")
+	}
+	return fmt.Sprintf("%s:%s:%s:%s", scope, id, period, bucket)
+}
+
+// PeriodEnd returns the end of the period containing t.
+func PeriodEnd(period string, t time.Time) time.Time {
+	t = t.UTC()
+	if period == Monthly {
+		return time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+	}
+	return time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+}
+
+// ValidPeriod reports whether period is Daily or Monthly.
+func ValidPeriod(period string) bool {
+	return period == Daily || period == Monthly
+}
+
+// MemoryStore is an in-memory Store.
+type MemoryStore struct {
+	mu       sync.Mutex
+	counters map[string]*counter
+	now      func() time.Time
+	swept    time.Time
+}
+
+type counter struct {
+	value     float64
+	expiresAt time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{counters: map[string]*counter{}, now: time.Now}
+}
+
+// Get implements Store.
+func (m *MemoryStore) Get(ctx context.Context, key string) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c, ok := m.counters[key]
+	if !ok || !m.now().Before(c.expiresAt) {
+		return 0, nil
+	}
+	return c.value, nil
+}
+
+// Add implements Store.
+func (m *MemoryStore) Add(ctx context.Context, key string, amount float64, expiresAt time.Time) (float64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.evictExpired(now)
+	c, ok := m.counters[key]
+	if !ok || !now.Before(c.expiresAt) {
+		c = &counter{expiresAt: expiresAt}
+		m.counters[key] = c
+	}
+	c.value += amount
+	return c.value, nil
+}
+
+// sweepInterval bounds how often Add scans for expired counters.
+const sweepInterval = time.Minute
+
+// evictExpired drops expired counters, at most once per sweepInterval. The
+// caller must hold m.mu.
+func (m *MemoryStore) evictExpired(now time.Time) {
+	if now.Sub(m.swept) < sweepInterval {
+		return
+	}
+	m.swept = now
+	for k, c := range m.counters {
+		if !now.Before(c.expiresAt) {
+			delete(m.counters, k)
+		}
+	}
+}
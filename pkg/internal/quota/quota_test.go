@@ -0,0 +1,44 @@
+package quota
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestKeyAndPeriodEnd(t *testing.T) {
+	at := time.Date(2024, 12, 31, 23, 30, 0, 0, time.UTC)
+
+	if got := Key(ScopeUser, "alice", Daily, at); got != "user:alice:daily:2024-12-31" {
+		t.Errorf("Unexpected daily key %q", got)
+	}
+	if got := Key(ScopeTenant, "acme", Monthly, at); got != "tenant:acme:monthly:2024-12" {
+		t.Errorf("Unexpected monthly key %q", got)
+	}
+	if got := PeriodEnd(Daily, at); !got.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected daily end %v", got)
+	}
+	if got := PeriodEnd(Monthly, at); !got.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected monthly end %v", got)
+	}
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	store.Add(ctx, "k", 2, now.Add(time.Hour))
+	if total, _ := store.Add(ctx, "k", 3, now.Add(time.Hour)); total != 5 {
+		t.Errorf("Expected 5, got %v", total)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if used, _ := store.Get(ctx, "k"); used != 0 {
+		t.Errorf("Expected expired counter to read 0, got %v", used)
+	}
+	if total, _ := store.Add(ctx, "k", 1, now.Add(time.Hour)); total != 1 {
+		t.Errorf("Expected expired counter to restart, got %v", total)
+	}
+}
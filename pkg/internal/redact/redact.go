@@ -0,0 +1,301 @@
+package redact
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Package redact masks sensitive values in tool call parameters, audit records and log output.
+//
+// A Redactor combines parameter names flagged `sensitive: true` in a tool
+// schema (masked entirely) with a set of detectors that find sensitive
+// substrings such as email addresses or card numbers in free text.
+//
+// Example usage:
+//
+//	r := redact.New(redact.DefaultDetectors(), "")
+//	masked := r.Parameters(params, map[string]bool{"body": true})
+//	line := r.String("contact me at jane@example.com")
+//
+// DefaultMask replaces values of parameters flagged as sensitive.
+const DefaultMask = "[REDACTED]"
+
+// Detector finds one kind of sensitive substring.
+type Detector struct {
+	Name    string
+	Pattern *regexp.Regexp
+	// Validate, if set, must accept a regex match before it is redacted. It is
+	// used to cut false positives, e.g. the Luhn check for card numbers.
+	Validate func(match string) bool
+}
+
+// Built-in detector names.
+const (
+	DetectorEmail      = "email"
+	DetectorPhone      = "phone"
+	DetectorCreditCard = "credit_card"
+	DetectorIBAN       = "iban"
+	DetectorAPIKey     = "api_key"
+)
+
+// DefaultDetectors returns the built-in detector set. Order matters: card
+// numbers and IBANs are matched before the looser phone pattern.
+func DefaultDetectors() []Detector {
+	return []Detector{
+		{
+			Name:    DetectorAPIKey,
+			Pattern: regexp.MustCompile(`\b(?:sk-[A-Za-z0-9_\-]{20,}|AKIA[0-9A-Z]{16}|gh[pousr]_[A-Za-z0-9]{36,}|xox[abprs]-[A-Za-z0-9\-]{10,}|AIza[0-9A-Za-z_\-]{35}|eyJ[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+\.[A-Za-z0-9_\-]+)`),
+		},
+		{
+			Name:    DetectorEmail,
+			Pattern: regexp.MustCompile(`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`),
+		},
+		{
+			Name:     DetectorIBAN,
+			Pattern:  regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`),
+			Validate: ValidIBAN,
+		},
+		{
+			Name:     DetectorCreditCard,
+			Pattern:  regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`),
+			Validate: ValidLuhn,
+		},
+		{
+			Name:    DetectorPhone,
+			Pattern: regexp.MustCompile(`\+?\(?\d[\d\s().\-]{8,}\d`),
+			Validate: func(m string) bool {
+				n := len(digits(m))
+				return n >= 10 && n <= 15
+			},
+		},
+	}
+}
+
+// SelectDetectors returns the built-in detectors with the given names. An
+// empty list selects all of them; unknown names are ignored.
+func SelectDetectors(names []string) []Detector {
+	all := DefaultDetectors()
+	if len(names) == 0 {
+		return all
+	}
+	want := make(map[string]bool, len(names))
+	for _, n := range names {
+		want[n] = true
+	}
+	var selected []Detector
+	for _, d := range all {
+		if want[d.Name] {
+			selected = append(selected, d)
+		}
+	}
+	return selected
+}
+
+// Redactor masks sensitive values. The zero value redacts nothing; use New.
+type Redactor struct {
+	detectors []Detector
+	mask      string
+}
+
+// New creates a Redactor using the given detectors. An empty mask uses
+// DefaultMask.
+func New(detectors []Detector, mask string) *Redactor {
+	if mask == "" {
+		mask = DefaultMask
+	}
+	return &Redactor{detectors: detectors, mask: mask}
+}
+
+// String replaces every detector match in s with a labelled mask such as
+// "[REDACTED:email]".
+func (r *Redactor) String(s string) string {
+	if r == nil {
+		return s
+	}
+	for _, d := range r.detectors {
+		s = d.Pattern.ReplaceAllStringFunc(s, func(m string) string {
+			if d.Validate != nil && !d.Validate(m) {
+				return m
+			}
+			return r.label(d.Name)
+		})
+	}
+	return s
+}
+
+// Detect returns the names of the detectors that match s, in detector order.
+func (r *Redactor) Detect(s string) []string {
+	if r == nil {
+		return nil
+	}
+	var found []string
+	for _, d := range r.detectors {
+		for _, m := range d.Pattern.FindAllString(s, -1) {
+			if d.Validate == nil || d.Validate(m) {
+				found = append(found, d.Name)
+				break
+			}
+		}
+	}
+	return found
+}
+
+// Value redacts strings and byte slices found anywhere inside v, descending
+// into maps and slices. Other values are returned unchanged.
+func (r *Redactor) Value(v interface{}) interface{} {
+	if r == nil {
+		return v
+	}
+	return mapStrings(v, r.String)
+}
+
+// Masker returns a function that redacts values logged while handling a
+// call. Wherever the text of one of the call's sensitive parameters appears
+// it is replaced by the mask; everything else is redacted as by Value.
+//
+// Example:
+//
+//	logger := logging.New(r.Masker(params, map[string]bool{"body": true}))
+func (r *Redactor) Masker(params map[string]interface{}, sensitive map[string]bool) func(interface{}) interface{} {
+	if r == nil {
+		return func(v interface{}) interface{} { return v }
+	}
+	var secrets []string
+	for name := range sensitive {
+		if v, ok := params[name]; ok && v != nil {
+			if s := fmt.Sprint(v); s != "" {
+				secrets = append(secrets, s)
+			}
+		}
+	}
+	// Longer values first, so that one containing another is masked whole.
+	sort.Slice(secrets, func(i, j int) bool { return len(secrets[i]) > len(secrets[j]) })
+	mask := func(s string) string {
+		for _, secret := range secrets {
+			s = strings.ReplaceAll(s, secret, r.mask)
+		}
+		return r.String(s)
+	}
+	return func(v interface{}) interface{} { return mapStrings(v, mask) }
+}
+
+// mapStrings applies fn to the strings and byte slices found anywhere inside
+// v, descending into maps and slices.
+func mapStrings(v interface{}, fn func(string) string) interface{} {
+	switch val := v.(type) {
+	case string:
+		return fn(val)
+	case []byte:
+		return []byte(fn(string(val)))
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(val))
+		for k, item := range val {
+			out[k] = mapStrings(item, fn)
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i, item := range val {
+			out[i] = mapStrings(item, fn)
+		}
+		return out
+	case []string:
+		out := make([]string, len(val))
+		for i, item := range val {
+			out[i] = fn(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+// Parameters returns a redacted copy of params. Parameters named in sensitive
+// are replaced by the mask entirely; all other values are scanned by the
+// detectors. The input map is never modified.
+func (r *Redactor) Parameters(params map[string]interface{}, sensitive map[string]bool) map[string]interface{} {
+	if r == nil || params == nil {
+		return params
+	}
+	out := make(map[string]interface{}, len(params))
+	for k, v := range params {
+		if sensitive[k] {
+			out[k] = r.mask
+			continue
+		}
+		out[k] = r.Value(v)
+	}
+	return out
+}
+
+// label names the detector inside a bracketed mask ("[REDACTED:email]").
+// Custom masks without brackets are used as-is.
+func (r *Redactor) label(name string) string {
+	if strings.HasPrefix(r.mask, "[") && strings.HasSuffix(r.mask, "]") {
+		return strings.TrimSuffix(r.mask, "]") + ":" + name + "]"
+	}
+	return r.mask
+}
+
+// ValidLuhn reports whether the digits in s pass the Luhn checksum and form a
+// plausible card number length.
+func ValidLuhn(s string) bool {
+	d := digits(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+	sum := 0
+	double := false
+	for i := len(d) - 1; i >= 0; i-- {
+		n := int(d[i] - '0')
+		if double {
+			n *= 2
+			if n > 9 {
+				n -= 9
+			}
+		}
+		sum += n
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// ValidIBAN reports whether s is an IBAN with a correct ISO 7064 mod-97 check.
+func ValidIBAN(s string) bool {
+	s = strings.ReplaceAll(strings.ToUpper(s), " ", "")
+	if len(s) < 15 || len(s) > 34 {
+		return false
+	}
+	rearranged := s[4:] + s[:4]
+	var numeric strings.Builder
+	for _, c := range rearranged {
+		switch {
+		case c >= '0' && c <= '9':
+			numeric.WriteRune(c)
+		case c >= 'A' && c <= 'Z':
+			numeric.WriteString(strconv.Itoa(int(c-'A') + 10))
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(numeric.String(), 10)
+	if !ok {
+		return false
+	}
+	return new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+func digits(s string) string {
+	var b strings.Builder
+	for _, c := range s {
+		if unicode.IsDigit(c) {
+			b.WriteRune(c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,74 @@
+package redact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectors(t *testing.T) {
+	r := New(DefaultDetectors(), "")
+
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Email", "write to jane.doe@example.com today", "write to [REDACTED:email] today"},
+		{"Phone", "call +1 (555) 123-4567", "call [REDACTED:phone]"},
+		{"Valid card", "card 4111 1111 1111 1111 ok", "card [REDACTED:credit_card] ok"},
+		{"Luhn failure is kept", "order 4111 1111 1111 1112", "order 4111 1111 1111 1112"},
+		{"IBAN", "pay GB82 WEST 1234 5698 7654 32", "pay [REDACTED:iban]"},
+		{"API key", "key sk-abcdefghijklmnopqrstuvwx", "key [REDACTED:api_key]"},
+		{"Plain text", "the weather in London", "the weather in London"},
+		{"Short number", "room 1234", "room 1234"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := r.String(tt.input); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestParameters(t *testing.T) {
+	r := New(SelectDetectors([]string{DetectorEmail}), "***")
+	params := map[string]interface{}{
+		"to":     "bob@example.com",
+		"body":   "secret plans",
+		"amount": 42,
+		"cc":     []interface{}{"eve@example.com"},
+	}
+
+	got := r.Parameters(params, map[string]bool{"body": true})
+
+	if got["body"] != "***" {
+		t.Errorf("Expected sensitive body to be masked, got %v", got["body"])
+	}
+	if got["to"] != "***" {
+		t.Errorf("Expected email to be masked, got %v", got["to"])
+	}
+	if got["amount"] != 42 {
+		t.Errorf("Expected non-string value to be unchanged, got %v", got["amount"])
+	}
+	if cc := got["cc"].([]interface{}); strings.Contains(cc[0].(string), "@") {
+		t.Errorf("Expected nested email to be masked, got %v", cc[0])
+	}
+	if params["body"] != "secret plans" {
+		t.Error("Expected input parameters to be left unmodified")
+	}
+}
+
+func TestMasker(t *testing.T) {
+	r := New(SelectDetectors([]string{DetectorEmail}), "***")
+	mask := r.Masker(map[string]interface{}{"body": "secret plans", "pin": 1234}, map[string]bool{"body": true, "pin": true})
+
+	got := mask(`parsing "secret plans" for bob@example.com with pin 1234`)
+	if want := `parsing "***" for *** with pin ***`; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+	if got := mask(map[string]interface{}{"reason": "secret plans"}); got.(map[string]interface{})["reason"] != "***" {
+		t.Errorf("Expected nested values to be masked, got %v", got)
+	}
+}
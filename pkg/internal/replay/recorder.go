@@ -0,0 +1,50 @@
+package replay
+
+import (
+	"encoding/json"
+	"io"
+	"math/rand"
+	"sync"
+)
+
+// Recorder writes sampled tool calls with their decisions as JSON Lines,
+// in the corpus format ReadCalls reads. It is safe for concurrent use.
+//
+// Example:
+//
+//	rec := replay.NewRecorder(f, 0.1) // about one call in ten
+//	if rec.Sample() {
+//		err := rec.Write(replay.Record{ToolCall: tc, Decision: &decision})
+//	}
+type Recorder struct {
+	mu   sync.Mutex
+	w    io.Writer
+	rate float64
+}
+
+// NewRecorder creates a recorder writing to w. rate is the fraction of calls
+// to record; rates outside (0, 1) record every call.
+func NewRecorder(w io.Writer, rate float64) *Recorder {
+	if rate <= 0 || rate > 1 {
+		rate = 1
+	}
+	return &Recorder{w: w, rate: rate}
+}
+
+// Sample reports whether the next call should be recorded.
+func (r *Recorder) Sample() bool {
+	return r.rate >= 1 || rand.Float64() < r.rate
+}
+
+// Write appends a record as a single line.
+func (r *Recorder) Write(rec Record) error {
+	b, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, err = r.w.Write(b)
+	return err
+}
@@ -0,0 +1,197 @@
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// Package replay records tool calls and re-evaluates corpora of them, e.g.
+// to see which decisions a policy change would alter before it is merged,
+// or which recorded decisions the current policies no longer reproduce.
+//
+// A corpus is a JSON Lines file with one tool call per line, in the same
+// shape as the SDK's ToolCall. Recordings add the decision each call got:
+//
+//	{"name": "transfer", "parameters": {"amount": 500}, "context": {"user_role": "analyst"}}
+//	{"id": "call_1", "name": "search", ..., "decision": {"action": "ALLOW", "status": "approved"}}
+//
+// Example usage:
+//
+//	entries, err := replay.ReadCalls(f)
+//	report := replay.Compare(ctx, entries, oldEvaluator, newEvaluator)
+//	report = replay.Replay(ctx, entries, currentEvaluator)
+//	for _, g := range report.Groups { /* ... */ }
+
+// ErrMalformed is returned when a corpus line cannot be decoded.
+var ErrMalformed = errors.New("malformed corpus")
+
+// Record is a line of a corpus: a tool call and, in recordings, the
+// decision it got.
+type Record struct {
+	model.ToolCall
+	Decision *Decision `json:"decision,omitempty"`
+}
+
+// Entry is a tool call read from a corpus.
+type Entry struct {
+	Line     int // 1-based line number in the corpus
+	Call     model.ToolCall
+	Recorded *Decision // Nil unless the corpus is a recording
+}
+
+// ReadCalls reads a corpus of tool calls. Blank lines are skipped.
+func ReadCalls(r io.Reader) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var rec Record
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("%w: line %d: %v", ErrMalformed, line, err)
+		}
+		if rec.Name == "" {
+			return nil, fmt.Errorf("%w: line %d: missing tool name", ErrMalformed, line)
+		}
+		entries = append(entries, Entry{Line: line, Call: rec.ToolCall, Recorded: rec.Decision})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Evaluator decides a tool call, typically by validating it against one
+// set of schemas and policies.
+type Evaluator func(ctx context.Context, tc model.ToolCall) model.ValidationResult
+
+// Decision is the part of a validation result compared between runs.
+// Origin tags decisions taken by a budget or an infrastructure error, which
+// replaying cannot reproduce.
+type Decision struct {
+	Action   string `json:"action"`
+	Status   string `json:"status"`
+	Reason   string `json:"reason,omitempty"`
+	PolicyID string `json:"policy_id,omitempty"`
+	Origin   string `json:"origin,omitempty"`
+}
+
+// DecisionOf extracts the decision from a validation result.
+func DecisionOf(r model.ValidationResult) Decision {
+	return Decision{Action: r.PolicyAction, Status: r.Status, Reason: r.Reason, PolicyID: r.PolicyID, Origin: r.Origin}
+}
+
+// differs reports whether the action, status or reason changed. A change of
+// matched policy alone does not change the decision.
+func (d Decision) differs(other Decision) bool {
+	return d.Action != other.Action || d.Status != other.Status || d.Reason != other.Reason
+}
+
+// Change is a call decided differently by two evaluators.
+type Change struct {
+	Line int            `json:"line"`
+	Call model.ToolCall `json:"call"`
+	Old  Decision       `json:"old"`
+	New  Decision       `json:"new"`
+}
+
+// Group collects the changes of one tool that moved between the same
+// matched policies.
+type Group struct {
+	Tool      string   `json:"tool"`
+	OldPolicy string   `json:"old_policy"`
+	NewPolicy string   `json:"new_policy"`
+	Changes   []Change `json:"changes"`
+}
+
+// Report is the outcome of comparing decisions over a corpus.
+type Report struct {
+	Calls   int     `json:"calls"`             // Calls compared
+	Changed int     `json:"changed"`           // Calls whose decision changed
+	Skipped int     `json:"skipped,omitempty"` // Calls Replay skipped for lack of a reproducible recorded decision
+	Groups  []Group `json:"groups"`            // Sorted by tool, then old and new policy
+}
+
+// Compare replays every entry through both evaluators and reports the calls
+// whose action, status or reason differ. Both evaluators see the same call,
+// stamped with the current time if the corpus did not record one, so that
+// time conditions agree.
+func Compare(ctx context.Context, entries []Entry, old, new Evaluator) *Report {
+	return compare(ctx, entries, func(e Entry, tc model.ToolCall) (Decision, Decision, bool) {
+		return DecisionOf(old(ctx, tc)), DecisionOf(new(ctx, tc)), true
+	})
+}
+
+// Replay re-evaluates the entries of a recording and reports the calls whose
+// action, status or reason differ from the recorded decision. Calls keep
+// their recorded timestamp. Entries without a recorded decision, or whose
+// decision has an Origin, are skipped.
+func Replay(ctx context.Context, entries []Entry, current Evaluator) *Report {
+	return compare(ctx, entries, func(e Entry, tc model.ToolCall) (Decision, Decision, bool) {
+		if e.Recorded == nil || e.Recorded.Origin != "" {
+			return Decision{}, Decision{}, false
+		}
+		return *e.Recorded, DecisionOf(current(ctx, tc)), true
+	})
+}
+
+// compare collects the entries for which decide returns differing
+// decisions. decide returns false to skip an entry.
+func compare(ctx context.Context, entries []Entry, decide func(e Entry, tc model.ToolCall) (before, after Decision, ok bool)) *Report {
+	report := &Report{Groups: []Group{}}
+	groups := map[[3]string]int{}
+	now := time.Now()
+	for _, e := range entries {
+		if ctx.Err() != nil {
+			break
+		}
+		tc := e.Call
+		if tc.ID == "" {
+			tc.ID = fmt.Sprintf("replay_%d", e.Line)
+		}
+		if tc.Timestamp.IsZero() {
+			tc.Timestamp = now
+		}
+		before, after, ok := decide(e, tc)
+		if !ok {
+			report.Skipped++
+			continue
+		}
+		report.Calls++
+		if !before.differs(after) {
+			continue
+		}
+		report.Changed++
+		key := [3]string{tc.Name, before.PolicyID, after.PolicyID}
+		i, ok := groups[key]
+		if !ok {
+			i = len(report.Groups)
+			groups[key] = i
+			report.Groups = append(report.Groups, Group{Tool: key[0], OldPolicy: key[1], NewPolicy: key[2]})
+		}
+		report.Groups[i].Changes = append(report.Groups[i].Changes, Change{Line: e.Line, Call: e.Call, Old: before, New: after})
+	}
+	sort.SliceStable(report.Groups, func(i, j int) bool {
+		a, b := report.Groups[i], report.Groups[j]
+		if a.Tool != b.Tool {
+			return a.Tool < b.Tool
+		}
+		if a.OldPolicy != b.OldPolicy {
+			return a.OldPolicy < b.OldPolicy
+		}
+		return a.NewPolicy < b.NewPolicy
+	})
+	return report
+}
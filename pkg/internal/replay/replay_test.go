@@ -0,0 +1,138 @@
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestReadCalls(t *testing.T) {
+	corpus := `{"name": "transfer", "parameters": {"amount": 50}, "context": {"user_role": "analyst"}}
+
+{"name": "search", "parameters": {"q": "x"}}
+`
+	entries, err := ReadCalls(strings.NewReader(corpus))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 2 || entries[1].Line != 3 || entries[0].Call.Context.UserRole != "analyst" {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+
+	for _, bad := range []string{`{"name": "transfer"`, `{"parameters": {}}`} {
+		if _, err := ReadCalls(strings.NewReader(bad)); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Expected ErrMalformed for %s, got %v", bad, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// Both evaluators reject large transfers; the new one lowers the limit
+	// and rewords the reason for searches.
+	evaluator := func(limit float64, searchReason string) Evaluator {
+		return func(ctx context.Context, tc model.ToolCall) model.ValidationResult {
+			if tc.Timestamp.IsZero() {
+				t.Error("Expected replayed calls to carry a timestamp")
+			}
+			switch {
+			case tc.Name == "search":
+				return model.ValidationResult{Status: "approved", PolicyAction: "LOG", Reason: searchReason, PolicyID: "log-search"}
+			case tc.Parameters["amount"].(float64) > limit:
+				return model.ValidationResult{Status: "rejected", PolicyAction: "REJECT", Reason: "too large", PolicyID: "limit"}
+			}
+			return model.ValidationResult{Status: "approved", PolicyAction: "ALLOW", PolicyID: "default:allow"}
+		}
+	}
+	corpus := `{"name": "transfer", "parameters": {"amount": 50}}
+{"name": "transfer", "parameters": {"amount": 500}}
+{"name": "search", "parameters": {}}
+{"name": "transfer", "parameters": {"amount": 700}}
+{"name": "transfer", "parameters": {"amount": 5000}}
+`
+	entries, err := ReadCalls(strings.NewReader(corpus))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	report := Compare(context.Background(), entries, evaluator(1000, "searched"), evaluator(100, "search logged"))
+	if report.Calls != 5 || report.Changed != 3 {
+		t.Fatalf("Expected 3 of 5 calls to change, got %d of %d", report.Changed, report.Calls)
+	}
+	if len(report.Groups) != 2 {
+		t.Fatalf("Expected 2 groups, got %+v", report.Groups)
+	}
+	search, transfer := report.Groups[0], report.Groups[1]
+	if search.Tool != "search" || search.OldPolicy != "log-search" || search.NewPolicy != "log-search" || search.Changes[0].New.Reason != "search logged" {
+		t.Errorf("Unexpected search group: %+v", search)
+	}
+	if transfer.Tool != "transfer" || transfer.OldPolicy != "default:allow" || transfer.NewPolicy != "limit" || len(transfer.Changes) != 2 {
+		t.Fatalf("Unexpected transfer group: %+v", transfer)
+	}
+	if transfer.Changes[0].Line != 2 || transfer.Changes[1].Line != 4 || transfer.Changes[1].Old.Status != "approved" {
+		t.Errorf("Unexpected transfer changes: %+v", transfer.Changes)
+	}
+}
+
+func TestRecorderAndReplay(t *testing.T) {
+	var buf bytes.Buffer
+	rec := NewRecorder(&buf, 0)
+	calls := []model.ToolCall{
+		{ID: "c1", Name: "transfer", Parameters: map[string]interface{}{"amount": 50.0}, Timestamp: time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)},
+		{ID: "c2", Name: "transfer", Parameters: map[string]interface{}{"amount": 500.0}},
+	}
+	for _, tc := range calls {
+		if !rec.Sample() {
+			t.Fatal("Expected a zero rate to record every call")
+		}
+		decision := Decision{Action: "ALLOW", Status: "approved", PolicyID: "default:allow"}
+		if err := rec.Write(Record{ToolCall: tc, Decision: &decision}); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+	// Corpora without decisions mix in as skipped calls, and so do calls
+	// decided by a budget.
+	buf.WriteString(`{"name": "transfer", "parameters": {"amount": 5000}}` + "\n")
+	budget := Decision{Action: "BUDGET", Status: "rejected", PolicyID: "transfer:BUDGET", Origin: model.OriginBudget}
+	if err := rec.Write(Record{ToolCall: calls[0], Decision: &budget}); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	entries, err := ReadCalls(&buf)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(entries) != 4 || entries[0].Recorded == nil || entries[2].Recorded != nil {
+		t.Fatalf("Unexpected entries: %+v", entries)
+	}
+	report := Replay(context.Background(), entries, func(ctx context.Context, tc model.ToolCall) model.ValidationResult {
+		if tc.ID == "c1" && !tc.Timestamp.Equal(calls[0].Timestamp) {
+			t.Errorf("Expected the recorded timestamp, got %v", tc.Timestamp)
+		}
+		if tc.Parameters["amount"].(float64) > 100 {
+			return model.ValidationResult{Status: "rejected", PolicyAction: "REJECT", Reason: "too large", PolicyID: "limit"}
+		}
+		return model.ValidationResult{Status: "approved", PolicyAction: "ALLOW", PolicyID: "default:allow"}
+	})
+	if report.Calls != 2 || report.Skipped != 2 || report.Changed != 1 {
+		t.Fatalf("Expected 1 regression in 2 calls and 2 skipped, got %+v", report)
+	}
+	if change := report.Groups[0].Changes[0]; change.Line != 2 || change.Old.Status != "approved" || change.New.Status != "rejected" {
+		t.Errorf("Unexpected change: %+v", change)
+	}
+
+	sampled := NewRecorder(io.Discard, 0.25)
+	n := 0
+	for i := 0; i < 10000; i++ {
+		if sampled.Sample() {
+			n++
+		}
+	}
+	if n < 2000 || n > 3000 {
+		t.Errorf("Expected about 2500 of 10000 calls sampled, got %d", n)
+	}
+}
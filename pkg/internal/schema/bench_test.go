@@ -0,0 +1,58 @@
+package schema
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+)
+
+// BenchmarkValidateAndPolicy measures the validation pipeline behind
+// Guard.ValidateToolCall (schema lookup, parameter checks, detectors and
+// policy evaluation) for a tool with 1, 100 and 10k policies in its set.
+// Run with -benchmem to see allocations per call.
+func BenchmarkValidateAndPolicy(b *testing.B) {
+	registry := NewRegistry(false)
+	registry.Register(ToolSchema{
+		Name: "transfer",
+		Parameters: map[string]ParameterSchema{
+			"amount":   {Type: "number", Required: true},
+			"currency": {Type: "string", Required: true},
+		},
+	})
+	for _, n := range []int{1, 100, 10000} {
+		b.Run(fmt.Sprintf("policies=%d", n), func(b *testing.B) {
+			set := policy.NewSet(false)
+			for i := 0; i < n; i++ {
+				tool := "transfer"
+				if i%20 != 0 {
+					tool = fmt.Sprintf("tool_%d", i%500)
+				}
+				set.Register(policy.Policy{
+					ToolName:  tool,
+					Type:      policy.PolicyReject,
+					Condition: fmt.Sprintf("user.role == 'role_%d' && params.amount > %d", i, i),
+					Priority:  i % 50,
+				})
+			}
+			tc := model.ToolCall{
+				ID:         "call_1",
+				Name:       "transfer",
+				Parameters: map[string]interface{}{"amount": 10.0, "currency": "EUR"},
+				Context:    model.CallContext{UserID: "user123", UserRole: "user"},
+			}
+			opts := Options{Schemas: registry, Policy: policy.EvalOptions{Set: set}}
+			ctx := context.Background()
+			if result := ValidateAndPolicyWithOptions(ctx, tc, opts); !result.ExecutionAllowed {
+				b.Fatalf("Expected the call to be allowed, got %s", result.Reason)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				ValidateAndPolicyWithOptions(ctx, tc, opts)
+			}
+		})
+	}
+}
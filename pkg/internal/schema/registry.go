@@ -0,0 +1,77 @@
+package schema
+
+import "github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+
+// Registry is a set of tool schemas. The package-level functions use the
+// default registry (see Default); tenants get their own registries layered
+// over it.
+//
+// Example:
+//
+//	reg := schema.NewRegistry(true)
+//	err := reg.Load("tenants/acme/schemas.yaml")
+//	result := schema.ValidateAndPolicyWithOptions(ctx, tc, schema.Options{Schemas: reg})
+type Registry struct {
+	schemas map[string]ToolSchema
+	// inherit falls back to the default registry for tools the registry
+	// does not define.
+	inherit bool
+}
+
+// NewRegistry creates an empty registry. If inherit is true, tools it does
+// not define are looked up in the default registry.
+func NewRegistry(inherit bool) *Registry {
+	return &Registry{schemas: map[string]ToolSchema{}, inherit: inherit}
+}
+
+// Default returns the default registry, backed by the package-level one.
+func Default() *Registry {
+	return &Registry{schemas: toolSchemas}
+}
+
+// Register adds or replaces a tool schema.
+func (r *Registry) Register(ts ToolSchema) {
+	r.schemas[ts.Name] = ts
+}
+
+// Get returns the schema for a tool.
+func (r *Registry) Get(name string) (ToolSchema, bool) {
+	if ts, ok := r.schemas[name]; ok {
+		return ts, true
+	}
+	if r.inherit {
+		return Default().Get(name)
+	}
+	return ToolSchema{}, false
+}
+
+// All returns a copy of every schema visible through the registry.
+func (r *Registry) All() map[string]ToolSchema {
+	all := map[string]ToolSchema{}
+	if r.inherit {
+		all = Default().All()
+	}
+	for k, v := range r.schemas {
+		all[k] = v
+	}
+	return all
+}
+
+// Load reads a YAML schema file and registers its schemas. Nothing is
+// registered if the file is invalid.
+func (r *Registry) Load(path string) error {
+	return r.LoadWithExtensions(path, nil)
+}
+
+// LoadWithExtensions is Load for schemas whose cost expressions may use the
+// functions and providers of ext.
+func (r *Registry) LoadWithExtensions(path string, ext *policy.Extensions) error {
+	schemas, err := readSchemas(path, ext)
+	if err != nil {
+		return err
+	}
+	for _, s := range schemas {
+		r.Register(s)
+	}
+	return nil
+}
@@ -0,0 +1,260 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/redact"
+)
+
+// ResultSchema describes and restricts what a tool may return. It is declared
+// in the `returns:` block of a tool schema.
+//
+// Example YAML:
+//
+//	schemas:
+//	  - name: weather
+//	    returns:
+//	      type: object
+//	      properties:
+//	        temperature:
+//	          type: number
+//	          required: true
+//	      max_size: 4096
+//	      on_oversize: reject
+//	      forbidden_patterns: ["(?i)ignore (all )?previous instructions"]
+//	      pii: redact
+type ResultSchema struct {
+	Type              string                     `yaml:"type,omitempty"`               // "string", "number", "boolean", "object" or "array"
+	Properties        map[string]ParameterSchema `yaml:"properties,omitempty"`         // expected fields of object results
+	MaxSize           int                        `yaml:"max_size,omitempty"`           // maximum size in bytes (strings as-is, other values as JSON)
+	OnOversize        string                     `yaml:"on_oversize,omitempty"`        // "reject" (default) or "truncate" (string results only)
+	ForbiddenPatterns []string                   `yaml:"forbidden_patterns,omitempty"` // regexes that must not appear in the result
+	PII               string                     `yaml:"pii,omitempty"`                // "allow" (default), "reject" or "redact"
+}
+
+// Result outcomes.
+const (
+	ResultApproved  = "approved"
+	ResultRejected  = "rejected"
+	ResultTruncated = "truncated"
+	ResultRedacted  = "redacted"
+)
+
+// ResultCheck is the outcome of validating a tool result.
+type ResultCheck struct {
+	Status     string
+	Allowed    bool
+	Reason     string
+	Result     interface{} // the result to hand back to the model, possibly truncated or redacted
+	Violations []string
+}
+
+// truncationMarker is appended to truncated string results.
+const truncationMarker = "…[truncated]"
+
+var (
+	patternMu    sync.RWMutex
+	patternCache = map[string]*regexp.Regexp{}
+)
+
+func compilePattern(p string) (*regexp.Regexp, error) {
+	patternMu.RLock()
+	re, ok := patternCache[p]
+	patternMu.RUnlock()
+	if ok {
+		return re, nil
+	}
+	re, err := regexp.Compile(p)
+	if err != nil {
+		return nil, err
+	}
+	patternMu.Lock()
+	patternCache[p] = re
+	patternMu.Unlock()
+	return re, nil
+}
+
+// ValidateResult checks a tool result against rs. Checks run in order: shape,
+// forbidden patterns, PII (using the detectors in r) and size; the first
+// rejecting check wins. A nil rs approves any result.
+//
+// Example:
+//
+//	check := schema.ValidateResult(ts.Returns, output, redact.New(redact.DefaultDetectors(), ""))
+//	if check.Allowed { /* feed check.Result to the model */ }
+func ValidateResult(rs *ResultSchema, result interface{}, r *redact.Redactor) ResultCheck {
+	check := ResultCheck{Status: ResultApproved, Allowed: true, Result: result}
+	if rs == nil {
+		return check
+	}
+	reject := func(format string, args ...interface{}) ResultCheck {
+		check.Status = ResultRejected
+		check.Allowed = false
+		check.Reason = fmt.Sprintf(format, args...)
+		check.Violations = append(check.Violations, check.Reason)
+		check.Result = nil
+		return check
+	}
+
+	// Structured results are often returned as raw JSON text
+	if rs.Type == "object" || rs.Type == "array" {
+		switch raw := result.(type) {
+		case string:
+			var decoded interface{}
+			if err := json.Unmarshal([]byte(raw), &decoded); err != nil {
+				return reject("result is not valid JSON: %v", err)
+			}
+			result = decoded
+		case []byte:
+			var decoded interface{}
+			if err := json.Unmarshal(raw, &decoded); err != nil {
+				return reject("result is not valid JSON: %v", err)
+			}
+			result = decoded
+		}
+		check.Result = result
+	}
+
+	if err := checkShape(rs, result); err != nil {
+		return reject("result shape mismatch: %v", err)
+	}
+
+	text, err := resultText(result)
+	if err != nil {
+		return reject("result cannot be encoded: %v", err)
+	}
+
+	for _, p := range rs.ForbiddenPatterns {
+		re, err := compilePattern(p)
+		if err != nil {
+			return reject("invalid forbidden pattern %q: %v", p, err)
+		}
+		if re.MatchString(text) {
+			return reject("result matches forbidden pattern %q", p)
+		}
+	}
+
+	switch rs.PII {
+	case "reject":
+		if found := r.Detect(text); len(found) > 0 {
+			return reject("result contains sensitive data: %s", strings.Join(found, ", "))
+		}
+	case "redact":
+		if found := r.Detect(text); len(found) > 0 {
+			check.Status = ResultRedacted
+			check.Reason = "sensitive data redacted: " + strings.Join(found, ", ")
+			check.Result = r.Value(result)
+			text, _ = resultText(check.Result)
+		}
+	}
+
+	if rs.MaxSize > 0 && len(text) > rs.MaxSize {
+		str, isString := check.Result.(string)
+		if rs.OnOversize != "truncate" || !isString {
+			return reject("result size %d exceeds max_size %d", len(text), rs.MaxSize)
+		}
+		check.Status = ResultTruncated
+		check.Reason = fmt.Sprintf("result truncated from %d to %d bytes", len(text), rs.MaxSize)
+		check.Result = truncate(str, rs.MaxSize)
+	}
+	return check
+}
+
+// validate rejects result schemas with unknown settings or invalid
+// patterns, which would otherwise weaken checks silently.
+func (rs *ResultSchema) validate() error {
+	switch rs.Type {
+	case "", "string", "number", "boolean", "object", "array":
+	default:
+		return fmt.Errorf("unknown type %q", rs.Type)
+	}
+	switch rs.OnOversize {
+	case "", "reject", "truncate":
+	default:
+		return fmt.Errorf("unknown on_oversize %q (want reject or truncate)", rs.OnOversize)
+	}
+	switch rs.PII {
+	case "", "allow", "reject", "redact":
+	default:
+		return fmt.Errorf("unknown pii %q (want allow, reject or redact)", rs.PII)
+	}
+	if rs.MaxSize < 0 {
+		return fmt.Errorf("max_size must not be negative")
+	}
+	for _, p := range rs.ForbiddenPatterns {
+		if _, err := compilePattern(p); err != nil {
+			return fmt.Errorf("invalid forbidden pattern %q: %w", p, err)
+		}
+	}
+	return nil
+}
+
+// checkShape verifies the result's type and, for objects, its properties.
+func checkShape(rs *ResultSchema, result interface{}) error {
+	switch rs.Type {
+	case "":
+		return nil
+	case "string":
+		if _, ok := result.(string); !ok {
+			return fmt.Errorf("expected string, got %T", result)
+		}
+	case "number":
+		switch result.(type) {
+		case float64, float32, int, int64, int32:
+		default:
+			return fmt.Errorf("expected number, got %T", result)
+		}
+	case "boolean":
+		if _, ok := result.(bool); !ok {
+			return fmt.Errorf("expected boolean, got %T", result)
+		}
+	case "array":
+		if _, ok := result.([]interface{}); !ok {
+			return fmt.Errorf("expected array, got %T", result)
+		}
+	case "object":
+		obj, ok := result.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected object, got %T", result)
+		}
+		return ValidateParameters(ToolSchema{Parameters: rs.Properties}, obj)
+	default:
+		return fmt.Errorf("unknown result type %q", rs.Type)
+	}
+	return nil
+}
+
+// resultText renders a result for pattern, PII and size checks.
+func resultText(result interface{}) (string, error) {
+	switch v := result.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	}
+	b, err := json.Marshal(result)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
+// truncate cuts s to at most max bytes including the marker, without
+// splitting a UTF-8 sequence. The marker is left out when max is too small
+// to hold it.
+func truncate(s string, max int) string {
+	marker := truncationMarker
+	cut := max - len(marker)
+	if cut <= 0 {
+		marker, cut = "", max
+	}
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	return s[:cut] + marker
+}
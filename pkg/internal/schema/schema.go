@@ -1,12 +1,17 @@
 package schema
 
 import (
+	"context"
 	"fmt"
+	"math"
 	"os"
+	"sort"
 
 	"github.com/SafellmHub/hguard-go/pkg/internal/core/fuzzy"
 	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
 	"github.com/SafellmHub/hguard-go/pkg/internal/core/policy"
+	"github.com/SafellmHub/hguard-go/pkg/internal/detect"
+	"github.com/SafellmHub/hguard-go/pkg/internal/trace"
 	"gopkg.in/yaml.v3"
 )
 
@@ -38,12 +43,17 @@ type ParameterSchema struct {
 	Enum      []string // allowed values (optional)
 	Pattern   string   // regex pattern (optional)
 	MaxLength int      // for strings (optional)
+	Sensitive bool     // mask the value in audit records and logs (optional)
+	Detectors []string // injection detectors run on string values, e.g. "sql_injection" (optional)
 }
 
 // ToolSchema defines the schema for a tool.
 type ToolSchema struct {
 	Name       string
 	Parameters map[string]ParameterSchema
+	Returns    *ResultSchema // optional; enables ValidateResult
+	Cost       string        // optional; number or expression such as "params.amount * 0.01", charged against BUDGET policies
+	Tags       []string      // optional; e.g. [financial, destructive], targeted by policies' tags
 }
 
 // In-memory registry of tool schemas
@@ -55,7 +65,7 @@ var toolSchemas = map[string]ToolSchema{}
 //
 //	schema.RegisterToolSchema(ToolSchema{Name: "weather", Parameters: ...})
 func RegisterToolSchema(schema ToolSchema) {
-	toolSchemas[schema.Name] = schema
+	Default().Register(schema)
 }
 
 // GetToolSchema retrieves a tool schema by name.
@@ -64,8 +74,7 @@ func RegisterToolSchema(schema ToolSchema) {
 //
 //	ts, ok := schema.GetToolSchema("weather")
 func GetToolSchema(name string) (ToolSchema, bool) {
-	schema, ok := toolSchemas[name]
-	return schema, ok
+	return Default().Get(name)
 }
 
 // ToolSchemas returns a copy of all registered tool schemas.
@@ -74,11 +83,36 @@ func GetToolSchema(name string) (ToolSchema, bool) {
 //
 //	all := schema.ToolSchemas()
 func ToolSchemas() map[string]ToolSchema {
-	copy := make(map[string]ToolSchema, len(toolSchemas))
-	for k, v := range toolSchemas {
-		copy[k] = v
+	return Default().All()
+}
+
+// SensitiveParameters returns the names of parameters flagged `sensitive: true`
+// in the schema for a tool, or nil if the tool is unknown.
+//
+// Example:
+//
+//	sensitive := schema.SensitiveParameters("send_email")
+func SensitiveParameters(name string) map[string]bool {
+	return Default().SensitiveParameters(name)
+}
+
+// SensitiveParameters returns the names of parameters flagged `sensitive: true`
+// in the schema for a tool, or nil if the tool is unknown.
+func (r *Registry) SensitiveParameters(name string) map[string]bool {
+	ts, ok := r.Get(name)
+	if !ok {
+		return nil
+	}
+	var sensitive map[string]bool
+	for paramName, paramSchema := range ts.Parameters {
+		if paramSchema.Sensitive {
+			if sensitive == nil {
+				sensitive = make(map[string]bool)
+			}
+			sensitive[paramName] = true
+		}
 	}
-	return copy
+	return sensitive
 }
 
 // ValidateParameters checks if the parameters conform to the schema.
@@ -126,21 +160,69 @@ func ValidateParameters(schema ToolSchema, params map[string]interface{}) error
 //
 //	err := schema.LoadSchemasFromYAML("schemas.yaml")
 func LoadSchemasFromYAML(path string) error {
+	return Default().Load(path)
+}
+
+// LoadSchemasWithExtensions is LoadSchemasFromYAML for schemas whose cost
+// expressions may use the functions and providers of ext.
+//
+// Example:
+//
+//	err := schema.LoadSchemasWithExtensions("schemas.yaml", ext)
+func LoadSchemasWithExtensions(path string, ext *policy.Extensions) error {
+	return Default().LoadWithExtensions(path, ext)
+}
+
+// readSchemas decodes and checks the schemas in a YAML file. Cost
+// expressions are checked against ext.
+func readSchemas(path string, ext *policy.Extensions) ([]ToolSchema, error) {
 	f, err := os.Open(path)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer f.Close()
 	var data struct {
 		Schemas []ToolSchema `yaml:"schemas"`
 	}
 	if err := yaml.NewDecoder(f).Decode(&data); err != nil {
-		return err
+		return nil, err
 	}
 	for _, s := range data.Schemas {
-		RegisterToolSchema(s)
+		if s.Cost != "" {
+			if err := ext.CheckExpression(s.Cost); err != nil {
+				return nil, fmt.Errorf("schema %s: cost: %w", s.Name, err)
+			}
+		}
+		if s.Returns != nil {
+			if err := s.Returns.validate(); err != nil {
+				return nil, fmt.Errorf("schema %s: returns: %w", s.Name, err)
+			}
+		}
+		for paramName, paramSchema := range s.Parameters {
+			for _, d := range paramSchema.Detectors {
+				if !detect.Known(d) {
+					return nil, fmt.Errorf("schema %s: parameter %s: unknown detector %q", s.Name, paramName, d)
+				}
+			}
+		}
 	}
-	return nil
+	return data.Schemas, nil
+}
+
+// Options configures ValidateAndPolicyWithOptions.
+type Options struct {
+	// Policy configures policy evaluation. Policy.Tracer also receives the
+	// schema lookup, fuzzy match and parameter validation spans.
+	Policy policy.EvalOptions
+	// OnFuzzyMatch, if set, is called when an unknown tool name is corrected
+	// to a known one.
+	OnFuzzyMatch func(input, suggestion string)
+	// FailOpen allows execution when validation times out or is cancelled.
+	// By default such calls are rejected.
+	FailOpen bool
+	// Schemas is the schema registry to validate against. Nil uses the
+	// default registry.
+	Schemas *Registry
 }
 
 // ValidateAndPolicy validates a tool call and applies the policy, returning a ValidationResult.
@@ -149,25 +231,51 @@ func LoadSchemasFromYAML(path string) error {
 //
 //	result := schema.ValidateAndPolicy(tc)
 func ValidateAndPolicy(tc model.ToolCall) model.ValidationResult {
-	result := model.ValidationResult{
-		ToolCallID:       tc.ID,
-		Status:           "approved",
-		Confidence:       1.0,
-		ExecutionAllowed: true,
-		PolicyAction:     string(policy.PolicyAllow),
+	return ValidateAndPolicyWithOptions(context.Background(), tc, Options{})
+}
+
+// ValidateAndPolicyWithOptions is ValidateAndPolicy with evaluation hooks.
+//
+// Example:
+//
+//	result := schema.ValidateAndPolicyWithOptions(ctx, tc, schema.Options{Policy: policy.EvalOptions{Tracer: t}})
+func ValidateAndPolicyWithOptions(ctx context.Context, tc model.ToolCall, opts Options) model.ValidationResult {
+	tracer := opts.Policy.Tracer
+	registry := opts.Schemas
+	if registry == nil {
+		registry = Default()
+	}
+	result := approvedResult(tc)
+
+	if err := ctx.Err(); err != nil {
+		return timeoutResult(tc, err.Error(), opts.FailOpen)
 	}
 
-	schema, ok := GetToolSchema(tc.Name)
+	_, lookupSpan := trace.Start(ctx, tracer, trace.SpanSchemaLookup)
+	schema, ok := registry.Get(tc.Name)
+	lookupSpan.SetAttribute(trace.AttrSchemaFound, ok)
+	lookupSpan.End()
 	if !ok {
 		// Evaluate policy for unknown tool
-		policyResult := policy.EvaluatePolicy(tc)
+		policyResult := policy.EvaluatePolicyWithOptions(ctx, tc, opts.Policy)
+		if policyResult.Action == policy.PolicyTimeout {
+			return timeoutResult(tc, policyResult.Reason, opts.FailOpen)
+		}
 		if policyResult.Action == policy.PolicyRewrite {
 			// Fuzzy match to suggest correction
-			known := make([]string, 0, len(toolSchemas))
-			for k := range toolSchemas {
+			_, fuzzySpan := trace.Start(ctx, tracer, trace.SpanFuzzyMatch)
+			all := registry.All()
+			known := make([]string, 0, len(all))
+			for k := range all {
 				known = append(known, k)
 			}
-			if suggestion, _ := fuzzy.FuzzyMatchToolName(tc.Name, known, 2); suggestion != "" {
+			suggestion, _ := fuzzy.FuzzyMatchToolName(tc.Name, known, 2)
+			fuzzySpan.SetAttribute(trace.AttrFuzzySuggestion, suggestion)
+			fuzzySpan.End()
+			if suggestion != "" {
+				if opts.OnFuzzyMatch != nil {
+					opts.OnFuzzyMatch(tc.Name, suggestion)
+				}
 				result.Status = "rewritten"
 				result.Confidence = 1.0
 				result.ExecutionAllowed = true
@@ -197,7 +305,12 @@ func ValidateAndPolicy(tc model.ToolCall) model.ValidationResult {
 		return result
 	}
 
+	_, paramSpan := trace.Start(ctx, tracer, trace.SpanValidateParameters)
 	err := ValidateParameters(schema, tc.Parameters)
+	if err != nil {
+		paramSpan.RecordError(err)
+	}
+	paramSpan.End()
 	if err != nil {
 		result.Status = "rejected"
 		result.Confidence = 0.0
@@ -207,9 +320,62 @@ func ValidateAndPolicy(tc model.ToolCall) model.ValidationResult {
 		return result
 	}
 
+	finding := ScanParameters(schema, tc.Parameters)
+	if finding.Fired {
+		result.Status = "rejected"
+		result.Confidence = finding.Score
+		result.ExecutionAllowed = false
+		result.Reason = fmt.Sprintf("parameter %s looks like %s (score %.2f)", finding.Parameter, finding.Detector, finding.Score)
+		result.PolicyAction = string(policy.PolicyReject)
+		result.PolicyID = "detector:" + finding.Detector
+		return result
+	}
+
 	// Use the new policy evaluation with context-aware conditions
-	policyResult := policy.EvaluatePolicy(tc)
+	opts.Policy.ToolTags = schema.Tags
+	result = applyPolicy(ctx, tc, opts, result)
+	// Sub-threshold detector scores lower the confidence of allowed calls
+	if result.ExecutionAllowed && finding.Score > 0 {
+		result.Confidence = 1 - finding.Score
+	}
+	return result
+}
+
+// EvaluatePolicyOnly applies only the policies to a call, deciding as
+// ValidateAndPolicyWithOptions does for a call its tool's schema accepts.
+// It compares policy sets without the schemas they guard.
+//
+// Example:
+//
+//	result := schema.EvaluatePolicyOnly(ctx, tc, schema.Options{Policy: policy.EvalOptions{Set: set}})
+func EvaluatePolicyOnly(ctx context.Context, tc model.ToolCall, opts Options) model.ValidationResult {
+	if err := ctx.Err(); err != nil {
+		return timeoutResult(tc, err.Error(), opts.FailOpen)
+	}
+	return applyPolicy(ctx, tc, opts, approvedResult(tc))
+}
+
+// approvedResult is the result of a call nothing objects to.
+func approvedResult(tc model.ToolCall) model.ValidationResult {
+	return model.ValidationResult{
+		ToolCallID:       tc.ID,
+		Status:           "approved",
+		Confidence:       1.0,
+		ExecutionAllowed: true,
+		PolicyAction:     string(policy.PolicyAllow),
+	}
+}
+
+// applyPolicy evaluates the policies for a call and turns the outcome into
+// the result's decision.
+func applyPolicy(ctx context.Context, tc model.ToolCall, opts Options, result model.ValidationResult) model.ValidationResult {
+	policyResult := policy.EvaluatePolicyWithOptions(ctx, tc, opts.Policy)
+	if policyResult.Action == policy.PolicyTimeout {
+		return timeoutResult(tc, policyResult.Reason, opts.FailOpen)
+	}
 	result.PolicyAction = string(policyResult.Action)
+	result.PolicyID = policyResult.PolicyID
+	result.Origin = policyResult.Origin
 	result.Reason = policyResult.Reason
 
 	switch policyResult.Action {
@@ -225,6 +391,14 @@ func ValidateAndPolicy(tc model.ToolCall) model.ValidationResult {
 		result.Status = "approved"
 		result.Confidence = 1.0
 		result.ExecutionAllowed = true
+	case policy.PolicyBudget:
+		result.Status = "rejected"
+		result.Confidence = 1.0
+		result.ExecutionAllowed = false
+	case policy.PolicyRequireApproval:
+		result.Status = "pending"
+		result.Confidence = 1.0
+		result.ExecutionAllowed = false
 	case policy.PolicyRewrite:
 		result.Status = "rewritten"
 		result.Confidence = 1.0
@@ -244,3 +418,86 @@ func ValidateAndPolicy(tc model.ToolCall) model.ValidationResult {
 	}
 	return result
 }
+
+// ToolCost returns the cost of a call from its tool schema's Cost. Unknown
+// tools and tools without a cost are free.
+//
+// Example:
+//
+//	cost, err := schema.ToolCost(ctx, tc, policy.EvalOptions{})
+func ToolCost(ctx context.Context, tc model.ToolCall, opts policy.EvalOptions) (float64, error) {
+	return Default().ToolCost(ctx, tc, opts)
+}
+
+// ToolCost returns the cost of a call from its tool schema's Cost. A cost
+// that is negative or not finite is an error, so it can neither refund nor
+// poison usage counters.
+func (r *Registry) ToolCost(ctx context.Context, tc model.ToolCall, opts policy.EvalOptions) (float64, error) {
+	ts, ok := r.Get(tc.Name)
+	if !ok || ts.Cost == "" {
+		return 0, nil
+	}
+	cost, err := policy.EvaluateNumber(ctx, ts.Cost, tc, opts)
+	if err != nil {
+		return 0, fmt.Errorf("cost of %s: %w", tc.Name, err)
+	}
+	if cost < 0 || math.IsNaN(cost) || math.IsInf(cost, 0) {
+		return 0, fmt.Errorf("cost of %s: invalid cost %v", tc.Name, cost)
+	}
+	return cost, nil
+}
+
+// DetectorFinding is the highest detector score across a call's parameters.
+type DetectorFinding struct {
+	Parameter string
+	Detector  string
+	Score     float64
+	Fired     bool // Score reached detect.DefaultThreshold
+}
+
+// ScanParameters runs the detectors declared on each parameter against its
+// string value and returns the highest-scoring finding. Parameters are
+// scanned in name order so ties resolve deterministically.
+//
+// Example:
+//
+//	finding := schema.ScanParameters(ts, map[string]interface{}{"query": "1' OR '1'='1"})
+func ScanParameters(schema ToolSchema, params map[string]interface{}) DetectorFinding {
+	names := make([]string, 0, len(schema.Parameters))
+	for name, ps := range schema.Parameters {
+		if len(ps.Detectors) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var best DetectorFinding
+	for _, name := range names {
+		str, ok := params[name].(string)
+		if !ok {
+			continue
+		}
+		for _, d := range schema.Parameters[name].Detectors {
+			score, err := detect.Score(d, str)
+			if err != nil || score <= best.Score {
+				continue
+			}
+			best = DetectorFinding{Parameter: name, Detector: d, Score: score}
+		}
+	}
+	best.Fired = best.Score >= detect.DefaultThreshold
+	return best
+}
+
+// timeoutResult reports a validation that could not finish in time. The call
+// is rejected unless failOpen is set.
+func timeoutResult(tc model.ToolCall, reason string, failOpen bool) model.ValidationResult {
+	return model.ValidationResult{
+		ToolCallID:       tc.ID,
+		Status:           "timeout",
+		Confidence:       0.0,
+		Reason:           reason,
+		ExecutionAllowed: failOpen,
+		PolicyAction:     string(policy.PolicyTimeout),
+	}
+}
@@ -0,0 +1,127 @@
+package session
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+// Package session records the approved tool calls of each session so that
+// policies can reason about history the caller cannot forge.
+//
+// Example usage:
+//
+//	store := session.NewMemoryStore(time.Hour, 1000)
+//	err := store.Append(ctx, "session456", model.SessionCall{ToolName: "weather", Timestamp: time.Now()})
+//	history, err := store.History(ctx, "session456")
+//
+// Store persists per-session call history. Implementations must be safe for
+// concurrent use.
+type Store interface {
+	// Append records an approved call for the session.
+	Append(ctx context.Context, sessionID string, call model.SessionCall) error
+	// History returns the session's calls, oldest first. Unknown or expired
+	// sessions have an empty history.
+	History(ctx context.Context, sessionID string) ([]model.SessionCall, error)
+}
+
+// Default limits for NewMemoryStore.
+const (
+	DefaultTTL      = 24 * time.Hour
+	DefaultMaxCalls = 1000
+)
+
+// MemoryStore is an in-memory Store. Sessions expire ttl after their last
+// recorded call, and only the most recent maxCalls calls are kept.
+type MemoryStore struct {
+	mu       sync.Mutex
+	ttl      time.Duration
+	maxCalls int
+	sessions map[string]*memorySession
+	swept    time.Time
+	now      func() time.Time
+}
+
+type memorySession struct {
+	calls    []model.SessionCall
+	lastSeen time.Time
+}
+
+// NewMemoryStore creates an in-memory store. Non-positive ttl or maxCalls use
+// DefaultTTL and DefaultMaxCalls.
+func NewMemoryStore(ttl time.Duration, maxCalls int) *MemoryStore {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	if maxCalls <= 0 {
+		maxCalls = DefaultMaxCalls
+	}
+	return &MemoryStore{
+		ttl:      ttl,
+		maxCalls: maxCalls,
+		sessions: map[string]*memorySession{},
+		now:      time.Now,
+	}
+}
+
+// Append implements Store.
+func (m *MemoryStore) Append(ctx context.Context, sessionID string, call model.SessionCall) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	m.evictExpired(now)
+
+	// Sweeps are rate-limited, so an expired session may still be present.
+	s, ok := m.sessions[sessionID]
+	if !ok || m.expired(s, now) {
+		s = &memorySession{}
+		m.sessions[sessionID] = s
+	}
+	s.calls = append(s.calls, call)
+	if len(s.calls) > m.maxCalls {
+		s.calls = append([]model.SessionCall(nil), s.calls[len(s.calls)-m.maxCalls:]...)
+	}
+	s.lastSeen = now
+	return nil
+}
+
+// History implements Store.
+func (m *MemoryStore) History(ctx context.Context, sessionID string) ([]model.SessionCall, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.sessions[sessionID]
+	if !ok || m.expired(s, m.now()) {
+		return nil, nil
+	}
+	return append([]model.SessionCall(nil), s.calls...), nil
+}
+
+// Delete forgets a session.
+func (m *MemoryStore) Delete(sessionID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, sessionID)
+}
+
+func (m *MemoryStore) expired(s *memorySession, now time.Time) bool {
+	return now.Sub(s.lastSeen) > m.ttl
+}
+
+// sweepInterval bounds how often Append scans for expired sessions.
+const sweepInterval = time.Minute
+
+// evictExpired drops expired sessions, at most once per sweepInterval. The
+// caller must hold m.mu.
+func (m *MemoryStore) evictExpired(now time.Time) {
+	if now.Sub(m.swept) < sweepInterval {
+		return
+	}
+	m.swept = now
+	for id, s := range m.sessions {
+		if m.expired(s, now) {
+			delete(m.sessions, id)
+		}
+	}
+}
@@ -0,0 +1,45 @@
+package session
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/internal/core/model"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	store := NewMemoryStore(time.Hour, 2)
+	store.now = func() time.Time { return now }
+
+	for _, name := range []string{"search", "quote", "transfer"} {
+		if err := store.Append(ctx, "s1", model.SessionCall{ToolName: name, Timestamp: now}); err != nil {
+			t.Fatalf("Append failed: %v", err)
+		}
+	}
+
+	history, _ := store.History(ctx, "s1")
+	if len(history) != 2 || history[0].ToolName != "quote" || history[1].ToolName != "transfer" {
+		t.Errorf("Expected the 2 most recent calls, got %+v", history)
+	}
+
+	if history, _ := store.History(ctx, "other"); len(history) != 0 {
+		t.Errorf("Expected empty history for unknown session, got %+v", history)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if history, _ := store.History(ctx, "s1"); len(history) != 0 {
+		t.Errorf("Expected expired session to have no history, got %+v", history)
+	}
+
+	// An Append before the next sweep starts the expired session afresh.
+	store.swept = now
+	if err := store.Append(ctx, "s1", model.SessionCall{ToolName: "search", Timestamp: now}); err != nil {
+		t.Fatalf("Append failed: %v", err)
+	}
+	if history, _ := store.History(ctx, "s1"); len(history) != 1 || history[0].ToolName != "search" {
+		t.Errorf("Expected only the new call, got %+v", history)
+	}
+}
@@ -0,0 +1,75 @@
+package trace
+
+import "context"
+
+// Package trace defines the span hooks used to instrument tool call validation.
+//
+// The interfaces are deliberately small so they can be bridged to
+// OpenTelemetry (or any other tracing system) in a few lines, without the SDK
+// depending on it. A nil Tracer is valid and creates no-op spans.
+//
+// Example usage:
+//
+//	ctx, span := trace.Start(ctx, tracer, trace.SpanValidate)
+//	defer span.End()
+//	span.SetAttribute(trace.AttrToolName, "weather")
+//
+// Tracer starts spans. Start must return a context carrying the new span so
+// that spans started from it become its children.
+type Tracer interface {
+	Start(ctx context.Context, name string) (context.Context, Span)
+}
+
+// Span is a single timed operation.
+type Span interface {
+	SetAttribute(key string, value interface{})
+	RecordError(err error)
+	End()
+}
+
+// Span names.
+const (
+	SpanValidate           = "hguard.validate_tool_call"
+	SpanSchemaLookup       = "hguard.schema_lookup"
+	SpanFuzzyMatch         = "hguard.fuzzy_match"
+	SpanValidateParameters = "hguard.validate_parameters"
+	SpanEvaluatePolicy     = "hguard.evaluate_policy"
+	SpanCondition          = "hguard.condition"
+	SpanValidateResult     = "hguard.validate_tool_result"
+)
+
+// Attribute keys.
+const (
+	AttrToolName        = "hguard.tool.name"
+	AttrSchemaFound     = "hguard.schema.found"
+	AttrFuzzySuggestion = "hguard.fuzzy.suggestion"
+	AttrDecisionStatus  = "hguard.decision.status"
+	AttrDecisionAction  = "hguard.decision.action"
+	AttrDecisionCached  = "hguard.decision.cached"
+	AttrPolicyID        = "hguard.policy.id"
+	AttrPolicyPriority  = "hguard.policy.priority"
+	AttrCondition       = "hguard.condition.expression"
+	AttrConditionResult = "hguard.condition.result"
+)
+
+// Start starts a span with t, or a no-op span if t is nil.
+func Start(ctx context.Context, t Tracer, name string) (context.Context, Span) {
+	if t == nil {
+		return ctx, noopSpan{}
+	}
+	return t.Start(ctx, name)
+}
+
+// Noop is a Tracer that records nothing.
+type Noop struct{}
+
+// Start implements Tracer.
+func (Noop) Start(ctx context.Context, name string) (context.Context, Span) {
+	return ctx, noopSpan{}
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttribute(key string, value interface{}) {}
+func (noopSpan) RecordError(err error)                      {}
+func (noopSpan) End()                                       {}
@@ -94,7 +94,7 @@ func (a *StandardAgent) ProcessMessage(ctx context.Context, message string, user
 	// Create system message with role context
 	systemMessage := fmt.Sprintf("You are an AI assistant. The user has role: %s with permissions: %s. Current time: %s. %s",
 		userCtx.Role,
-		strings.Join(userCtx.Permissions, ", "),
+		strings.Join(a.EffectivePermissions(userCtx), ", "),
 		time.Now().Format("2006-01-02 15:04:05"),
 		a.config.SystemPrompt)
 
@@ -182,6 +182,9 @@ func (a *StandardAgent) ExecuteToolCall(ctx context.Context, toolCall ToolCallRe
 	case hallucinationguard.PolicyActionREJECT:
 		return fmt.Sprintf("Tool call rejected: %s", result.Error), nil
 
+	case hallucinationguard.PolicyActionREQUIRE_APPROVAL:
+		return fmt.Sprintf("Tool call is waiting for approval (token %s): %s", result.ApprovalToken, result.Error), nil
+
 	case hallucinationguard.PolicyActionREWRITE:
 		// Rewrite the tool call and try again
 		if result.SuggestedCorrection != nil {
@@ -333,15 +336,27 @@ func (a *StandardAgent) GetToolDescription(toolName string) string {
 	return "Tool description not available"
 }
 
+// EffectivePermissions returns the permissions granted to the user's role by
+// the policy configuration plus any extra grants on the user context
+func (a *StandardAgent) EffectivePermissions(userCtx UserContext) []string {
+	return a.guard.Permissions(&hallucinationguard.CallContext{UserRole: string(userCtx.Role), UserPermissions: userCtx.Permissions})
+}
+
 // GetUserStats returns statistics about the user's tool usage
 func (a *StandardAgent) GetUserStats(userCtx UserContext, sessionCtx SessionContext) map[string]interface{} {
+	// The guard records every approved call for the session
+	history, err := a.guard.SessionHistory(context.Background(), sessionCtx.ID)
+	if err != nil {
+		log.Printf("Session history error: %v", err)
+	}
+
 	return map[string]interface{}{
 		"user_id":         userCtx.ID,
 		"role":            userCtx.Role,
-		"permissions":     userCtx.Permissions,
+		"permissions":     a.EffectivePermissions(userCtx),
 		"session_id":      sessionCtx.ID,
 		"session_start":   sessionCtx.StartTime,
-		"calls_made":      len(sessionCtx.PreviousCalls),
+		"calls_made":      len(history),
 		"available_tools": len(a.GetAvailableTools(userCtx)),
 	}
 }
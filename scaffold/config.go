@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/SafellmHub/hguard-go/pkg/hallucinationguard"
 )
 
 // UserRole represents different user roles in the system
@@ -25,7 +27,7 @@ const (
 type UserContext struct {
 	ID          string            `json:"id"`
 	Role        UserRole          `json:"role"`
-	Permissions []string          `json:"permissions"`
+	Permissions []string          `json:"permissions"` // Extra grants; role permissions come from the roles section of policies.yaml
 	IPAddress   string            `json:"ip_address"`
 	Metadata    map[string]string `json:"metadata"`
 }
@@ -133,20 +135,13 @@ func CallAnthropic(ctx context.Context, apiKey, prompt string) (string, error) {
 	return CallAnthropicConversation(ctx, apiKey, messages, "")
 }
 
-// GetUserPermissions returns permissions for a given user role
+// GetUserPermissions returns the permissions the loaded policies grant role,
+// including inherited roles.
+//
+// Deprecated: role permissions come from the roles section of policies.yaml.
+// Use Guard.Permissions, which also sees tenant roles and extra grants.
 func GetUserPermissions(role UserRole) []string {
-	switch role {
-	case RoleAdmin:
-		return []string{"financial_access", "system_access", "user_management", "file_operations", "email_send", "database_query"}
-	case RoleManager:
-		return []string{"financial_access", "email_send", "database_query"}
-	case RoleDeveloper:
-		return []string{"system_access", "file_operations", "database_query"}
-	case RoleUser:
-		return []string{"email_send"}
-	default:
-		return []string{}
-	}
+	return hallucinationguard.New().Permissions(&hallucinationguard.CallContext{UserRole: string(role)})
 }
 
 // CreateUserContext creates a user context with the specified role
@@ -154,7 +149,7 @@ func CreateUserContext(userID string, role UserRole, ipAddress string) UserConte
 	return UserContext{
 		ID:          userID,
 		Role:        role,
-		Permissions: GetUserPermissions(role),
+		Permissions: []string{},
 		IPAddress:   ipAddress,
 		Metadata:    make(map[string]string),
 	}